@@ -1,26 +1,70 @@
 package app
 
 import (
-	"database/sql"
+	"context"
 
+	"order-service/config"
+	"order-service/config/database"
+	"order-service/internal/consumer"
 	"order-service/internal/delivery/rest"
+	"order-service/internal/outbox"
 	repo "order-service/internal/repository/mysql"
 	cache "order-service/internal/repository/redis"
+	"order-service/internal/saga"
 	shard "order-service/internal/sharding"
 	"order-service/internal/usecase"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
 	"github.com/segmentio/kafka-go"
 )
 
-func NewApp(router *mux.Router, dbShards []*sql.DB, rdb *redis.Client, kafkaWriter *kafka.Writer) {
-	orderShard := shard.NewShardRouter(len(dbShards))
-	orderRepo := repo.NewOrderRepository(dbShards, orderShard)
+func NewApp(ctx context.Context, router *mux.Router, shards []*database.Shard, rdb *redis.Client, kafkaWriter *kafka.Writer) {
+	dbShards := database.Primaries(shards)
+	readShards := make([]repo.ShardReader, len(shards))
+	for i, s := range shards {
+		readShards[i] = s
+	}
+
+	orderShard, err := NewShardRouter(config.AppConfig.Shard, config.AppConfig.Shard.PreviousPlacement)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid shard configuration")
+	}
+	orderIDGen, err := shard.NewOrderIDGenerator(config.AppConfig.Server.NodeID)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid NODE_ID")
+	}
+	orderRepo := repo.NewOrderRepository(dbShards, readShards, orderShard, orderIDGen)
+	sagaRepo := repo.NewSagaRepository(dbShards, orderShard, orderIDGen)
 	orderCache := cache.NewOrderCache(rdb)
-	orderUsecase := usecase.NewOrderUsecase(orderRepo, orderCache, kafkaWriter, "http://localhost:8001", "http://localhost:8003")
+	orderUsecase := usecase.NewOrderUsecase(orderRepo, sagaRepo, orderCache, "http://localhost:8001", "http://localhost:8003")
+
+	// Resume order sagas interrupted by a restart
+	sagaResumer := saga.NewResumer(orderUsecase)
+	go sagaResumer.Start(ctx)
+
+	// One outbox relay per shard publishes committed order events to Kafka
+	outboxRepo := repo.NewOutboxRepository(dbShards)
+	for shardIndex := range dbShards {
+		relay := outbox.NewRelay(outboxRepo, kafkaWriter, shardIndex)
+		go relay.Start(ctx)
+	}
+
+	// Unpaid orders are cancelled once their stock reservation expires
+	reservationConsumer := consumer.NewReservationConsumer(orderUsecase, config.AppConfig.Kafka)
+	go reservationConsumer.Start(ctx)
 
 	orderHandler := rest.NewOrderHandler(orderUsecase)
 
 	rest.RegisterRoutes(router, orderHandler)
 }
+
+// NewShardRouter builds the shard router from the configured shards, in configuration order.
+func NewShardRouter(cfg config.ShardConfig, previousPlacement string) (*shard.ShardRouter, error) {
+	nodes := make([]shard.RingNode, len(cfg.Shards))
+	for i, shardConfig := range cfg.Shards {
+		nodes[i] = shard.RingNode{Shard: i, Name: shardConfig.Name, Weight: shardConfig.Weight}
+	}
+	return shard.NewRouter(nodes, cfg.VirtualNodes, previousPlacement)
+}
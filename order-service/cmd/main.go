@@ -43,24 +43,80 @@ func main() {
 	logger.InitializeLogger(config.AppConfig)
 
 	// Initialize DB
-	dbShard, err := database.NewMySQLShardConnection(config.AppConfig)
+	shards, err := database.NewMySQLShardConnection(config.AppConfig)
 	if err != nil {
 		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to connect to database: %v", err))
 	}
-	for _, db := range dbShard {
-		defer db.Close()
+	for _, shard := range shards {
+		defer shard.Close()
 	}
+	dbShard := database.Primaries(shards)
 
 	err = migration.AutoMigrateOrders(3, dbShard...)
 	if err != nil {
 		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate orders table: %v", err))
 	}
 
+	err = migration.AutoMigrateOrderCreatedAt(dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate orders created_at column: %v", err))
+	}
+
 	err = migration.AutoMigrateProductRequests(3, dbShard...)
 	if err != nil {
 		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate product_requests table: %v", err))
 	}
 
+	err = migration.AutoMigrateProductRequestSKU(dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate product_requests sku column: %v", err))
+	}
+
+	err = migration.AutoMigrateProductRequestReservations(dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate product_requests reservation_id column: %v", err))
+	}
+
+	err = migration.AutoMigrateOrderOutbox(3, dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate order_outbox table: %v", err))
+	}
+
+	err = migration.AutoMigrateOrderOutboxAggregateIndex(dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate order_outbox aggregate index: %v", err))
+	}
+
+	err = migration.AutoMigrateOrderSagas(3, dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate order_sagas table: %v", err))
+	}
+
+	err = migration.AutoMigrateOrderStatusHistory(3, dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate order_status_history table: %v", err))
+	}
+
+	err = migration.AutoMigrateOrderCoupons(dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate order coupon columns: %v", err))
+	}
+
+	err = migration.AutoMigrateOrderSagaLeases(dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate order saga lease columns: %v", err))
+	}
+
+	err = migration.AutoMigrateOrderIDsToBigint(dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate order ID columns: %v", err))
+	}
+
+	err = migration.AutoMigrateOrderMoves(3, dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate order_moves table: %v", err))
+	}
+
 	// Initialize DB
 	rdb, err := cache.NewRedisClient(config.AppConfig)
 	if err != nil {
@@ -69,11 +125,12 @@ func main() {
 	defer rdb.Close()
 
 	kafkaWriter := kafka.NewKafkaWriter(config.AppConfig, "order-topic")
+	defer kafkaWriter.Close()
 
 	// Router setup
 	router := mux.NewRouter()
 
-	app.NewApp(router, dbShard, rdb, kafkaWriter)
+	app.NewApp(ctx, router, shards, rdb, kafkaWriter)
 
 	// Start server
 	server := &http.Server{
@@ -0,0 +1,58 @@
+// Command reshard moves orders to the shard the consistent-hash ring places their user on.
+//
+// Run it after adding a shard or changing the ring while the service runs with
+// SHARD_PREVIOUS_PLACEMENT set to the old placement, then clear SHARD_PREVIOUS_PLACEMENT.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"order-service/cmd/app"
+	"order-service/config"
+	"order-service/config/database"
+	repo "order-service/internal/repository/mysql"
+	"order-service/internal/reshard"
+	"order-service/migration"
+	"order-service/pkg/logger"
+
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only log the users that would be moved")
+	batchSize := flag.Int("batch", 100, "orders moved per transaction")
+	flag.Parse()
+
+	config.LoadConfig()
+	logger.InitializeLogger(config.AppConfig)
+
+	shards, err := database.NewMySQLShardConnection(config.AppConfig)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to connect to database: %v", err))
+	}
+	for _, shard := range shards {
+		defer shard.Close()
+	}
+	dbShard := database.Primaries(shards)
+
+	err = migration.AutoMigrateOrderMoves(3, dbShard...)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Failed to migrate order_moves table: %v", err))
+	}
+
+	// Only the current placement matters, every shard is scanned for misplaced users
+	router, err := app.NewShardRouter(config.AppConfig.Shard, "")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid shard configuration")
+	}
+
+	resharder := reshard.NewResharder(repo.NewReshardRepository(dbShard), router, *batchSize, *dryRun)
+	report, err := resharder.Run(context.Background())
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Resharding stopped after moving %d orders of %d users", report.Orders, report.Users)
+	}
+
+	log.Info().Msgf("Resharding done: %d orders of %d users moved", report.Orders, report.Users)
+}
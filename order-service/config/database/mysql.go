@@ -4,50 +4,108 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"time"
+	"sync/atomic"
 
 	"order-service/config"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
-func NewMySQLShardConnection(cfg *config.Config) (dbs []*sql.DB, err error) {
+// Shard holds the connection pools of one order shard.
+type Shard struct {
+	Name     string
+	Primary  *sql.DB
+	Replicas []*sql.DB
+	next     atomic.Uint32
+}
+
+// Reader returns the read replicas in turn, or the primary when the shard has none.
+func (s *Shard) Reader() *sql.DB {
+	if len(s.Replicas) == 0 {
+		return s.Primary
+	}
+	return s.Replicas[int(s.next.Add(1)-1)%len(s.Replicas)]
+}
+
+// Close closes the primary and replica pools of the shard.
+func (s *Shard) Close() {
+	s.Primary.Close()
+	for _, replica := range s.Replicas {
+		replica.Close()
+	}
+}
+
+// Primaries returns the primary pool of every shard, indexed by shard.
+func Primaries(shards []*Shard) []*sql.DB {
+	dbs := make([]*sql.DB, len(shards))
+	for i, shard := range shards {
+		dbs[i] = shard.Primary
+	}
+	return dbs
+}
+
+// NewMySQLShardConnection opens and pings the primary and replicas of every configured shard.
+func NewMySQLShardConnection(cfg *config.Config) (shards []*Shard, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("error occured %+v", r)
 		}
 	}()
-	dbConfig := cfg.MySql
-	dbConfig2 := cfg.MySql2
-	dbConfig3 := cfg.MySql3
 
-	shardConfigs := []string{
-		fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-			dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Name),
-		fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-			dbConfig2.User, dbConfig2.Password, dbConfig2.Host, dbConfig2.Port, dbConfig2.Name),
-		fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-			dbConfig3.User, dbConfig3.Password, dbConfig3.Host, dbConfig3.Port, dbConfig3.Name),
-	}
+	for _, shardConfig := range cfg.Shard.Shards {
+		shard := &Shard{Name: shardConfig.Name}
+		shards = append(shards, shard)
 
-	for _, dsn := range shardConfigs {
-		db, err := sql.Open("mysql", dsn)
+		shard.Primary, err = openShardDB(shardConfig, shardConfig.DSN)
 		if err != nil {
-			return nil, fmt.Errorf("failed to connect to shard %s: %w", dbConfig.Name, err)
+			closeShards(shards)
+			return nil, fmt.Errorf("failed to connect to shard %s: %w", shardConfig.Name, err)
 		}
 
-		// Configure connection pool
-		db.SetMaxIdleConns(10)
-		db.SetMaxOpenConns(100)
-		db.SetConnMaxLifetime(time.Hour)
-
-		// Verify connection
-		if err = db.Ping(); err != nil {
-			return nil, fmt.Errorf("failed to ping shard %s: %w", dbConfig.Name, err)
+		for i, dsn := range shardConfig.Replicas {
+			replica, err := openShardDB(shardConfig, dsn)
+			if err != nil {
+				closeShards(shards)
+				return nil, fmt.Errorf("failed to connect to replica %d of shard %s: %w", i, shardConfig.Name, err)
+			}
+			shard.Replicas = append(shard.Replicas, replica)
 		}
+	}
+
+	return shards, nil
+}
+
+func openShardDB(shardConfig config.ShardNodeConfig, dsn string) (db *sql.DB, err error) {
+	// Order timestamps are scanned into time.Time
+	dsnConfig, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	dsnConfig.ParseTime = true
+
+	db, err = sql.Open("mysql", dsnConfig.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure connection pool
+	db.SetMaxIdleConns(shardConfig.MaxIdleConns)
+	db.SetMaxOpenConns(shardConfig.MaxOpenConns)
+	db.SetConnMaxLifetime(shardConfig.ConnMaxLifetime.Duration)
 
-		dbs = append(dbs, db)
+	// Verify connection
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	return dbs, nil
+	return db, nil
+}
+
+func closeShards(shards []*Shard) {
+	for _, shard := range shards {
+		if shard.Primary != nil {
+			shard.Close()
+		}
+	}
 }
@@ -13,25 +13,16 @@ var AppConfig *Config
 // Config holds all configuration for the application
 type Config struct {
 	Server ServerConfig
-	MySql  MySqlConfig
-	MySql2 MySqlConfig
-	MySql3 MySqlConfig
 	Redis  RedisConfig
 	Jwt    JwtConfig
 	Log    LogConfig
 	Kafka  KafkaConfig
+	Shard  ShardConfig
 }
 
 type ServerConfig struct {
-	Port string
-}
-
-type MySqlConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Name     string
+	Port   string
+	NodeID int // unique per instance, encoded in generated order IDs
 }
 
 type RedisConfig struct {
@@ -50,6 +41,14 @@ type LogConfig struct {
 	LogFilePath    string
 }
 
+type ShardConfig struct {
+	Shards       []ShardNodeConfig
+	VirtualNodes int
+	// PreviousPlacement is the placement users are being moved from, e.g. "modulo:3".
+	// Reads of a user also go to that shard until it is cleared after running cmd/reshard.
+	PreviousPlacement string
+}
+
 type KafkaConfig struct {
 	Host string
 	Port string
@@ -66,27 +65,6 @@ func LoadConfig() {
 		Server: ServerConfig{
 			Port: getEnv("PORT", "8002"),
 		},
-		MySql: MySqlConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "3307"),
-			User:     getEnv("DB_USER", "root"),
-			Password: getEnv("DB_PASS", ""),
-			Name:     getEnv("DB_NAME", "order-db"),
-		},
-		MySql2: MySqlConfig{
-			Host:     getEnv("DB2_HOST", "localhost"),
-			Port:     getEnv("DB2_PORT", "3308"),
-			User:     getEnv("DB2_USER", "root"),
-			Password: getEnv("DB2_PASS", ""),
-			Name:     getEnv("DB2_NAME", "order-db"),
-		},
-		MySql3: MySqlConfig{
-			Host:     getEnv("DB3_HOST", "localhost"),
-			Port:     getEnv("DB3_PORT", "3309"),
-			User:     getEnv("DB3_USER", "root"),
-			Password: getEnv("DB3_PASS", ""),
-			Name:     getEnv("DB3_NAME", "order-db"),
-		},
 		Redis: RedisConfig{
 			Host: getEnv("REDIS_HOST", "localhost"),
 			Port: getEnv("REDIS_PORT", "6379"),
@@ -103,9 +81,24 @@ func LoadConfig() {
 			Host: getEnv("KAFKA_HOST", "localhost"),
 			Port: getEnv("KAFKA_PORT", "9092"),
 		},
+		Shard: ShardConfig{
+			// Orders used to be placed by user_id % 3 before the hash ring
+			PreviousPlacement: getEnv("SHARD_PREVIOUS_PLACEMENT", "modulo:3"),
+		},
 	}
 
 	AppConfig.Log.LogFileEnabled, _ = strconv.ParseBool(getEnv("LOG_FILE_ENABLED", "true"))
+	AppConfig.Server.NodeID, _ = strconv.Atoi(getEnv("NODE_ID", "0"))
+	AppConfig.Shard.VirtualNodes, _ = strconv.Atoi(getEnv("SHARD_VIRTUAL_NODES", "128"))
+
+	shards, err := loadShards()
+	if err != nil {
+		log.Fatalf("Failed to load shard configuration: %v", err)
+	}
+	AppConfig.Shard.Shards = shards
+	if err = AppConfig.Shard.Validate(); err != nil {
+		log.Fatalf("Invalid shard configuration: %v", err)
+	}
 
 }
 
@@ -13,7 +13,7 @@ func NewKafkaWriter(cfg *config.Config, topic string) *kafka.Writer {
 	return &kafka.Writer{
 		Addr:                   kafka.TCP(broker),
 		Topic:                  topic,
-		Balancer:               &kafka.LeastBytes{}, // Balancer for selecting partition
+		Balancer:               &kafka.Hash{}, // Same key always lands on the same partition
 		AllowAutoTopicCreation: true,
 	}
 }
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShardNodeConfig is one order database shard. Its position in the shard list is its shard index,
+// which is encoded in order IDs, so shards may only be appended to the list.
+type ShardNodeConfig struct {
+	Name            string   `json:"name"` // position on the hash ring, keep it stable
+	DSN             string   `json:"dsn"`
+	Weight          int      `json:"weight"`
+	MaxOpenConns    int      `json:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`
+	Replicas        []string `json:"replicas"` // read-replica DSNs
+}
+
+// Duration is a time.Duration written as a string like "1h" in JSON.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) (err error) {
+	var value string
+	if err = json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	d.Duration, err = time.ParseDuration(value)
+	return err
+}
+
+// shardsFile is the layout of SHARDS_CONFIG_FILE.
+type shardsFile struct {
+	Shards []ShardNodeConfig `json:"shards"`
+}
+
+// loadShards reads the shard list from SHARDS_CONFIG_FILE, else from the comma-separated DSNs in DB_SHARDS,
+// else from the DB_*, DB2_* and DB3_* variables of the original three shards. Unset pool settings and
+// weights get the defaults.
+func loadShards() (shards []ShardNodeConfig, err error) {
+	if path := getEnv("SHARDS_CONFIG_FILE", ""); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var file shardsFile
+		if err = json.Unmarshal(raw, &file); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", path, err)
+		}
+		shards = file.Shards
+	} else if list := getEnv("DB_SHARDS", ""); list != "" {
+		for _, dsn := range strings.Split(list, ",") {
+			shards = append(shards, ShardNodeConfig{DSN: strings.TrimSpace(dsn)})
+		}
+	} else {
+		for _, prefix := range []string{"DB", "DB2", "DB3"} {
+			shards = append(shards, ShardNodeConfig{DSN: legacyDSN(prefix)})
+		}
+	}
+
+	maxOpenConns, _ := strconv.Atoi(getEnv("DB_MAX_OPEN_CONNS", "100"))
+	maxIdleConns, _ := strconv.Atoi(getEnv("DB_MAX_IDLE_CONNS", "10"))
+	connMaxLifetime, err := time.ParseDuration(getEnv("DB_CONN_MAX_LIFETIME", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_CONN_MAX_LIFETIME: %w", err)
+	}
+
+	for i := range shards {
+		if shards[i].Name == "" {
+			shards[i].Name = fmt.Sprintf("shard-%d", i)
+		}
+		if shards[i].Weight == 0 {
+			shards[i].Weight = 1
+		}
+		if shards[i].MaxOpenConns == 0 {
+			shards[i].MaxOpenConns = maxOpenConns
+		}
+		if shards[i].MaxIdleConns == 0 {
+			shards[i].MaxIdleConns = maxIdleConns
+		}
+		if shards[i].ConnMaxLifetime.Duration == 0 {
+			shards[i].ConnMaxLifetime.Duration = connMaxLifetime
+		}
+	}
+
+	return shards, nil
+}
+
+func legacyDSN(prefix string) string {
+	ports := map[string]string{"DB": "3307", "DB2": "3308", "DB3": "3309"}
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
+		getEnv(prefix+"_USER", "root"), getEnv(prefix+"_PASS", ""), getEnv(prefix+"_HOST", "localhost"),
+		getEnv(prefix+"_PORT", ports[prefix]), getEnv(prefix+"_NAME", "order-db"))
+}
+
+// Validate checks the shard topology before any connection is opened.
+func (c ShardConfig) Validate() error {
+	if len(c.Shards) == 0 {
+		return errors.New("no shards configured")
+	}
+	if c.VirtualNodes <= 0 {
+		return fmt.Errorf("virtual nodes must be positive, got %d", c.VirtualNodes)
+	}
+
+	names := map[string]bool{}
+	for i, shard := range c.Shards {
+		switch {
+		case shard.DSN == "":
+			return fmt.Errorf("shard %d (%s) has no dsn", i, shard.Name)
+		case names[shard.Name]:
+			return fmt.Errorf("shard name %q is used twice", shard.Name)
+		case shard.Weight < 0:
+			return fmt.Errorf("shard %s has negative weight %d", shard.Name, shard.Weight)
+		case shard.MaxOpenConns < 0 || shard.MaxIdleConns < 0 || shard.ConnMaxLifetime.Duration < 0:
+			return fmt.Errorf("shard %s has negative pool settings", shard.Name)
+		case shard.MaxIdleConns > shard.MaxOpenConns:
+			return fmt.Errorf("shard %s has max_idle_conns %d above max_open_conns %d", shard.Name, shard.MaxIdleConns, shard.MaxOpenConns)
+		}
+		for _, replica := range shard.Replicas {
+			if replica == "" {
+				return fmt.Errorf("shard %s has an empty replica dsn", shard.Name)
+			}
+		}
+		names[shard.Name] = true
+	}
+
+	return nil
+}
@@ -7,5 +7,16 @@ const (
 	UserIDlKey       contextKey = "user_id"
 	UserNameKey      contextKey = "username"
 	UserEmailKey     contextKey = "email"
+	UserRoleKey      contextKey = "role"
 	AuthorizationKey contextKey = "Authorization"
 )
+
+const (
+	RoleAdmin   = "admin"   // JWT role allowed to use admin endpoints
+	RoleService = "service" // JWT role of other services, e.g. a payment service marking orders paid
+)
+
+// IsStaff reports whether role acts for the shop rather than for a customer.
+func IsStaff(role string) bool {
+	return role == RoleAdmin || role == RoleService
+}
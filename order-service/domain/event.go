@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderEventSchemaVersion is the envelope version produced by this service.
+const OrderEventSchemaVersion = 1
+
+const (
+	OrderEventCreated   = "order.created"
+	OrderEventUpdated   = "order.updated"
+	OrderEventCancelled = "order.cancelled"
+)
+
+// Kafka headers carried alongside every order event
+const (
+	EventHeaderID            = "event-id"
+	EventHeaderType          = "event-type"
+	EventHeaderSchemaVersion = "schema-version"
+)
+
+var (
+	ErrUnknownEventType         = errors.New("unknown order event type")
+	ErrUnsupportedSchemaVersion = errors.New("unsupported order event schema version")
+)
+
+// OrderEvent is the envelope published to order-topic. Payload holds the Order for the given schema version.
+type OrderEvent struct {
+	EventID       string          `json:"event_id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	AggregateID   int             `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// NewOrderEvent wraps the order in a new envelope of the current schema version.
+func NewOrderEvent(eventType string, order Order) (event OrderEvent, err error) {
+	payload, err := json.Marshal(order)
+	if err != nil {
+		return event, err
+	}
+
+	event = OrderEvent{
+		EventID:       uuid.New().String(),
+		Type:          eventType,
+		SchemaVersion: OrderEventSchemaVersion,
+		OccurredAt:    time.Now().UTC(),
+		AggregateID:   order.ID,
+		Payload:       payload,
+	}
+
+	return event, event.Validate()
+}
+
+// Validate checks that the envelope is complete and of a known type and version.
+func (e OrderEvent) Validate() error {
+	if e.SchemaVersion != OrderEventSchemaVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedSchemaVersion, e.SchemaVersion)
+	}
+
+	switch e.Type {
+	case OrderEventCreated, OrderEventUpdated, OrderEventCancelled:
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownEventType, e.Type)
+	}
+
+	if e.EventID == "" || e.AggregateID == 0 || len(e.Payload) == 0 {
+		return errors.New("order event is missing event_id, aggregate_id or payload")
+	}
+
+	return nil
+}
@@ -1,5 +1,15 @@
 package domain
 
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrCouponNotApplicable = errors.New("coupon not applicable")
+)
+
 type Order struct {
 	ID              int              `json:"id"`
 	UserID          int              `json:"user_id"`
@@ -8,22 +18,39 @@ type Order struct {
 	Total           float64          `json:"total"`
 	TotalMarkUp     float64          `json:"total_mark_up"`
 	TotalDiscount   float64          `json:"total_discount"`
-	Status          string           `json:"status"` // e.g., "created", "paid", "canceled"
+	CouponCode      string           `json:"coupon_code,omitempty"`
+	CouponDiscount  float64          `json:"coupon_discount,omitempty"` // taken off Total
+	Status          string           `json:"status"`                    // see OrderStatus constants
 	IdempotentKey   string           `json:"idempotent_key"`
+	CreatedAt       time.Time        `json:"created_at"`
 }
 
 type ProductRequest struct {
-	ProductID  int     `json:"product_id"`
-	Quantity   int     `json:"quantity"`
-	MarkUp     float64 `json:"mark_up"`
-	Discount   float64 `json:"discount"`
-	FinalPrice float64 `json:"final_price"`
+	ProductID     int     `json:"product_id"`
+	SKU           string  `json:"sku,omitempty"` // variant of the product, empty for products without variants
+	Quantity      int     `json:"quantity"`
+	MarkUp        float64 `json:"mark_up"`
+	Discount      float64 `json:"discount"`
+	FinalPrice    float64 `json:"final_price"`
+	ReservationID int64   `json:"reservation_id,omitempty"` // stock reservation holding the item, 0 for orders reserved before reservations
+}
+
+// HoldsReservation reports whether an item of the order is held by the stock reservation.
+func (o Order) HoldsReservation(reservationID int64) bool {
+	for _, productRequest := range o.ProductRequests {
+		if productRequest.ReservationID == reservationID {
+			return true
+		}
+	}
+	return false
 }
 
 type OrderRequest struct {
 	ProductRequests []struct {
-		ProductID int `json:"product_id"`
-		Quantity  int `json:"quantity"`
+		ProductID int    `json:"product_id"`
+		SKU       string `json:"sku"`
+		Quantity  int    `json:"quantity"`
 	}
+	CouponCode    string `json:"coupon_code"`
 	IdempotentKey string `json:"-"`
 }
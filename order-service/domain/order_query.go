@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultOrderPageLimit = 20
+	MaxOrderPageLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// OrderFilter selects orders, newest first. A zero UserID matches orders of every user.
+type OrderFilter struct {
+	UserID      int
+	Status      string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Cursor      *OrderCursor
+	Limit       int
+}
+
+// OrderCursor points at the last order of a page; the next page starts right after it.
+type OrderCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        int       `json:"id"`
+}
+
+// OrderPage is one page of orders with the cursor of the next page, empty on the last page.
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c OrderCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeOrderCursor parses a cursor produced by OrderCursor.Encode.
+func DecodeOrderCursor(value string) (cursor OrderCursor, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	if err = json.Unmarshal(raw, &cursor); err != nil || cursor.ID == 0 {
+		return cursor, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// OrderSortsBefore reports whether order a comes before order b in newest-first order.
+func OrderSortsBefore(a, b Order) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	OrderStatusCreated   = "created"  // legacy orders created before stock reservation
+	OrderStatusReserved  = "reserved" // stock reserved and price locked, awaiting payment
+	OrderStatusPaid      = "paid"
+	OrderStatusFulfilled = "fulfilled"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+	OrderStatusFailed    = "failed"
+)
+
+// orderTransitions lists the statuses an order may move to from each status.
+// Statuses without an entry are terminal.
+var orderTransitions = map[string][]string{
+	OrderStatusCreated:   {OrderStatusReserved, OrderStatusCancelled, OrderStatusFailed},
+	OrderStatusReserved:  {OrderStatusPaid, OrderStatusCancelled, OrderStatusFailed},
+	OrderStatusPaid:      {OrderStatusFulfilled, OrderStatusRefunded},
+	OrderStatusFulfilled: {OrderStatusRefunded},
+}
+
+// ErrOrderStatusConflict is returned when the order status changed between reading and updating it.
+var ErrOrderStatusConflict = errors.New("order status was changed concurrently")
+
+// ErrStatusChangeForbidden is returned when the role of the caller may not set the requested status.
+var ErrStatusChangeForbidden = errors.New("order status change not allowed")
+
+// ErrReservationExpired is returned when an order is paid after its stock reservation expired; the order is cancelled.
+var ErrReservationExpired = errors.New("stock reservation of the order expired")
+
+// CanSetStatus reports whether a caller with role may move an order to status. Admins and other services
+// may set any status the state machine allows; customers may only cancel their orders.
+func CanSetStatus(role, status string) bool {
+	return IsStaff(role) || status == OrderStatusCancelled
+}
+
+// InvalidTransitionError is returned for a status change the order state machine does not allow.
+type InvalidTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot change order status from %q to %q", e.From, e.To)
+}
+
+// ValidateTransition checks that an order in status from may move to status to.
+func ValidateTransition(from, to string) error {
+	for _, allowed := range orderTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &InvalidTransitionError{From: from, To: to}
+}
+
+// IsOrderStatus reports whether status is a known order status.
+func IsOrderStatus(status string) bool {
+	switch status {
+	case OrderStatusCreated, OrderStatusReserved, OrderStatusPaid, OrderStatusFulfilled,
+		OrderStatusCancelled, OrderStatusRefunded, OrderStatusFailed:
+		return true
+	}
+	return false
+}
+
+// OrderStatusHistory records a single status change of an order.
+type OrderStatusHistory struct {
+	OrderID    int       `json:"order_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ChangedBy  int       `json:"changed_by"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+)
+
+// OutboxMessage is an order event stored in the order_outbox table of a shard,
+// written in the same transaction as the order and relayed to Kafka afterwards.
+type OutboxMessage struct {
+	ID          int64     `json:"id"`
+	EventID     string    `json:"event_id"`
+	EventType   string    `json:"event_type"`
+	Version     int       `json:"schema_version"`
+	AggregateID int       `json:"aggregate_id"`
+	Payload     []byte    `json:"payload"` // JSON encoded OrderEvent
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -1,8 +1,30 @@
 package domain
 
+// Pricing is the pricing of a quantity of a product as returned by pricing-service. Total is the price of the
+// whole quantity with its quantity tier and bundle applied, so it is not always Quantity times FinalPrice.
 type Pricing struct {
 	ProductID  int     `json:"product_id"`
+	Quantity   int     `json:"quantity"`
 	Markup     float64 `json:"markup"`      // Markup percentage
 	Discount   float64 `json:"discount"`    // Discount percentage
 	FinalPrice float64 `json:"final_price"` // Calculated final price
+	Total      float64 `json:"total"`
+}
+
+// MarkupAmount is the part of Total added by the markup, e.g. 20 of a total of 120 with a markup of 0.2.
+func (p Pricing) MarkupAmount() float64 {
+	return p.Total * p.Markup / (1 + p.Markup)
+}
+
+// DiscountAmount is what the discount took off Total, e.g. 20 off a total of 80 with a discount of 0.2.
+// Pricing rules keep discounts below 1.
+func (p Pricing) DiscountAmount() float64 {
+	return p.Total * p.Discount / (1 - p.Discount)
+}
+
+// CartPricing is the pricing of a whole cart, with its lines in the order they were requested.
+type CartPricing struct {
+	Lines    []Pricing `json:"lines"`
+	Quantity int       `json:"quantity"`
+	Total    float64   `json:"total"`
 }
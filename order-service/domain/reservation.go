@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// ReservationStateHeld is the state of a product-service stock reservation that still holds its stock.
+const ReservationStateHeld = "held"
+
+// ReservationEventExpired is published by product-service when an expired reservation gave its stock back.
+const ReservationEventExpired = "reservation.expired"
+
+// ReservationEvent is a stock reservation event of product-service. Reservations of the order saga carry
+// the saga in their reference, see SagaIDFromReference.
+type ReservationEvent struct {
+	EventID       string    `json:"event_id"`
+	Type          string    `json:"type"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	ReservationID int64     `json:"reservation_id"`
+	Reference     string    `json:"reference,omitempty"`
+	ProductID     int       `json:"product_id"`
+	SKU           string    `json:"sku,omitempty"`
+	Quantity      int       `json:"quantity"`
+}
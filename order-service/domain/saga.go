@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Order saga steps, persisted so an interrupted saga can be resumed
+const (
+	SagaStatusStarted        = "started"         // reserving stock
+	SagaStatusStockReserved  = "stock_reserved"  // locking prices
+	SagaStatusPriceLocked    = "price_locked"    // redeeming the coupon, if any
+	SagaStatusCouponRedeemed = "coupon_redeemed" // persisting the order
+	SagaStatusCompleted      = "completed"
+	SagaStatusCompensating   = "compensating" // releasing reserved stock and the coupon
+	SagaStatusFailed         = "failed"
+)
+
+var (
+	ErrProductOutOfStock = errors.New("product out of stock")
+	ErrSagaConflict      = errors.New("order saga is being processed by another worker")
+	ErrDuplicateOrder    = errors.New("an order with this idempotent key already exists")
+)
+
+// StockShortfall is a product product-service could not reserve, as reported by its batch reserve.
+type StockShortfall struct {
+	ProductID int    `json:"product_id"`
+	SKU       string `json:"sku,omitempty"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// OutOfStockError lists the products that were short when reserving an order. It matches ErrProductOutOfStock.
+type OutOfStockError struct {
+	Shortfalls []StockShortfall
+}
+
+func (e *OutOfStockError) Error() string {
+	products := make([]string, len(e.Shortfalls))
+	for i, shortfall := range e.Shortfalls {
+		product := fmt.Sprintf("product %d", shortfall.ProductID)
+		if shortfall.SKU != "" {
+			product += fmt.Sprintf(" sku %s", shortfall.SKU)
+		}
+		products[i] = fmt.Sprintf("%s (requested %d, available %d)", product, shortfall.Requested, shortfall.Available)
+	}
+	return fmt.Sprintf("%s: %s", ErrProductOutOfStock, strings.Join(products, ", "))
+}
+
+func (e *OutOfStockError) Unwrap() error { return ErrProductOutOfStock }
+
+// OrderSaga tracks the creation of one order across product-service and pricing-service.
+type OrderSaga struct {
+	ID             string           `json:"id"`
+	UserID         int              `json:"user_id"`
+	OrderID        int              `json:"order_id"`
+	Status         string           `json:"status"`
+	Items          []ProductRequest `json:"items"` // prices are filled in once locked
+	CouponCode     string           `json:"coupon_code"`
+	CouponDiscount float64          `json:"coupon_discount"` // set once the coupon is redeemed
+	IdempotentKey  string           `json:"idempotent_key"`
+	Error          string           `json:"error"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+	ShardIndex     int              `json:"-"` // shard the saga is stored on, which stays put if the user is resharded
+	LeaseOwner     string           `json:"-"` // worker driving the saga; only its writes are applied
+	LeaseExpiresAt time.Time        `json:"-"` // after this the resumer may take the saga over
+}
+
+// ReserveReference is the idempotency reference of the stock reservation for an item of this saga.
+func (s OrderSaga) ReserveReference(item ProductRequest) string {
+	return s.itemReference("reserve", item)
+}
+
+// ReleaseReference is the idempotency reference of the compensating stock release for an item of this saga.
+func (s OrderSaga) ReleaseReference(item ProductRequest) string {
+	return s.itemReference("release", item)
+}
+
+// CouponReference is the idempotency reference of the coupon redemption of this saga, under which it is also released.
+func (s OrderSaga) CouponReference() string {
+	return fmt.Sprintf("saga:%s:coupon", s.ID)
+}
+
+// SagaIDFromReference returns the ID of the saga a reference of ReserveReference or ReleaseReference belongs to.
+func SagaIDFromReference(reference string) (id string, ok bool) {
+	parts := strings.Split(reference, ":")
+	if len(parts) < 4 || parts[0] != "saga" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// itemReference keys references by product, and by SKU for variants, so items without a SKU keep
+// the references of sagas started before variants existed.
+func (s OrderSaga) itemReference(operation string, item ProductRequest) string {
+	if item.SKU == "" {
+		return fmt.Sprintf("saga:%s:%s:%d", s.ID, operation, item.ProductID)
+	}
+	return fmt.Sprintf("saga:%s:%s:%d:%s", s.ID, operation, item.ProductID, item.SKU)
+}
@@ -0,0 +1,102 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"order-service/config"
+	"order-service/domain"
+	"order-service/internal/usecase"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// ReservationTopic receives the stock reservation events of product-service
+const ReservationTopic = "reservation-topic"
+
+const (
+	maxAttempts = 5
+	baseBackoff = 1 * time.Second
+)
+
+// ReservationConsumer cancels unpaid orders whose stock reservation expired.
+type ReservationConsumer struct {
+	orderUsecase usecase.OrderUsecase
+	cfg          config.KafkaConfig
+}
+
+func NewReservationConsumer(orderUsecase usecase.OrderUsecase, cfg config.KafkaConfig) *ReservationConsumer {
+	return &ReservationConsumer{orderUsecase: orderUsecase, cfg: cfg}
+}
+
+// Start reads reservation events until the context is cancelled. Offsets are committed once an event is handled.
+func (c *ReservationConsumer) Start(ctx context.Context) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  []string{fmt.Sprintf("%s:%s", c.cfg.Host, c.cfg.Port)},
+		Topic:    ReservationTopic,
+		GroupID:  "order-service-group",
+		MinBytes: 10e3, // 10KB
+		MaxBytes: 10e6, // 10MB
+	})
+	defer reader.Close()
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Info().Msg("Stopping reservation consumer")
+				return
+			}
+			log.Error().Err(err).Msg("Error reading reservation event")
+			continue
+		}
+
+		c.handleMessage(ctx, msg)
+
+		err = reader.CommitMessages(ctx, msg)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error committing reservation event at offset %d", msg.Offset)
+		}
+	}
+}
+
+// handleMessage processes a message, retrying with exponential backoff. An order that still cannot be cancelled
+// after the last attempt is cancelled when it is paid, as the confirm of its expired reservation fails then.
+func (c *ReservationConsumer) handleMessage(ctx context.Context, msg kafka.Message) {
+	delay := baseBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.processMessage(ctx, msg)
+		if err == nil {
+			return
+		}
+		if attempt == maxAttempts || ctx.Err() != nil {
+			log.Error().Err(err).Msgf("Giving up on reservation event at offset %d after %d attempts", msg.Offset, attempt)
+			return
+		}
+
+		log.Warn().Err(err).Msgf("Error processing reservation event at offset %d, retrying in %s", msg.Offset, delay)
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// processMessage cancels the order of an expired reservation. Other and malformed events are skipped.
+func (c *ReservationConsumer) processMessage(ctx context.Context, msg kafka.Message) (err error) {
+	var event domain.ReservationEvent
+	err = json.Unmarshal(msg.Value, &event)
+	if err != nil {
+		log.Error().Err(err).Msgf("Skipping malformed reservation event at offset %d", msg.Offset)
+		return nil
+	}
+	if event.Type != domain.ReservationEventExpired {
+		return nil
+	}
+
+	return c.orderUsecase.CancelExpiredOrder(ctx, event)
+}
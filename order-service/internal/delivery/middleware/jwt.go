@@ -29,6 +29,7 @@ type JwtCustomClaims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
@@ -75,6 +76,7 @@ func (m *JWTMiddleware) Middleware(next http.Handler) http.Handler {
 		ctx := context.WithValue(r.Context(), domain.UserNameKey, claims.Username)
 		ctx = context.WithValue(ctx, domain.UserEmailKey, claims.Email)
 		ctx = context.WithValue(ctx, domain.UserIDlKey, claims.UserID)
+		ctx = context.WithValue(ctx, domain.UserRoleKey, claims.Role)
 		ctx = context.WithValue(ctx, domain.AuthorizationKey, token)
 
 		// Lanjutkan request dengan context yang telah diperbarui
@@ -86,3 +88,16 @@ func (m *JWTMiddleware) Middleware(next http.Handler) http.Handler {
 func (m *JWTMiddleware) RequireAuth(next http.Handler) http.Handler {
 	return m.Middleware(next)
 }
+
+// RequireAdmin adalah middleware yang memastikan user terautentikasi dengan role admin
+func (m *JWTMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value(domain.UserRoleKey).(string)
+		if role != domain.RoleAdmin {
+			utils.RespondWithJSON(w, http.StatusForbidden, map[string]string{"message": "Admin access required"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
@@ -2,11 +2,13 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"order-service/domain"
 	"order-service/internal/usecase"
 	"order-service/pkg/utils"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -30,6 +32,23 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 
 	createdOrder, err := h.orderUsecase.CreateOrder(r.Context(), order)
 	if err != nil {
+		var shortage *domain.OutOfStockError
+		if errors.As(err, &shortage) {
+			utils.RespondWithJSON(w, http.StatusConflict, map[string]interface{}{"error": err.Error(), "shortfalls": shortage.Shortfalls})
+			return
+		}
+		if errors.Is(err, domain.ErrProductOutOfStock) {
+			utils.RespondWithJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, domain.ErrCouponNotApplicable) {
+			utils.RespondWithJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, domain.ErrSagaConflict) || errors.Is(err, domain.ErrDuplicateOrder) {
+			utils.RespondWithJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
 		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -46,7 +65,7 @@ func (h *OrderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 
 	updatedOrder, err := h.orderUsecase.UpdateOrder(r.Context(), order)
 	if err != nil {
-		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondWithOrderError(w, err)
 		return
 	}
 
@@ -64,9 +83,136 @@ func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 
 	order, err := h.orderUsecase.CancelOrder(r.Context(), id)
 	if err != nil {
-		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondWithOrderError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, order)
+}
+
+func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	order, err := h.orderUsecase.GetOrder(r.Context(), id)
+	if err != nil {
+		respondWithOrderError(w, err)
 		return
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, order)
 }
+
+// ListOrders lists the orders of the current user --> /orders?status=&from=&to=&cursor=&limit=
+func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseOrderFilter(r)
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	page, err := h.orderUsecase.ListOrders(r.Context(), filter)
+	if err != nil {
+		respondWithOrderError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// SearchOrders searches orders of all users --> /admin/orders?user_id=&status=&from=&to=&cursor=&limit=
+func (h *OrderHandler) SearchOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseOrderFilter(r)
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		filter.UserID, err = strconv.Atoi(userID)
+		if err != nil || filter.UserID <= 0 {
+			utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid user_id"})
+			return
+		}
+	}
+
+	page, err := h.orderUsecase.SearchOrders(r.Context(), filter)
+	if err != nil {
+		respondWithOrderError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// parseOrderFilter reads the status, date range, cursor and limit query parameters
+func parseOrderFilter(r *http.Request) (filter domain.OrderFilter, err error) {
+	query := r.URL.Query()
+
+	filter.Status = query.Get("status")
+	if filter.Status != "" && !domain.IsOrderStatus(filter.Status) {
+		return filter, errors.New("invalid status")
+	}
+
+	filter.CreatedFrom, err = parseOrderTime(query.Get("from"))
+	if err != nil {
+		return filter, errors.New("invalid from, expected RFC3339 or YYYY-MM-DD")
+	}
+	filter.CreatedTo, err = parseOrderTime(query.Get("to"))
+	if err != nil {
+		return filter, errors.New("invalid to, expected RFC3339 or YYYY-MM-DD")
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		decoded, err := domain.DecodeOrderCursor(cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.Cursor = &decoded
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 {
+			return filter, errors.New("invalid limit")
+		}
+	}
+
+	return filter, nil
+}
+
+// parseOrderTime parses an RFC3339 timestamp or a plain date, which means midnight UTC
+func parseOrderTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+// respondWithOrderError maps order errors to their HTTP status
+func respondWithOrderError(w http.ResponseWriter, err error) {
+	var invalidTransition *domain.InvalidTransitionError
+	switch {
+	case errors.Is(err, domain.ErrOrderNotFound):
+		utils.RespondWithJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrStatusChangeForbidden):
+		utils.RespondWithJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalidTransition), errors.Is(err, domain.ErrOrderStatusConflict), errors.Is(err, domain.ErrReservationExpired):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
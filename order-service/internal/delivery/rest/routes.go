@@ -23,6 +23,9 @@ func RegisterRoutes(router *mux.Router, orderHandler *OrderHandler) {
 
 	// Register order routes
 	registerOrderRoutes(apiRouter, orderHandler, jwtMiddleware)
+
+	// Register admin routes
+	registerAdminRoutes(apiRouter, orderHandler, jwtMiddleware)
 }
 
 func registerOrderRoutes(router *mux.Router, handler *OrderHandler, jwtMiddleware *middleware.JWTMiddleware) {
@@ -32,11 +35,19 @@ func registerOrderRoutes(router *mux.Router, handler *OrderHandler, jwtMiddlewar
 	// Protected routes
 	protected := orderRouter.PathPrefix("").Subrouter()
 	protected.Use(jwtMiddleware.RequireAuth)
+	protected.HandleFunc("", handler.ListOrders).Methods("GET")
+	protected.HandleFunc("/{id:[0-9]+}", handler.GetOrder).Methods("GET")
 	protected.HandleFunc("", handler.CreateOrder).Methods("POST")
 	protected.HandleFunc("", handler.UpdateOrder).Methods("PUT")
 	protected.HandleFunc("/{id:[0-9]+}", handler.CancelOrder).Methods("DELETE")
 }
 
+func registerAdminRoutes(router *mux.Router, handler *OrderHandler, jwtMiddleware *middleware.JWTMiddleware) {
+	adminRouter := router.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(jwtMiddleware.RequireAdmin)
+	adminRouter.HandleFunc("/orders", handler.SearchOrders).Methods("GET")
+}
+
 // HealthCheck handler for the health endpoint
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
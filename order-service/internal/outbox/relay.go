@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"order-service/domain"
+	repo "order-service/internal/repository/mysql"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	pollInterval = 1 * time.Second
+	batchSize    = 100
+	baseBackoff  = 1 * time.Second
+	maxBackoff   = 5 * time.Minute
+)
+
+// Relay publishes pending order_outbox rows of a single shard to Kafka and marks them sent.
+type Relay struct {
+	repo        repo.OutboxRepository
+	kafkaWriter *kafka.Writer
+	shardIndex  int
+}
+
+func NewRelay(repo repo.OutboxRepository, kafkaWriter *kafka.Writer, shardIndex int) *Relay {
+	return &Relay{
+		repo:        repo,
+		kafkaWriter: kafkaWriter,
+		shardIndex:  shardIndex,
+	}
+}
+
+// Start polls the shard outbox until the context is cancelled.
+func (r *Relay) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msgf("Stopping outbox relay for shard %d", r.shardIndex)
+			return
+		case <-ticker.C:
+		}
+
+		// Keep draining while full batches are returned
+		for {
+			sent, err := r.relayBatch(ctx)
+			if err != nil {
+				log.Error().Err(err).Msgf("Error relaying outbox messages for shard %d", r.shardIndex)
+				break
+			}
+			if sent < batchSize {
+				break
+			}
+		}
+	}
+}
+
+// relayBatch claims a batch of due messages and publishes them one by one in insertion order.
+func (r *Relay) relayBatch(ctx context.Context) (sent int, err error) {
+	messages, err := r.repo.ClaimPendingMessages(ctx, r.shardIndex, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, message := range messages {
+		err = r.publish(ctx, message)
+		if err != nil {
+			// Stop at the first failure so later events of the same order are not published ahead of it
+			log.Error().Err(err).Msgf("Error publishing outbox message %d on shard %d", message.ID, r.shardIndex)
+			r.fail(ctx, messages[i:], err)
+			return sent, nil
+		}
+
+		err = r.repo.MarkMessageSent(ctx, r.shardIndex, message.ID)
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (r *Relay) publish(ctx context.Context, message domain.OutboxMessage) (err error) {
+	// The order ID is the partition key so all events of one order stay in order
+	msg := kafka.Message{
+		Key:   []byte(strconv.Itoa(message.AggregateID)),
+		Value: message.Payload,
+		Headers: []kafka.Header{
+			{Key: domain.EventHeaderID, Value: []byte(message.EventID)},
+			{Key: domain.EventHeaderType, Value: []byte(message.EventType)},
+			{Key: domain.EventHeaderSchemaVersion, Value: []byte(strconv.Itoa(message.Version))},
+		},
+	}
+
+	return r.kafkaWriter.WriteMessages(ctx, msg)
+}
+
+// fail schedules the remaining messages for another attempt with exponential backoff,
+// all at the same time so they keep their relative order.
+func (r *Relay) fail(ctx context.Context, messages []domain.OutboxMessage, cause error) {
+	nextAttemptAt := time.Now().Add(backoff(messages[0].Attempts + 1))
+	for _, message := range messages {
+		err := r.repo.MarkMessageFailed(ctx, r.shardIndex, message.ID, nextAttemptAt, cause.Error())
+		if err != nil {
+			log.Error().Err(err).Msgf("Error rescheduling outbox message %d on shard %d", message.ID, r.shardIndex)
+		}
+	}
+}
+
+// backoff returns the delay before the given attempt, doubling from baseBackoff up to maxBackoff.
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,180 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+
+	"order-service/domain"
+)
+
+// ShardReader returns the pool for reads of a shard that tolerate replication lag, such as order listings.
+type ShardReader interface {
+	Reader() *sql.DB
+}
+
+// GetOrdersByUser lists orders of one user from the shard that owns the user. While the user is being
+// resharded the shard the user is moving from is read as well.
+func (r *orderRepository) GetOrdersByUser(ctx context.Context, filter domain.OrderFilter) (page domain.OrderPage, err error) {
+	var orders []domain.Order
+	for _, dbIndex := range r.shard.GetReadShards(filter.UserID) {
+		// Fetch one extra row to know whether there is a next page
+		shardOrders, err := queryOrders(ctx, r.readShards[dbIndex].Reader(), filter, filter.Limit+1)
+		if err != nil {
+			return page, err
+		}
+		orders = append(orders, shardOrders...)
+	}
+
+	return buildOrderPage(mergeOrders(orders), filter.Limit), nil
+}
+
+// SearchOrders queries every shard concurrently and merges the results in newest-first order.
+func (r *orderRepository) SearchOrders(ctx context.Context, filter domain.OrderFilter) (page domain.OrderPage, err error) {
+	results := make([][]domain.Order, len(r.readShards))
+	errs := make([]error, len(r.readShards))
+
+	var wg sync.WaitGroup
+	for i, readShard := range r.readShards {
+		wg.Add(1)
+		go func(i int, db *sql.DB) {
+			defer wg.Done()
+			results[i], errs[i] = queryOrders(ctx, db, filter, filter.Limit+1)
+		}(i, readShard.Reader())
+	}
+	wg.Wait()
+
+	var orders []domain.Order
+	for i := range results {
+		if errs[i] != nil {
+			return page, errs[i]
+		}
+		orders = append(orders, results[i]...)
+	}
+
+	return buildOrderPage(mergeOrders(orders), filter.Limit), nil
+}
+
+// mergeOrders sorts orders read from several shards newest first and drops duplicates, which
+// exist briefly while resharding copies an order before deleting it from the old shard.
+func mergeOrders(orders []domain.Order) []domain.Order {
+	sort.Slice(orders, func(i, j int) bool { return domain.OrderSortsBefore(orders[i], orders[j]) })
+
+	merged := orders[:0]
+	for _, order := range orders {
+		if len(merged) > 0 && order.ID == merged[len(merged)-1].ID {
+			continue
+		}
+		merged = append(merged, order)
+	}
+	return merged
+}
+
+// buildOrderPage trims the orders to limit and sets the next cursor when more orders remain.
+func buildOrderPage(orders []domain.Order, limit int) (page domain.OrderPage) {
+	page.Orders = orders
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := page.Orders[limit-1]
+		page.NextCursor = domain.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	if page.Orders == nil {
+		page.Orders = []domain.Order{}
+	}
+	return page
+}
+
+// queryOrders returns up to limit orders of a shard matching the filter, newest first, with their product requests.
+func queryOrders(ctx context.Context, db *sql.DB, filter domain.OrderFilter, limit int) (orders []domain.Order, err error) {
+	query := `SELECT id, user_id, quantity, total, status, total_mark_up, total_discount, COALESCE(coupon_code, ''), coupon_discount, created_at FROM orders WHERE 1 = 1`
+	var args []interface{}
+
+	if filter.UserID != 0 {
+		query += ` AND user_id = ?`
+		args = append(args, filter.UserID)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.CreatedFrom != nil {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.CreatedFrom.UTC())
+	}
+	if filter.CreatedTo != nil {
+		query += ` AND created_at < ?`
+		args = append(args, filter.CreatedTo.UTC())
+	}
+	if filter.Cursor != nil {
+		query += ` AND (created_at < ? OR (created_at = ? AND id < ?))`
+		args = append(args, filter.Cursor.CreatedAt.UTC(), filter.Cursor.CreatedAt.UTC(), filter.Cursor.ID)
+	}
+
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		order := domain.Order{}
+		err = rows.Scan(&order.ID, &order.UserID, &order.Quantity, &order.Total, &order.Status, &order.TotalMarkUp, &order.TotalDiscount, &order.CouponCode, &order.CouponDiscount, &order.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = loadProductRequests(ctx, db, orders)
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// loadProductRequests fills the product requests of orders from the same shard in a single query, using either a database or a transaction.
+func loadProductRequests(ctx context.Context, db interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}, orders []domain.Order) (err error) {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(orders))
+	args := make([]interface{}, 0, len(orders))
+	for i, order := range orders {
+		index[order.ID] = i
+		args = append(args, order.ID)
+	}
+
+	query := `SELECT order_id, product_id, COALESCE(sku, ''), quantity, mark_up, discount, final_price, COALESCE(reservation_id, 0) FROM product_requests WHERE order_id IN (?` +
+		strings.Repeat(", ?", len(orders)-1) + `) ORDER BY id`
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID int
+		productRequest := domain.ProductRequest{}
+		err = rows.Scan(&orderID, &productRequest.ProductID, &productRequest.SKU, &productRequest.Quantity, &productRequest.MarkUp, &productRequest.Discount, &productRequest.FinalPrice, &productRequest.ReservationID)
+		if err != nil {
+			return err
+		}
+		i := index[orderID]
+		orders[i].ProductRequests = append(orders[i].ProductRequests, productRequest)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,119 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"order-service/domain"
+
+	"github.com/google/uuid"
+)
+
+// outboxClaimTimeout is how long a relay holds claimed messages before another relay may pick them up again.
+const outboxClaimTimeout = 30 * time.Second
+
+type OutboxRepository interface {
+	ClaimPendingMessages(ctx context.Context, shardIndex, limit int) (messages []domain.OutboxMessage, err error)
+	MarkMessageSent(ctx context.Context, shardIndex int, id int64) (err error)
+	MarkMessageFailed(ctx context.Context, shardIndex int, id int64, nextAttemptAt time.Time, lastError string) (err error)
+}
+
+type outboxRepository struct {
+	dbShards []*sql.DB
+}
+
+func NewOutboxRepository(dbShards []*sql.DB) OutboxRepository {
+	return &outboxRepository{dbShards}
+}
+
+// ClaimPendingMessages claims up to limit due messages on a shard so that concurrent relays do not publish the same rows.
+// Only the oldest pending messages of an order are claimed: a message waiting behind an earlier one of the same order
+// that is backed off or claimed by another relay stays pending, so events of an order are always published in order.
+func (r *outboxRepository) ClaimPendingMessages(ctx context.Context, shardIndex, limit int) (messages []domain.OutboxMessage, err error) {
+	db := r.dbShards[shardIndex]
+	claimID := uuid.New().String()
+
+	// The derived table is materialized because of its LIMIT, which lets MySQL read the table it updates
+	claimQuery := `
+		UPDATE order_outbox o
+		JOIN (
+			SELECT p.id FROM order_outbox p
+			WHERE p.status = ? AND p.next_attempt_at <= ?
+			AND NOT EXISTS (
+				SELECT 1 FROM order_outbox e
+				WHERE e.aggregate_id = p.aggregate_id AND e.status = ? AND e.id < p.id AND e.next_attempt_at > ?
+			)
+			ORDER BY p.id
+			LIMIT ?
+		) due ON due.id = o.id
+		SET o.claimed_by = ?, o.next_attempt_at = ?
+		WHERE o.status = ? AND o.next_attempt_at <= ?`
+	now := time.Now().UTC()
+	res, err := db.ExecContext(ctx, claimQuery,
+		domain.OutboxStatusPending, now, domain.OutboxStatusPending, now, limit,
+		claimID, now.Add(outboxClaimTimeout), domain.OutboxStatusPending, now)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed, err := res.RowsAffected()
+	if err != nil || claimed == 0 {
+		return nil, err
+	}
+
+	selectQuery := `
+		SELECT id, event_id, event_type, schema_version, aggregate_id, payload, status, attempts, created_at
+		FROM order_outbox WHERE claimed_by = ? AND status = ?
+		ORDER BY id`
+	rows, err := db.QueryContext(ctx, selectQuery, claimID, domain.OutboxStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		message := domain.OutboxMessage{}
+		err = rows.Scan(&message.ID, &message.EventID, &message.EventType, &message.Version, &message.AggregateID, &message.Payload, &message.Status, &message.Attempts, &message.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+func (r *outboxRepository) MarkMessageSent(ctx context.Context, shardIndex int, id int64) (err error) {
+	db := r.dbShards[shardIndex]
+
+	query := `UPDATE order_outbox SET status = ?, sent_at = ?, claimed_by = NULL WHERE id = ?`
+	_, err = db.ExecContext(ctx, query, domain.OutboxStatusSent, time.Now().UTC(), id)
+	return err
+}
+
+func (r *outboxRepository) MarkMessageFailed(ctx context.Context, shardIndex int, id int64, nextAttemptAt time.Time, lastError string) (err error) {
+	db := r.dbShards[shardIndex]
+
+	query := `UPDATE order_outbox SET attempts = attempts + 1, next_attempt_at = ?, last_error = ?, claimed_by = NULL WHERE id = ?`
+	_, err = db.ExecContext(ctx, query, nextAttemptAt.UTC(), lastError, id)
+	return err
+}
+
+// insertOutboxMessage wraps the order in an event envelope and stores it in the outbox within the caller's transaction.
+func insertOutboxMessage(ctx context.Context, tx *sql.Tx, eventType string, order domain.Order) (err error) {
+	event, err := domain.NewOrderEvent(eventType, order)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO order_outbox (event_id, event_type, schema_version, aggregate_id, payload, status, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, event.EventID, event.Type, event.SchemaVersion, event.AggregateID, payload, domain.OutboxStatusPending, event.OccurredAt, event.OccurredAt)
+	return err
+}
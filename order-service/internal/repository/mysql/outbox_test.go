@@ -0,0 +1,114 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"order-service/domain"
+	"order-service/internal/testutil"
+)
+
+// addOutboxEvent records an event of the order in the outbox, as the order repository does with its changes.
+func addOutboxEvent(t *testing.T, db *sql.DB, eventType string, orderID int) {
+	t.Helper()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+	err = insertOutboxMessage(context.Background(), tx, eventType, domain.Order{ID: orderID})
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("inserting %s of order %d: %v", eventType, orderID, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("committing %s of order %d: %v", eventType, orderID, err)
+	}
+}
+
+// claimEvents claims the due messages and describes them as "<event type> <order ID>" in claim order.
+func claimEvents(t *testing.T, repo OutboxRepository) (events []string, messages []domain.OutboxMessage) {
+	t.Helper()
+
+	messages, err := repo.ClaimPendingMessages(context.Background(), 0, 100)
+	if err != nil {
+		t.Fatalf("ClaimPendingMessages: %v", err)
+	}
+	for _, message := range messages {
+		events = append(events, message.EventType+" "+strconv.Itoa(message.AggregateID))
+	}
+	return events, messages
+}
+
+func TestClaimWaitsForFailedEventOfTheSameOrder(t *testing.T) {
+	db := testutil.MySQL(t)
+	repo := NewOutboxRepository([]*sql.DB{db})
+	ctx := context.Background()
+
+	addOutboxEvent(t, db, domain.OrderEventCreated, 1)
+	addOutboxEvent(t, db, domain.OrderEventCreated, 2)
+
+	events, messages := claimEvents(t, repo)
+	if want := []string{"order.created 1", "order.created 2"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("claimed %v, want %v", events, want)
+	}
+
+	// Publishing the event of order 1 fails and it is backed off, order 2 goes out
+	err := repo.MarkMessageFailed(ctx, 0, messages[0].ID, time.Now().Add(time.Hour), "broker down")
+	if err != nil {
+		t.Fatalf("MarkMessageFailed: %v", err)
+	}
+	err = repo.MarkMessageSent(ctx, 0, messages[1].ID)
+	if err != nil {
+		t.Fatalf("MarkMessageSent: %v", err)
+	}
+
+	// Newer events of both orders arrive while order 1 is backed off
+	addOutboxEvent(t, db, domain.OrderEventCancelled, 1)
+	addOutboxEvent(t, db, domain.OrderEventUpdated, 2)
+
+	events, messages = claimEvents(t, repo)
+	if want := []string{"order.updated 2"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("claimed %v while order 1 is backed off, want %v", events, want)
+	}
+	err = repo.MarkMessageSent(ctx, 0, messages[0].ID)
+	if err != nil {
+		t.Fatalf("MarkMessageSent: %v", err)
+	}
+
+	// Once the backoff is over the events of order 1 go out in the order they happened
+	_, err = db.Exec(`UPDATE order_outbox SET next_attempt_at = ? WHERE status = ?`, time.Now().UTC().Add(-time.Second), domain.OutboxStatusPending)
+	if err != nil {
+		t.Fatalf("ending backoff: %v", err)
+	}
+
+	events, _ = claimEvents(t, repo)
+	if want := []string{"order.created 1", "order.cancelled 1"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("claimed %v after the backoff, want %v", events, want)
+	}
+}
+
+func TestClaimSkipsOrdersClaimedByAnotherRelay(t *testing.T) {
+	db := testutil.MySQL(t)
+	repo := NewOutboxRepository([]*sql.DB{db})
+
+	addOutboxEvent(t, db, domain.OrderEventCreated, 1)
+	events, _ := claimEvents(t, repo)
+	if len(events) != 1 {
+		t.Fatalf("first relay claimed %v", events)
+	}
+
+	// A second relay must not publish a later event of order 1 while the first one still holds its earlier event
+	addOutboxEvent(t, db, domain.OrderEventCancelled, 1)
+	addOutboxEvent(t, db, domain.OrderEventCreated, 2)
+
+	events, _ = claimEvents(t, repo)
+	if want := []string{"order.created 2"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("second relay claimed %v, want %v", events, want)
+	}
+}
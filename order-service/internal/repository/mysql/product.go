@@ -6,67 +6,104 @@ import (
 	"fmt"
 	"order-service/domain"
 	"order-service/internal/sharding"
+	"time"
 )
 
 type OrderRepository interface {
 	GetOrderByID(ctx context.Context, id int) (order domain.Order, err error)
-	CreateOrder(ctx context.Context, req domain.Order) (order domain.Order, err error)
-	UpdateOrder(ctx context.Context, req domain.Order) (order domain.Order, err error)
+	CreateOrder(ctx context.Context, req domain.Order, eventType string) (order domain.Order, err error)
+	UpdateOrder(ctx context.Context, req domain.Order, eventType string) (order domain.Order, err error)
 	DeleteOrder(ctx context.Context, id, userID int) (err error)
-	UpdateOrderStatus(ctx context.Context, id, userID int, status string) (err error)
+	UpdateOrderStatus(ctx context.Context, req domain.Order, status string, changedBy int, eventType string) (order domain.Order, err error)
+	GetOrdersByUser(ctx context.Context, filter domain.OrderFilter) (page domain.OrderPage, err error)
+	SearchOrders(ctx context.Context, filter domain.OrderFilter) (page domain.OrderPage, err error)
 }
 
 type orderRepository struct {
-	dbShards []*sql.DB
-	shard    *sharding.ShardRouter
+	dbShards   []*sql.DB
+	readShards []ShardReader
+	shard      *sharding.ShardRouter
+	idGen      *sharding.OrderIDGenerator
 }
 
-func NewOrderRepository(dbShards []*sql.DB, shard *sharding.ShardRouter) OrderRepository {
-	return &orderRepository{dbShards, shard}
+func NewOrderRepository(dbShards []*sql.DB, readShards []ShardReader, shard *sharding.ShardRouter, idGen *sharding.OrderIDGenerator) OrderRepository {
+	return &orderRepository{dbShards, readShards, shard, idGen}
 }
 
+// GetOrderByID reads the order and its product requests from the shard encoded in the order ID,
+// following the order if resharding moved it to another shard.
 func (r *orderRepository) GetOrderByID(ctx context.Context, id int) (order domain.Order, err error) {
-	orderQuery := `SELECT id, user_id, quantity, total, status, total_mark_up, total_discount FROM orders WHERE id = ?`
-	productRequestQuery := `SELECT product_id, quantity, mark_up, discount, final_price FROM product_requests WHERE order_id = ?`
-
-	// Loop semua database shard
-	for _, db := range r.dbShards {
-		err = db.QueryRowContext(ctx, orderQuery, id).Scan(&order.ID, &order.UserID, &order.Quantity, &order.Total, &order.Status, &order.TotalMarkUp, &order.TotalDiscount)
-		if err == nil {
-			break
-		} else if err == sql.ErrNoRows {
+	dbIndex, err := r.shard.GetOrderShard(id)
+	if err != nil {
+		// IDs that do not map to a shard cannot exist
+		return order, sql.ErrNoRows
+	}
+
+	for hops := 0; hops < r.shard.ShardCount; hops++ {
+		db := r.dbShards[dbIndex]
+
+		orderQuery := `SELECT id, user_id, quantity, total, status, total_mark_up, total_discount, COALESCE(coupon_code, ''), coupon_discount, created_at FROM orders WHERE id = ?`
+		err = db.QueryRowContext(ctx, orderQuery, id).Scan(&order.ID, &order.UserID, &order.Quantity, &order.Total, &order.Status, &order.TotalMarkUp, &order.TotalDiscount,
+			&order.CouponCode, &order.CouponDiscount, &order.CreatedAt)
+		if err == sql.ErrNoRows {
+			dbIndex, err = r.movedTo(ctx, dbIndex, id)
+			if err != nil {
+				return order, err
+			}
 			continue
-		} else {
+		}
+		if err != nil {
 			return order, err
 		}
+
+		orders := []domain.Order{order}
+		err = loadProductRequests(ctx, db, orders)
+		if err != nil {
+			return order, err
+		}
+
+		return orders[0], nil
 	}
 
-	if order.ID == 0 {
-		return order, sql.ErrNoRows
+	return order, sql.ErrNoRows
+}
+
+// orderShard returns the shard currently holding the order.
+func (r *orderRepository) orderShard(ctx context.Context, id int) (dbIndex int, err error) {
+	dbIndex, err = r.shard.GetOrderShard(id)
+	if err != nil {
+		return 0, err
 	}
 
-	for _, db := range r.dbShards {
-		rows, err := db.QueryContext(ctx, productRequestQuery, id)
-		if err != nil {
-			continue
+	for hops := 0; hops < r.shard.ShardCount; hops++ {
+		next, err := r.movedTo(ctx, dbIndex, id)
+		if err == sql.ErrNoRows {
+			return dbIndex, nil
 		}
-		defer rows.Close()
-
-		for rows.Next() {
-			productRequest := domain.ProductRequest{}
-			err := rows.Scan(&productRequest.ProductID, &productRequest.Quantity, &productRequest.MarkUp, &productRequest.Discount, &productRequest.FinalPrice)
-			if err != nil {
-				return order, err
-			}
-			order.ProductRequests = append(order.ProductRequests, productRequest)
+		if err != nil {
+			return 0, err
 		}
-		break
+		dbIndex = next
 	}
 
-	return order, nil
+	return dbIndex, nil
+}
+
+// movedTo returns the shard the order was moved to from dbIndex, or sql.ErrNoRows if it was not moved.
+func (r *orderRepository) movedTo(ctx context.Context, dbIndex, id int) (toShard int, err error) {
+	query := `SELECT to_shard FROM order_moves WHERE order_id = ?`
+	err = r.dbShards[dbIndex].QueryRowContext(ctx, query, id).Scan(&toShard)
+	if err != nil {
+		return 0, err
+	}
+	if toShard < 0 || toShard >= len(r.dbShards) {
+		return 0, fmt.Errorf("order %d moved to unknown shard %d", id, toShard)
+	}
+	return toShard, nil
 }
 
-func (r *orderRepository) CreateOrder(ctx context.Context, req domain.Order) (order domain.Order, err error) {
+// CreateOrder inserts the order, its product requests and the order event into the outbox in one shard transaction.
+func (r *orderRepository) CreateOrder(ctx context.Context, req domain.Order, eventType string) (order domain.Order, err error) {
 	dbIndex := r.shard.GetShard(req.UserID)
 	db := r.dbShards[dbIndex]
 
@@ -75,72 +112,82 @@ func (r *orderRepository) CreateOrder(ctx context.Context, req domain.Order) (or
 	if err != nil {
 		return order, err
 	}
-	fmt.Println(req.UserID)
-	// Insert order
-	orderQuery := `INSERT INTO orders (user_id, quantity, total, status, total_mark_up, total_discount, idempotent_key) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	res, err := tx.ExecContext(ctx, orderQuery, req.UserID, req.Quantity, req.Total, req.Status, req.TotalMarkUp, req.TotalDiscount, req.IdempotentKey)
+
+	req.ID, err = r.idGen.NextID(dbIndex)
 	if err != nil {
-		fmt.Println(err)
 		tx.Rollback()
 		return order, err
 	}
 
-	orderID, err := res.LastInsertId()
+	order, err = insertOrder(ctx, tx, req)
 	if err != nil {
 		tx.Rollback()
 		return order, err
 	}
 
-	//// Insert product requests
-	//productQuery := `
-	//	INSERT INTO product_requests (order_id, product_id, quantity, mark_up, discount, final_price)
-	//	VALUES (?, ?, ?, ?, ?, ?)`
-	//for _, product := range order.ProductRequests {
-	//	_, err := tx.Exec(productQuery, orderID, product.ProductID, product.Quantity, product.MarkUp, product.Discount, product.FinalPrice)
-	//	if err != nil {
-	//		tx.Rollback()
-	//		return nil, err
-	//	}
-	//}
+	// Record the event in the outbox so the relay publishes it once the order is committed
+	err = insertOutboxMessage(ctx, tx, eventType, order)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
+	// Commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return order, err
+	}
+
+	return order, nil
+}
+
+// insertOrder inserts the order with its pre-generated ID and its product requests within the caller's transaction.
+func insertOrder(ctx context.Context, tx *sql.Tx, req domain.Order) (order domain.Order, err error) {
+	// Insert order
+	if req.CreatedAt.IsZero() {
+		req.CreatedAt = time.Now().UTC()
+	}
+	orderQuery := `INSERT INTO orders (id, user_id, quantity, total, status, total_mark_up, total_discount, coupon_code, coupon_discount, idempotent_key, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, orderQuery, req.ID, req.UserID, req.Quantity, req.Total, req.Status, req.TotalMarkUp, req.TotalDiscount,
+		nullString(req.CouponCode), req.CouponDiscount, req.IdempotentKey, req.CreatedAt.UTC())
+	if err != nil {
+		return order, err
+	}
+	if len(req.ProductRequests) == 0 {
+		return req, nil
+	}
 
 	// Insert product requests with batch
 	productQuery := `
-		INSERT INTO product_requests (order_id, product_id, quantity, mark_up, discount, final_price)
+		INSERT INTO product_requests (order_id, product_id, sku, quantity, mark_up, discount, final_price, reservation_id)
 		VALUES `
 
 	// Build the query
 	var values []interface{}
 	for _, product := range req.ProductRequests {
-		productQuery += "(?, ?, ?, ?, ?, ?),"
-		values = append(values, orderID, product.ProductID, product.Quantity, product.MarkUp, product.Discount, product.FinalPrice)
+		productQuery += "(?, ?, ?, ?, ?, ?, ?, ?),"
+		values = append(values, req.ID, product.ProductID, nullString(product.SKU), product.Quantity, product.MarkUp, product.Discount, product.FinalPrice, nullInt64(product.ReservationID))
 	}
 
 	// Remove the trailing comma
 	productQuery = productQuery[:len(productQuery)-1]
 
-	fmt.Println(productQuery)
-	fmt.Println(orderID)
 	// Execute the query batch insert
 	_, err = tx.ExecContext(ctx, productQuery, values...)
 	if err != nil {
-		fmt.Println("vf")
-		fmt.Println(err)
-		tx.Rollback()
 		return order, err
 	}
 
-	// Commit the transaction
-	err = tx.Commit()
+	return req, nil
+}
+
+// UpdateOrder updates the order, replaces its product requests and records the order event in the outbox in one shard transaction.
+func (r *orderRepository) UpdateOrder(ctx context.Context, req domain.Order, eventType string) (order domain.Order, err error) {
+	dbIndex, err := r.orderShard(ctx, req.ID)
 	if err != nil {
 		return order, err
 	}
-
-	order.ID = int(orderID)
-	return order, nil
-}
-
-func (r *orderRepository) UpdateOrder(ctx context.Context, req domain.Order) (order domain.Order, err error) {
-	dbIndex := r.shard.GetShard(req.UserID)
 	db := r.dbShards[dbIndex]
 
 	// Start a transaction
@@ -167,27 +214,37 @@ func (r *orderRepository) UpdateOrder(ctx context.Context, req domain.Order) (or
 
 	// Insert product requests
 	productQuery := `
-		INSERT INTO product_requests (order_id, product_id, quantity, mark_up, discount, final_price)
-		VALUES (?, ?, ?, ?, ?, ?)`
+		INSERT INTO product_requests (order_id, product_id, sku, quantity, mark_up, discount, final_price, reservation_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 	for _, product := range req.ProductRequests {
-		_, err := tx.ExecContext(ctx, productQuery, req.ID, product.ProductID, product.Quantity, product.MarkUp, product.Discount, product.FinalPrice)
+		_, err := tx.ExecContext(ctx, productQuery, req.ID, product.ProductID, nullString(product.SKU), product.Quantity, product.MarkUp, product.Discount, product.FinalPrice, nullInt64(product.ReservationID))
 		if err != nil {
 			tx.Rollback()
 			return order, err
 		}
 	}
 
+	// Record the event in the outbox so the relay publishes it once the order is committed
+	err = insertOutboxMessage(ctx, tx, eventType, req)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
 	// Commit the transaction
 	err = tx.Commit()
 	if err != nil {
 		return order, err
 	}
 
-	return order, nil
+	return req, nil
 }
 
 func (r *orderRepository) DeleteOrder(ctx context.Context, id, userID int) (err error) {
-	dbIndex := r.shard.GetShard(userID)
+	dbIndex, err := r.orderShard(ctx, id)
+	if err != nil {
+		return err
+	}
 	db := r.dbShards[dbIndex]
 
 	// Start a transaction
@@ -221,15 +278,88 @@ func (r *orderRepository) DeleteOrder(ctx context.Context, id, userID int) (err
 	return nil
 }
 
-func (r *orderRepository) UpdateOrderStatus(ctx context.Context, id, userID int, status string) (err error) {
-	dbIndex := r.shard.GetShard(userID)
+// UpdateOrderStatus moves the order from its current status to status, recording the change in
+// order_status_history and the order event in the outbox in one shard transaction. The update only
+// applies if the order is still in the status it was read with.
+func (r *orderRepository) UpdateOrderStatus(ctx context.Context, req domain.Order, status string, changedBy int, eventType string) (order domain.Order, err error) {
+	dbIndex, err := r.orderShard(ctx, req.ID)
+	if err != nil {
+		return order, err
+	}
 	db := r.dbShards[dbIndex]
 
-	query := `UPDATE orders SET status = ? WHERE id = ?`
-	_, err = db.ExecContext(ctx, query, status, id)
+	// Start a transaction
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return order, err
 	}
 
-	return nil
+	query := `UPDATE orders SET status = ? WHERE id = ? AND status = ?`
+	res, err := tx.ExecContext(ctx, query, status, req.ID, req.Status)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
+	if updated == 0 {
+		tx.Rollback()
+		return order, domain.ErrOrderStatusConflict
+	}
+
+	err = insertStatusHistory(ctx, tx, req.ID, req.Status, status, changedBy)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
+	order = req
+	order.Status = status
+
+	// Record the event in the outbox so the relay publishes it once the status change is committed
+	err = insertOutboxMessage(ctx, tx, eventType, order)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
+	// Commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return order, err
+	}
+
+	return order, nil
+}
+
+// insertStatusHistory records a status change of an order within the caller's transaction.
+func insertStatusHistory(ctx context.Context, tx *sql.Tx, orderID int, from, to string, changedBy int) (err error) {
+	return insertStatusHistoryEntry(ctx, tx, domain.OrderStatusHistory{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		ChangedBy:  changedBy,
+		ChangedAt:  time.Now().UTC(),
+	})
+}
+
+func insertStatusHistoryEntry(ctx context.Context, tx *sql.Tx, entry domain.OrderStatusHistory) (err error) {
+	query := `INSERT INTO order_status_history (order_id, from_status, to_status, changed_by, changed_at) VALUES (?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, entry.OrderID, entry.FromStatus, entry.ToStatus, entry.ChangedBy, entry.ChangedAt.UTC())
+	return err
+}
+
+// nullString stores an empty string as NULL.
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// nullInt64 stores zero as NULL.
+func nullInt64(value int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: value, Valid: value != 0}
 }
@@ -0,0 +1,180 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/sharding"
+)
+
+// fakeShard is an in-memory shard database that answers the order lookup queries and counts them.
+type fakeShard struct {
+	mu      sync.Mutex
+	queries int
+	orders  map[int64]bool
+	moves   map[int64]int64
+}
+
+func (s *fakeShard) Connect(ctx context.Context) (driver.Conn, error) { return fakeConn{s}, nil }
+func (s *fakeShard) Driver() driver.Driver                            { return nil }
+
+func (s *fakeShard) queryCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries
+}
+
+type fakeConn struct {
+	shard *fakeShard
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	s := c.shard
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries++
+
+	id := args[0].Value.(int64)
+	switch {
+	case strings.HasPrefix(query, "SELECT id, user_id"):
+		if !s.orders[id] {
+			return &fakeRows{}, nil
+		}
+		return &fakeRows{
+			columns: []string{"id", "user_id", "quantity", "total", "status", "total_mark_up", "total_discount", "coupon_code", "coupon_discount", "created_at"},
+			values:  [][]driver.Value{{id, int64(7), int64(2), 20.0, "created", 0.0, 0.0, "", 0.0, time.Now()}},
+		}, nil
+	case strings.HasPrefix(query, "SELECT order_id, product_id"):
+		return &fakeRows{
+			columns: []string{"order_id", "product_id", "sku", "quantity", "mark_up", "discount", "final_price", "reservation_id"},
+			values:  [][]driver.Value{{id, int64(3), "", int64(2), 0.0, 0.0, 20.0, int64(9)}},
+		}, nil
+	case strings.HasPrefix(query, "SELECT to_shard"):
+		toShard, ok := s.moves[id]
+		if !ok {
+			return &fakeRows{}, nil
+		}
+		return &fakeRows{columns: []string{"to_shard"}, values: [][]driver.Value{{toShard}}}, nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// newShardedRepository builds an order repository over count fake shards.
+func newShardedRepository(t *testing.T, count int) (OrderRepository, []*fakeShard, *sharding.OrderIDGenerator) {
+	t.Helper()
+
+	shards := make([]*fakeShard, count)
+	dbs := make([]*sql.DB, count)
+	for i := range shards {
+		shards[i] = &fakeShard{orders: map[int64]bool{}, moves: map[int64]int64{}}
+		dbs[i] = sql.OpenDB(shards[i])
+		t.Cleanup(func() { dbs[i].Close() })
+	}
+
+	idGen, err := sharding.NewOrderIDGenerator(0)
+	if err != nil {
+		t.Fatalf("NewOrderIDGenerator: %v", err)
+	}
+	router := sharding.NewShardRouterWithPlacement(count, sharding.ModuloPlacement{Count: count}, nil)
+	return NewOrderRepository(dbs, nil, router, idGen), shards, idGen
+}
+
+func TestGetOrderByIDQueriesOnlyTheOrderShard(t *testing.T) {
+	repo, shards, idGen := newShardedRepository(t, 3)
+
+	id, err := idGen.NextID(1)
+	if err != nil {
+		t.Fatalf("NextID: %v", err)
+	}
+	shards[1].orders[int64(id)] = true
+
+	order, err := repo.GetOrderByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetOrderByID: %v", err)
+	}
+	if order.ID != id || len(order.ProductRequests) != 1 {
+		t.Errorf("got order %d with %d product requests, want order %d with 1", order.ID, len(order.ProductRequests), id)
+	} else if order.ProductRequests[0].ReservationID != 9 {
+		t.Errorf("product request has reservation %d, want 9", order.ProductRequests[0].ReservationID)
+	}
+
+	for i, shard := range shards {
+		want := 0
+		if i == 1 {
+			want = 2 // the order and its product requests
+		}
+		if got := shard.queryCount(); got != want {
+			t.Errorf("shard %d got %d queries, want %d", i, got, want)
+		}
+	}
+}
+
+func TestGetOrderByIDFollowsMovedOrder(t *testing.T) {
+	repo, shards, idGen := newShardedRepository(t, 3)
+
+	id, err := idGen.NextID(0)
+	if err != nil {
+		t.Fatalf("NextID: %v", err)
+	}
+	shards[0].moves[int64(id)] = 2
+	shards[2].orders[int64(id)] = true
+
+	_, err = repo.GetOrderByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetOrderByID: %v", err)
+	}
+
+	// The shard the ID encodes answers the move, the shard it moved to the order
+	for i, want := range []int{2, 0, 2} {
+		if got := shards[i].queryCount(); got != want {
+			t.Errorf("shard %d got %d queries, want %d", i, got, want)
+		}
+	}
+}
+
+func TestGetOrderByIDUnknownShard(t *testing.T) {
+	repo, shards, idGen := newShardedRepository(t, 2)
+
+	id, err := idGen.NextID(5)
+	if err != nil {
+		t.Fatalf("NextID: %v", err)
+	}
+
+	_, err = repo.GetOrderByID(context.Background(), id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetOrderByID of an order on shard 5: got %v, want sql.ErrNoRows", err)
+	}
+	for i, shard := range shards {
+		if got := shard.queryCount(); got != 0 {
+			t.Errorf("shard %d got %d queries, want none", i, got)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"order-service/domain"
+)
+
+type ReshardRepository interface {
+	GetUserIDs(ctx context.Context, shardIndex, afterUserID, limit int) (userIDs []int, err error)
+	MoveUserOrders(ctx context.Context, userID, from, to, batchSize int) (moved int, err error)
+}
+
+type reshardRepository struct {
+	dbShards []*sql.DB
+}
+
+func NewReshardRepository(dbShards []*sql.DB) ReshardRepository {
+	return &reshardRepository{dbShards}
+}
+
+// GetUserIDs returns the IDs of users with orders on a shard, in ascending order after afterUserID.
+func (r *reshardRepository) GetUserIDs(ctx context.Context, shardIndex, afterUserID, limit int) (userIDs []int, err error) {
+	query := `SELECT DISTINCT user_id FROM orders WHERE user_id > ? ORDER BY user_id LIMIT ?`
+	rows, err := r.dbShards[shardIndex].QueryContext(ctx, query, afterUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int
+		if err = rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// MoveUserOrders moves the orders of a user with their product requests and status history from one
+// shard to another, batch by batch. Each batch is copied and committed on the target first, then deleted
+// from the source, which keeps an order_moves row so lookups by the shard encoded in the ID are redirected.
+// A batch interrupted between the two commits is picked up again by the next run.
+func (r *reshardRepository) MoveUserOrders(ctx context.Context, userID, from, to, batchSize int) (moved int, err error) {
+	for {
+		n, err := r.moveOrderBatch(ctx, userID, from, to, batchSize)
+		moved += n
+		if err != nil || n < batchSize {
+			return moved, err
+		}
+	}
+}
+
+func (r *reshardRepository) moveOrderBatch(ctx context.Context, userID, from, to, batchSize int) (moved int, err error) {
+	source, err := r.dbShards[from].BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Rollback()
+
+	// Lock the orders so status changes wait until they are gone from the source shard
+	query := `
+		SELECT id, user_id, quantity, total, status, total_mark_up, total_discount, COALESCE(coupon_code, ''), coupon_discount, idempotent_key, created_at
+		FROM orders WHERE user_id = ? ORDER BY id LIMIT ? FOR UPDATE`
+	rows, err := source.QueryContext(ctx, query, userID, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var orders []domain.Order
+	for rows.Next() {
+		order := domain.Order{}
+		err = rows.Scan(&order.ID, &order.UserID, &order.Quantity, &order.Total, &order.Status, &order.TotalMarkUp, &order.TotalDiscount, &order.CouponCode, &order.CouponDiscount,
+			&order.IdempotentKey, &order.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil || len(orders) == 0 {
+		return 0, err
+	}
+
+	ids := make([]interface{}, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+	}
+	in := "(?" + strings.Repeat(", ?", len(ids)-1) + ")"
+
+	history, err := getStatusHistory(ctx, source, in, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	err = loadProductRequests(ctx, source, orders)
+	if err != nil {
+		return 0, err
+	}
+
+	err = r.copyOrders(ctx, to, orders, history, in, ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy orders of user %d to shard %d: %w", userID, to, err)
+	}
+
+	movedAt := time.Now().UTC()
+	for _, order := range orders {
+		_, err = source.ExecContext(ctx, `INSERT INTO order_moves (order_id, user_id, to_shard, moved_at) VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE to_shard = VALUES(to_shard), moved_at = VALUES(moved_at)`, order.ID, order.UserID, to, movedAt)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for _, table := range []string{"order_status_history", "product_requests"} {
+		_, err = source.ExecContext(ctx, "DELETE FROM "+table+" WHERE order_id IN "+in, ids...)
+		if err != nil {
+			return 0, err
+		}
+	}
+	_, err = source.ExecContext(ctx, "DELETE FROM orders WHERE id IN "+in, ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	err = source.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(orders), nil
+}
+
+// copyOrders inserts the orders that are not on the target shard yet, keeping their IDs, in one transaction.
+func (r *reshardRepository) copyOrders(ctx context.Context, to int, orders []domain.Order, history []domain.OrderStatusHistory, in string, ids []interface{}) (err error) {
+	target, err := r.dbShards[to].BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer target.Rollback()
+
+	// Orders copied by an interrupted earlier run are already there
+	existing := map[int]bool{}
+	rows, err := target.QueryContext(ctx, "SELECT id FROM orders WHERE id IN "+in, ids...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[id] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		if existing[order.ID] {
+			continue
+		}
+
+		// A clash on idempotent_key fails the move instead of dropping the order
+		_, err = insertOrder(ctx, target, order)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range history {
+		if existing[entry.OrderID] {
+			continue
+		}
+
+		err = insertStatusHistoryEntry(ctx, target, entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return target.Commit()
+}
+
+// getStatusHistory returns the status history of the orders, oldest first.
+func getStatusHistory(ctx context.Context, tx *sql.Tx, in string, ids []interface{}) (history []domain.OrderStatusHistory, err error) {
+	query := `SELECT order_id, from_status, to_status, changed_by, changed_at FROM order_status_history WHERE order_id IN ` + in + ` ORDER BY id`
+	rows, err := tx.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := domain.OrderStatusHistory{}
+		err = rows.Scan(&entry.OrderID, &entry.FromStatus, &entry.ToStatus, &entry.ChangedBy, &entry.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
@@ -0,0 +1,250 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"order-service/domain"
+	"order-service/internal/sharding"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlErrDuplicateEntry is the MySQL error number for a duplicate unique key
+const mysqlErrDuplicateEntry = 1062
+
+type SagaRepository interface {
+	CreateSaga(ctx context.Context, saga domain.OrderSaga) (created domain.OrderSaga, err error)
+	UpdateSaga(ctx context.Context, saga domain.OrderSaga, fromStatus string) (err error)
+	CompleteSaga(ctx context.Context, saga domain.OrderSaga, req domain.Order) (order domain.Order, err error)
+	ClaimStaleSagas(ctx context.Context, owner string, now, leaseExpiresAt time.Time, limit int) (sagas []domain.OrderSaga, err error)
+	GetSaga(ctx context.Context, id string) (saga domain.OrderSaga, err error)
+}
+
+const sagaColumns = `id, user_id, COALESCE(order_id, 0), status, items, COALESCE(coupon_code, ''), coupon_discount, idempotent_key, COALESCE(error, ''),
+	COALESCE(lease_owner, ''), COALESCE(lease_expires_at, created_at), created_at, updated_at`
+
+type sagaRepository struct {
+	dbShards []*sql.DB
+	shard    *sharding.ShardRouter
+	idGen    *sharding.OrderIDGenerator
+}
+
+func NewSagaRepository(dbShards []*sql.DB, shard *sharding.ShardRouter, idGen *sharding.OrderIDGenerator) SagaRepository {
+	return &sagaRepository{dbShards, shard, idGen}
+}
+
+// CreateSaga stores a new saga on the shard of its user, leased to its owner, and returns it with that shard.
+func (r *sagaRepository) CreateSaga(ctx context.Context, saga domain.OrderSaga) (created domain.OrderSaga, err error) {
+	saga.ShardIndex = r.shard.GetShard(saga.UserID)
+	db := r.dbShards[saga.ShardIndex]
+
+	items, err := json.Marshal(saga.Items)
+	if err != nil {
+		return created, err
+	}
+
+	query := `INSERT INTO order_sagas (id, user_id, status, items, coupon_code, idempotent_key, lease_owner, lease_expires_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = db.ExecContext(ctx, query, saga.ID, saga.UserID, saga.Status, items, nullString(saga.CouponCode), saga.IdempotentKey, saga.LeaseOwner,
+		saga.LeaseExpiresAt.UTC(), saga.CreatedAt.UTC(), saga.UpdatedAt.UTC())
+	if err != nil {
+		return created, err
+	}
+
+	return saga, nil
+}
+
+// UpdateSaga persists the current step, items, coupon discount, error and lease of a saga that is still at fromStatus
+// and leased to its owner. Otherwise another worker took the saga over and ErrSagaConflict is returned.
+func (r *sagaRepository) UpdateSaga(ctx context.Context, saga domain.OrderSaga, fromStatus string) (err error) {
+	db := r.dbShards[saga.ShardIndex]
+	return updateSaga(ctx, db, saga, fromStatus)
+}
+
+// CompleteSaga marks the saga completed, inserts the order with its initial status history and its outbox event in one shard
+// transaction, so a resumed saga can never create the order twice. A saga another worker took over is reported as ErrSagaConflict.
+// When another saga already created an order with the same idempotent key, that order is returned with ErrDuplicateOrder.
+func (r *sagaRepository) CompleteSaga(ctx context.Context, saga domain.OrderSaga, req domain.Order) (order domain.Order, err error) {
+	dbIndex := saga.ShardIndex
+	db := r.dbShards[dbIndex]
+
+	// The order ID carries the shard so the order can later be found without the user ID
+	req.ID, err = r.idGen.NextID(dbIndex)
+	if err != nil {
+		return order, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return order, err
+	}
+
+	// The saga row stays locked until commit, so a second worker completing it waits and then fails the status check
+	fromStatus := saga.Status
+	saga.OrderID = req.ID
+	saga.Status = domain.SagaStatusCompleted
+	saga.UpdatedAt = time.Now()
+	err = updateSaga(ctx, tx, saga, fromStatus)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
+	order, err = insertOrder(ctx, tx, req)
+	if err != nil {
+		tx.Rollback()
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+			return r.getOrderByIdempotentKey(ctx, db, req.IdempotentKey)
+		}
+		return order, err
+	}
+
+	err = insertStatusHistory(ctx, tx, order.ID, "", order.Status, saga.UserID)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
+	err = insertOutboxMessage(ctx, tx, domain.OrderEventCreated, order)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return order, err
+	}
+
+	return order, nil
+}
+
+// getOrderByIdempotentKey returns the order created under the idempotent key, with ErrDuplicateOrder.
+func (r *sagaRepository) getOrderByIdempotentKey(ctx context.Context, db *sql.DB, idempotentKey string) (order domain.Order, err error) {
+	query := `SELECT id, user_id, quantity, total, status, total_mark_up, total_discount, COALESCE(coupon_code, ''), coupon_discount, created_at
+		FROM orders WHERE idempotent_key = ?`
+	err = db.QueryRowContext(ctx, query, idempotentKey).Scan(&order.ID, &order.UserID, &order.Quantity, &order.Total, &order.Status, &order.TotalMarkUp,
+		&order.TotalDiscount, &order.CouponCode, &order.CouponDiscount, &order.CreatedAt)
+	if err != nil {
+		return order, err
+	}
+
+	orders := []domain.Order{order}
+	err = loadProductRequests(ctx, db, orders)
+	if err != nil {
+		return order, err
+	}
+
+	return orders[0], domain.ErrDuplicateOrder
+}
+
+// ClaimStaleSagas leases unfinished sagas whose lease ran out, or that never had one, to owner until leaseExpiresAt
+// and returns them. The claim is a single UPDATE per shard, so two workers never claim the same saga.
+func (r *sagaRepository) ClaimStaleSagas(ctx context.Context, owner string, now, leaseExpiresAt time.Time, limit int) (sagas []domain.OrderSaga, err error) {
+	unfinished := []interface{}{domain.SagaStatusStarted, domain.SagaStatusStockReserved, domain.SagaStatusPriceLocked,
+		domain.SagaStatusCouponRedeemed, domain.SagaStatusCompensating}
+
+	claim := `
+		UPDATE order_sagas SET lease_owner = ?, lease_expires_at = ?
+		WHERE status IN (?, ?, ?, ?, ?) AND (lease_expires_at IS NULL OR lease_expires_at < ?)
+		ORDER BY updated_at
+		LIMIT ?`
+	query := `
+		SELECT ` + sagaColumns + `
+		FROM order_sagas
+		WHERE lease_owner = ? AND status IN (?, ?, ?, ?, ?)
+		ORDER BY updated_at`
+
+	for shardIndex, db := range r.dbShards {
+		args := append([]interface{}{owner, leaseExpiresAt.UTC()}, unfinished...)
+		_, err = db.ExecContext(ctx, claim, append(args, now.UTC(), limit)...)
+		if err != nil {
+			return sagas, err
+		}
+
+		rows, err := db.QueryContext(ctx, query, append([]interface{}{owner}, unfinished...)...)
+		if err != nil {
+			return sagas, err
+		}
+
+		for rows.Next() {
+			saga, err := scanSaga(rows, shardIndex)
+			if err != nil {
+				rows.Close()
+				return sagas, err
+			}
+			sagas = append(sagas, saga)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return sagas, err
+		}
+	}
+
+	return sagas, nil
+}
+
+// GetSaga looks the saga up on every shard, as its shard depends on the placement of its user when it started.
+// An unknown saga is reported as sql.ErrNoRows.
+func (r *sagaRepository) GetSaga(ctx context.Context, id string) (saga domain.OrderSaga, err error) {
+	query := `SELECT ` + sagaColumns + ` FROM order_sagas WHERE id = ?`
+	for shardIndex, db := range r.dbShards {
+		saga, err = scanSaga(db.QueryRowContext(ctx, query, id), shardIndex)
+		if err != sql.ErrNoRows {
+			return saga, err
+		}
+	}
+	return saga, sql.ErrNoRows
+}
+
+// scanSaga scans a row of sagaColumns of the saga stored on shardIndex.
+func scanSaga(row interface{ Scan(dest ...any) error }, shardIndex int) (saga domain.OrderSaga, err error) {
+	saga.ShardIndex = shardIndex
+	var items []byte
+	err = row.Scan(&saga.ID, &saga.UserID, &saga.OrderID, &saga.Status, &items, &saga.CouponCode, &saga.CouponDiscount, &saga.IdempotentKey, &saga.Error,
+		&saga.LeaseOwner, &saga.LeaseExpiresAt, &saga.CreatedAt, &saga.UpdatedAt)
+	if err != nil {
+		return saga, err
+	}
+	err = json.Unmarshal(items, &saga.Items)
+	return saga, err
+}
+
+// updateSaga writes the saga state using either a database or a transaction, if the saga is still at fromStatus
+// and leased to its owner. Every step changes the status, so a write that matches no row lost the saga to another worker.
+func updateSaga(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}, saga domain.OrderSaga, fromStatus string) (err error) {
+	items, err := json.Marshal(saga.Items)
+	if err != nil {
+		return err
+	}
+
+	var orderID sql.NullInt64
+	if saga.OrderID != 0 {
+		orderID = sql.NullInt64{Int64: int64(saga.OrderID), Valid: true}
+	}
+
+	query := `UPDATE order_sagas SET order_id = ?, status = ?, items = ?, coupon_discount = ?, error = ?, lease_expires_at = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND lease_owner = ?`
+	res, err := db.ExecContext(ctx, query, orderID, saga.Status, items, saga.CouponDiscount, saga.Error, saga.LeaseExpiresAt.UTC(), saga.UpdatedAt.UTC(),
+		saga.ID, fromStatus, saga.LeaseOwner)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrSagaConflict
+	}
+	return nil
+}
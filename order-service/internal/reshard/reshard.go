@@ -0,0 +1,76 @@
+package reshard
+
+import (
+	"context"
+
+	repo "order-service/internal/repository/mysql"
+	"order-service/internal/sharding"
+
+	"github.com/rs/zerolog/log"
+)
+
+const userBatchSize = 500
+
+// Resharder moves the orders of every user whose shard changed to the shard the router now places them on.
+type Resharder struct {
+	repo      repo.ReshardRepository
+	router    *sharding.ShardRouter
+	batchSize int
+	dryRun    bool
+}
+
+// Report counts the users and orders moved by a run.
+type Report struct {
+	Users  int
+	Orders int
+}
+
+func NewResharder(repo repo.ReshardRepository, router *sharding.ShardRouter, batchSize int, dryRun bool) *Resharder {
+	return &Resharder{
+		repo:      repo,
+		router:    router,
+		batchSize: batchSize,
+		dryRun:    dryRun,
+	}
+}
+
+// Run scans the users of every shard and moves the misplaced ones. Users are moved one at a time, so the
+// service keeps running; it dual-reads the previous shard of a user until the run completes.
+func (r *Resharder) Run(ctx context.Context) (report Report, err error) {
+	for shardIndex := 0; shardIndex < r.router.ShardCount; shardIndex++ {
+		afterUserID := 0
+		for {
+			userIDs, err := r.repo.GetUserIDs(ctx, shardIndex, afterUserID, userBatchSize)
+			if err != nil {
+				return report, err
+			}
+			if len(userIDs) == 0 {
+				break
+			}
+			afterUserID = userIDs[len(userIDs)-1]
+
+			for _, userID := range userIDs {
+				target := r.router.GetShard(userID)
+				if target == shardIndex {
+					continue
+				}
+
+				report.Users++
+				if r.dryRun {
+					log.Info().Msgf("Would move orders of user %d from shard %d to shard %d", userID, shardIndex, target)
+					continue
+				}
+
+				moved, err := r.repo.MoveUserOrders(ctx, userID, shardIndex, target, r.batchSize)
+				report.Orders += moved
+				if err != nil {
+					log.Error().Err(err).Msgf("Error moving orders of user %d from shard %d to shard %d", userID, shardIndex, target)
+					return report, err
+				}
+				log.Info().Msgf("Moved %d orders of user %d from shard %d to shard %d", moved, userID, shardIndex, target)
+			}
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,41 @@
+package saga
+
+import (
+	"context"
+	"time"
+
+	"order-service/internal/usecase"
+
+	"github.com/rs/zerolog/log"
+)
+
+const resumeInterval = 30 * time.Second
+
+// Resumer periodically resumes order sagas left unfinished, e.g. by a crash or restart.
+type Resumer struct {
+	orderUsecase usecase.OrderUsecase
+}
+
+func NewResumer(orderUsecase usecase.OrderUsecase) *Resumer {
+	return &Resumer{orderUsecase: orderUsecase}
+}
+
+// Start resumes sagas right away and then on every interval until the context is cancelled.
+func (r *Resumer) Start(ctx context.Context) {
+	ticker := time.NewTicker(resumeInterval)
+	defer ticker.Stop()
+
+	for {
+		err := r.orderUsecase.ResumeSagas(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Error resuming order sagas")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Stopping order saga resumer")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package sharding
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Order IDs are snowflake-style: 41 bits of milliseconds since orderIDEpoch, 8 bits of shard,
+// 5 bits of node and 9 bits of sequence. The shard bits let any order ID resolve to its shard.
+const (
+	orderIDShardBits    = 8
+	orderIDNodeBits     = 5
+	orderIDSequenceBits = 9
+
+	MaxShards = 1 << orderIDShardBits
+	MaxNodes  = 1 << orderIDNodeBits
+
+	orderIDNodeShift  = orderIDSequenceBits
+	orderIDShardShift = orderIDNodeShift + orderIDNodeBits
+	orderIDTimeShift  = orderIDShardShift + orderIDShardBits
+	orderIDMaxSeq     = 1<<orderIDSequenceBits - 1
+
+	// Orders created before snowflake IDs used per-shard AUTO_INCREMENT ranges starting at (shard+1)*legacyShardRange
+	legacyShardRange = 1000001
+	legacyMaxID      = 1 << 32
+)
+
+// orderIDEpoch is the custom epoch of order IDs, 2024-01-01 UTC.
+var orderIDEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var ErrInvalidOrderID = errors.New("invalid order ID")
+
+// OrderIDGenerator generates unique order IDs for this node. Every order-service instance needs its own node ID.
+type OrderIDGenerator struct {
+	mu       sync.Mutex
+	nodeID   int
+	lastMs   []int64
+	sequence []int
+	now      func() time.Time
+}
+
+func NewOrderIDGenerator(nodeID int) (*OrderIDGenerator, error) {
+	if nodeID < 0 || nodeID >= MaxNodes {
+		return nil, fmt.Errorf("node ID %d out of range [0, %d)", nodeID, MaxNodes)
+	}
+
+	return &OrderIDGenerator{
+		nodeID:   nodeID,
+		lastMs:   make([]int64, MaxShards),
+		sequence: make([]int, MaxShards),
+		now:      time.Now,
+	}, nil
+}
+
+// NextID returns a new order ID for an order stored on the shard.
+func (g *OrderIDGenerator) NextID(shardIndex int) (id int, err error) {
+	if shardIndex < 0 || shardIndex >= MaxShards {
+		return 0, fmt.Errorf("shard %d out of range [0, %d)", shardIndex, MaxShards)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.now().Sub(orderIDEpoch).Milliseconds()
+	if ms < g.lastMs[shardIndex] {
+		// The clock moved backwards; keep counting on the last timestamp so IDs stay unique
+		ms = g.lastMs[shardIndex]
+	}
+
+	if ms == g.lastMs[shardIndex] {
+		g.sequence[shardIndex]++
+		if g.sequence[shardIndex] > orderIDMaxSeq {
+			// Sequence exhausted for this millisecond, borrow the next one
+			ms++
+			g.sequence[shardIndex] = 0
+		}
+	} else {
+		g.sequence[shardIndex] = 0
+	}
+	g.lastMs[shardIndex] = ms
+
+	return int(ms<<orderIDTimeShift | int64(shardIndex)<<orderIDShardShift | int64(g.nodeID)<<orderIDNodeShift | int64(g.sequence[shardIndex])), nil
+}
+
+// ShardOfOrderID returns the shard an order ID was created on. It also resolves legacy
+// AUTO_INCREMENT IDs from their per-shard range.
+func ShardOfOrderID(id int) (shardIndex int, err error) {
+	if id <= 0 {
+		return 0, ErrInvalidOrderID
+	}
+
+	if id < legacyMaxID {
+		shardIndex = id/legacyShardRange - 1
+		if shardIndex < 0 {
+			return 0, ErrInvalidOrderID
+		}
+		return shardIndex, nil
+	}
+
+	return (id >> orderIDShardShift) & (MaxShards - 1), nil
+}
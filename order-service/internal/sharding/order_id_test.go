@@ -0,0 +1,187 @@
+package sharding
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fields splits an order ID into the parts NextID packs into it.
+func fields(id int) (ms int64, shardIndex, nodeID, sequence int) {
+	return int64(id) >> orderIDTimeShift, (id >> orderIDShardShift) & (MaxShards - 1), (id >> orderIDNodeShift) & (MaxNodes - 1), id & orderIDMaxSeq
+}
+
+func newTestGenerator(t *testing.T, nodeID int, now time.Time) *OrderIDGenerator {
+	t.Helper()
+
+	g, err := NewOrderIDGenerator(nodeID)
+	if err != nil {
+		t.Fatalf("NewOrderIDGenerator(%d): %v", nodeID, err)
+	}
+	g.now = func() time.Time { return now }
+	return g
+}
+
+func TestOrderIDEncodesShardNodeAndTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		nodeID int
+		shard  int
+		now    time.Time
+	}{
+		{"first shard and node", 0, 0, orderIDEpoch.Add(24 * time.Hour)},
+		{"last shard and node", MaxNodes - 1, MaxShards - 1, orderIDEpoch.Add(time.Hour)},
+		{"today", 7, 3, time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)},
+		{"far future", 1, 42, time.Date(2090, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGenerator(t, tt.nodeID, tt.now)
+
+			id, err := g.NextID(tt.shard)
+			if err != nil {
+				t.Fatalf("NextID: %v", err)
+			}
+
+			ms, shardIndex, nodeID, sequence := fields(id)
+			if want := tt.now.Sub(orderIDEpoch).Milliseconds(); ms != want {
+				t.Errorf("time is %d ms, want %d", ms, want)
+			}
+			if shardIndex != tt.shard || nodeID != tt.nodeID || sequence != 0 {
+				t.Errorf("got shard %d, node %d, sequence %d, want %d, %d, 0", shardIndex, nodeID, sequence, tt.shard, tt.nodeID)
+			}
+
+			decoded, err := ShardOfOrderID(id)
+			if err != nil {
+				t.Fatalf("ShardOfOrderID(%d): %v", id, err)
+			}
+			if decoded != tt.shard {
+				t.Errorf("ShardOfOrderID(%d) = %d, want %d", id, decoded, tt.shard)
+			}
+			if id < legacyMaxID {
+				t.Errorf("ID %d falls in the legacy range", id)
+			}
+		})
+	}
+}
+
+func TestOrderIDSequence(t *testing.T) {
+	now := orderIDEpoch.Add(time.Minute)
+	g := newTestGenerator(t, 2, now)
+
+	seen := make(map[int]bool)
+	last := 0
+	for i := 0; i <= orderIDMaxSeq+1; i++ {
+		id, err := g.NextID(5)
+		if err != nil {
+			t.Fatalf("NextID: %v", err)
+		}
+		if seen[id] || id <= last {
+			t.Fatalf("ID %d after %d is not unique and increasing", id, last)
+		}
+		seen[id] = true
+		last = id
+	}
+
+	// The sequence ran out within one millisecond, so the last ID borrowed the next one
+	ms, _, _, sequence := fields(last)
+	if want := now.Sub(orderIDEpoch).Milliseconds() + 1; ms != want || sequence != 0 {
+		t.Errorf("after exhausting the sequence got %d ms and sequence %d, want %d ms and 0", ms, sequence, want)
+	}
+
+	// Shards count their sequences separately
+	id, err := g.NextID(6)
+	if err != nil {
+		t.Fatalf("NextID: %v", err)
+	}
+	if _, shardIndex, _, sequence := fields(id); shardIndex != 6 || sequence != 0 {
+		t.Errorf("first ID of shard 6 has shard %d and sequence %d", shardIndex, sequence)
+	}
+}
+
+func TestOrderIDClockMovingBackwards(t *testing.T) {
+	now := orderIDEpoch.Add(time.Hour)
+	g := newTestGenerator(t, 0, now)
+
+	first, err := g.NextID(1)
+	if err != nil {
+		t.Fatalf("NextID: %v", err)
+	}
+
+	g.now = func() time.Time { return now.Add(-time.Second) }
+	second, err := g.NextID(1)
+	if err != nil {
+		t.Fatalf("NextID: %v", err)
+	}
+
+	if second <= first {
+		t.Errorf("ID %d after the clock moved back is not greater than %d", second, first)
+	}
+}
+
+func TestOrderIDGeneratorRejectsOutOfRange(t *testing.T) {
+	for _, nodeID := range []int{-1, MaxNodes} {
+		if _, err := NewOrderIDGenerator(nodeID); err == nil {
+			t.Errorf("NewOrderIDGenerator(%d) succeeded", nodeID)
+		}
+	}
+
+	g := newTestGenerator(t, 0, time.Now())
+	for _, shardIndex := range []int{-1, MaxShards} {
+		if _, err := g.NextID(shardIndex); err == nil {
+			t.Errorf("NextID(%d) succeeded", shardIndex)
+		}
+	}
+}
+
+func TestShardOfLegacyOrderID(t *testing.T) {
+	tests := []struct {
+		id    int
+		shard int
+		err   error
+	}{
+		{0, 0, ErrInvalidOrderID},
+		{-5, 0, ErrInvalidOrderID},
+		{1, 0, ErrInvalidOrderID},
+		{legacyShardRange - 1, 0, ErrInvalidOrderID},
+		{legacyShardRange, 0, nil},
+		{2*legacyShardRange - 1, 0, nil},
+		{2 * legacyShardRange, 1, nil},
+		{3*legacyShardRange + 17, 2, nil},
+		{legacyMaxID - 1, (legacyMaxID-1)/legacyShardRange - 1, nil},
+	}
+	for _, tt := range tests {
+		shardIndex, err := ShardOfOrderID(tt.id)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ShardOfOrderID(%d) error = %v, want %v", tt.id, err, tt.err)
+			continue
+		}
+		if err == nil && shardIndex != tt.shard {
+			t.Errorf("ShardOfOrderID(%d) = %d, want %d", tt.id, shardIndex, tt.shard)
+		}
+	}
+}
+
+func TestGetOrderShardRejectsUnknownShard(t *testing.T) {
+	router := NewShardRouterWithPlacement(2, ModuloPlacement{Count: 2}, nil)
+
+	shardIndex, err := router.GetOrderShard(2 * legacyShardRange)
+	if err != nil || shardIndex != 1 {
+		t.Errorf("GetOrderShard of a legacy shard 1 ID = %d, %v", shardIndex, err)
+	}
+
+	_, err = router.GetOrderShard(3 * legacyShardRange)
+	if !errors.Is(err, ErrInvalidOrderID) {
+		t.Errorf("GetOrderShard of a legacy shard 2 ID: got %v, want ErrInvalidOrderID", err)
+	}
+
+	g := newTestGenerator(t, 0, time.Now())
+	id, err := g.NextID(5)
+	if err != nil {
+		t.Fatalf("NextID: %v", err)
+	}
+	_, err = router.GetOrderShard(id)
+	if !errors.Is(err, ErrInvalidOrderID) {
+		t.Errorf("GetOrderShard of a shard 5 ID: got %v, want ErrInvalidOrderID", err)
+	}
+}
@@ -0,0 +1,105 @@
+package sharding
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultVirtualNodes is the number of points a shard of weight 1 gets on the ring.
+const DefaultVirtualNodes = 128
+
+// Placement maps a user to the shard that owns the user's orders.
+type Placement interface {
+	ShardFor(userID int) int
+}
+
+// ModuloPlacement is the original userID % count placement, kept to read users not yet moved to the ring.
+type ModuloPlacement struct {
+	Count int
+}
+
+func (p ModuloPlacement) ShardFor(userID int) int {
+	return userID % p.Count
+}
+
+// RingNode is a shard on the hash ring. The name, not the index, decides the ring points, so
+// adding a shard only takes over the users between its own points.
+type RingNode struct {
+	Shard  int
+	Name   string
+	Weight int
+}
+
+type ringPoint struct {
+	hash  uint32
+	shard int
+}
+
+// Ring is a consistent-hash ring with virtual nodes.
+type Ring struct {
+	points []ringPoint
+}
+
+// NewRing places weight*virtualNodes points for every node on the ring.
+func NewRing(nodes []RingNode, virtualNodes int) (*Ring, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("ring needs at least one shard")
+	}
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+
+	ring := &Ring{}
+	for _, node := range nodes {
+		if node.Weight <= 0 {
+			return nil, fmt.Errorf("shard %q has weight %d, must be positive", node.Name, node.Weight)
+		}
+		for i := 0; i < node.Weight*virtualNodes; i++ {
+			ring.points = append(ring.points, ringPoint{hash: hashKey(node.Name + "#" + strconv.Itoa(i)), shard: node.Shard})
+		}
+	}
+
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i].hash < ring.points[j].hash })
+	return ring, nil
+}
+
+// ShardFor returns the shard of the first point clockwise from the user's hash.
+func (r *Ring) ShardFor(userID int) int {
+	hash := hashKey(strconv.Itoa(userID))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].shard
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// ParsePlacement parses the placement of a previous topology: "modulo:N" for the original
+// userID % N placement or "ring:N" for a ring of the first N of nodes.
+func ParsePlacement(spec string, nodes []RingNode, virtualNodes int) (Placement, error) {
+	kind, countStr, _ := strings.Cut(spec, ":")
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("invalid placement %q, expected modulo:N or ring:N", spec)
+	}
+
+	switch kind {
+	case "modulo":
+		return ModuloPlacement{Count: count}, nil
+	case "ring":
+		if count > len(nodes) {
+			return nil, fmt.Errorf("placement %q has more shards than the %d configured", spec, len(nodes))
+		}
+		return NewRing(nodes[:count], virtualNodes)
+	default:
+		return nil, fmt.Errorf("invalid placement %q, expected modulo:N or ring:N", spec)
+	}
+}
@@ -1,15 +1,67 @@
 package sharding
 
+import "fmt"
+
 type ShardRouter struct {
-	ShardCount int // Number of shards
+	ShardCount int       // Number of shards
+	placement  Placement // where users live now
+	previous   Placement // where users lived before resharding, nil once it is finished
 }
 
-func NewShardRouter(shardCount int) *ShardRouter {
-	return &ShardRouter{ShardCount: shardCount}
+// NewShardRouterWithPlacement builds a router from a placement. While previous is set the
+// router dual-reads users from the shard they are moving from as well.
+func NewShardRouterWithPlacement(shardCount int, placement, previous Placement) *ShardRouter {
+	return &ShardRouter{ShardCount: shardCount, placement: placement, previous: previous}
 }
 
+// GetShard returns the shard new data of the user is written to.
 func (r *ShardRouter) GetShard(userID int) int {
-	// Hash the ID and get the shard index
-	shardIndex := userID % r.ShardCount
-	return shardIndex
+	return r.placement.ShardFor(userID)
+}
+
+// GetReadShards returns the shards that may hold data of the user: the current shard first and,
+// while resharding, the shard the user is moving from.
+func (r *ShardRouter) GetReadShards(userID int) []int {
+	shards := []int{r.GetShard(userID)}
+	if r.previous != nil {
+		if previous := r.previous.ShardFor(userID); previous != shards[0] && previous < r.ShardCount {
+			shards = append(shards, previous)
+		}
+	}
+	return shards
+}
+
+// GetOrderShard returns the shard an order was created on, derived from the order ID.
+func (r *ShardRouter) GetOrderShard(orderID int) (int, error) {
+	shardIndex, err := ShardOfOrderID(orderID)
+	if err != nil {
+		return 0, err
+	}
+	if shardIndex >= r.ShardCount {
+		return 0, ErrInvalidOrderID
+	}
+	return shardIndex, nil
+}
+
+// NewRouter builds the ring router for the shards. previousSpec is the placement users are
+// being moved from (see ParsePlacement), or empty when no resharding is in progress.
+func NewRouter(nodes []RingNode, virtualNodes int, previousSpec string) (*ShardRouter, error) {
+	if len(nodes) > MaxShards {
+		return nil, fmt.Errorf("%d shards configured, order IDs support at most %d", len(nodes), MaxShards)
+	}
+
+	ring, err := NewRing(nodes, virtualNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous Placement
+	if previousSpec != "" {
+		previous, err = ParsePlacement(previousSpec, nodes, virtualNodes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewShardRouterWithPlacement(len(nodes), ring, previous), nil
 }
@@ -0,0 +1,84 @@
+// Package testutil sets up the databases integration tests run against.
+package testutil
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"order-service/migration"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL returns a connection to a new shard database with every migration applied, dropped when the test ends.
+// The server comes from TEST_MYSQL_DSN, e.g. root:root@tcp(localhost:3306)/, and the test is skipped without it.
+func MySQL(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set")
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("invalid TEST_MYSQL_DSN: %v", err)
+	}
+	cfg.ParseTime = true
+	cfg.DBName = ""
+
+	server, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("connecting to MySQL: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	name := fmt.Sprintf("order_test_%d", time.Now().UnixNano())
+	_, err = server.Exec("CREATE DATABASE `" + name + "`")
+	if err != nil {
+		t.Fatalf("creating database %s: %v", name, err)
+	}
+	t.Cleanup(func() { server.Exec("DROP DATABASE `" + name + "`") })
+
+	cfg.DBName = name
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("connecting to database %s: %v", name, err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrate(t, db)
+	return db
+}
+
+// migrate applies the migrations of order-service in the order cmd/main.go runs them.
+func migrate(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	migrations := []struct {
+		name string
+		run  func() error
+	}{
+		{"orders", func() error { return migration.AutoMigrateOrders(0, db) }},
+		{"orders created_at", func() error { return migration.AutoMigrateOrderCreatedAt(db) }},
+		{"product_requests", func() error { return migration.AutoMigrateProductRequests(0, db) }},
+		{"product_requests sku", func() error { return migration.AutoMigrateProductRequestSKU(db) }},
+		{"product_requests reservation_id", func() error { return migration.AutoMigrateProductRequestReservations(db) }},
+		{"order_outbox", func() error { return migration.AutoMigrateOrderOutbox(0, db) }},
+		{"order_outbox aggregate index", func() error { return migration.AutoMigrateOrderOutboxAggregateIndex(db) }},
+		{"order_sagas", func() error { return migration.AutoMigrateOrderSagas(0, db) }},
+		{"order_status_history", func() error { return migration.AutoMigrateOrderStatusHistory(0, db) }},
+		{"order coupons", func() error { return migration.AutoMigrateOrderCoupons(db) }},
+		{"order saga leases", func() error { return migration.AutoMigrateOrderSagaLeases(db) }},
+		{"order IDs to bigint", func() error { return migration.AutoMigrateOrderIDsToBigint(db) }},
+		{"order_moves", func() error { return migration.AutoMigrateOrderMoves(0, db) }},
+	}
+	for _, m := range migrations {
+		if err := m.run(); err != nil {
+			t.Fatalf("migrating %s: %v", m.name, err)
+		}
+	}
+}
@@ -1,11 +1,12 @@
 package usecase
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"order-service/domain"
@@ -13,32 +14,38 @@ import (
 	cache "order-service/internal/repository/redis"
 	"order-service/pkg/utils"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
-	"github.com/segmentio/kafka-go"
 )
 
 type OrderUsecase interface {
 	CreateOrder(ctx context.Context, req domain.OrderRequest) (order domain.Order, err error)
+	ResumeSagas(ctx context.Context) (err error)
 	UpdateOrder(ctx context.Context, req domain.Order) (updateOrder domain.Order, err error)
 	CancelOrder(ctx context.Context, id int) (updatedOrder domain.Order, err error)
-	checkProductStock(ctx context.Context, productId int, quantity int) (avail bool, err error)
+	CancelExpiredOrder(ctx context.Context, event domain.ReservationEvent) (err error)
+	GetOrder(ctx context.Context, id int) (order domain.Order, err error)
+	ListOrders(ctx context.Context, filter domain.OrderFilter) (page domain.OrderPage, err error)
+	SearchOrders(ctx context.Context, filter domain.OrderFilter) (page domain.OrderPage, err error)
 }
 
 type orderUsecase struct {
 	repo              repo.OrderRepository
+	sagaRepo          repo.SagaRepository
 	cache             cache.OrderCache
-	kafkaWriter       *kafka.Writer
 	productServiceURL string
 	pricingServiceURL string
+	httpClient        *http.Client
 }
 
-func NewOrderUsecase(repo repo.OrderRepository, cache cache.OrderCache, kafkaWriter *kafka.Writer, productServiceURL, pricingServiceURL string) OrderUsecase {
+func NewOrderUsecase(repo repo.OrderRepository, sagaRepo repo.SagaRepository, cache cache.OrderCache, productServiceURL, pricingServiceURL string) OrderUsecase {
 	return &orderUsecase{
 		repo:              repo,
+		sagaRepo:          sagaRepo,
 		cache:             cache,
-		kafkaWriter:       kafkaWriter,
 		productServiceURL: productServiceURL,
 		pricingServiceURL: pricingServiceURL,
+		httpClient:        &http.Client{Timeout: serviceRequestTimeout},
 	}
 }
 
@@ -59,276 +66,240 @@ func (u *orderUsecase) CreateOrder(ctx context.Context, req domain.OrderRequest)
 		return createdOrder, err
 	}
 
-	var orderReq domain.Order
-
-	availabilityCh := make(chan struct {
-		ProductID int
-		Available bool
-		Error     error
-	}, len(req.ProductRequests))
-
-	pricingCh := make(chan struct {
-		ProductID  int
-		FinalPrice float64
-		MarkUp     float64
-		Discount   float64
-		Error      error
-	}, len(req.ProductRequests))
-
-	for _, productRequest := range req.ProductRequests {
-		//// check product availability
-		//available, err := u.checkProductStock(productRequest.ProductID, productRequest.Quantity)
-		//if err != nil {
-		//	log.Error().Err(err).Msgf("Error checking product stock for product %d", productRequest.ProductID)
-		//	return nil, err
-		//}
-		//
-		//// get pricing
-		//pricing, err := u.getPricing(productRequest.ProductID)
-		//if err != nil {
-		//	log.Error().Err(err).Msgf("Error getting pricing for product %d", productRequest.ProductID)
-		//	return nil, err
-		//}
-		//
-		//if !available {
-		//	log.Warn().Msgf("Product %d out of stock", productRequest.ProductID)
-		//	return nil, fmt.Errorf("product out of stock")
-		//}
-		//
-		//productRequest.FinalPrice = float64(productRequest.Quantity) * pricing.FinalPrice
-		//productRequest.MarkUp = float64(productRequest.Quantity) * pricing.Markup
-		//productRequest.Discount = float64(productRequest.Quantity) * pricing.Discount
-
-		go func(productID, quantity int) {
-			available, err := u.checkProductStock(ctx, productID, quantity)
-			availabilityCh <- struct {
-				ProductID int
-				Available bool
-				Error     error
-			}{
-				ProductID: productRequest.ProductID,
-				Available: available,
-				Error:     err,
-			}
-			fmt.Println(availabilityCh)
-		}(productRequest.ProductID, productRequest.Quantity)
-
-		go func(productID int) {
-			pricing, err := u.getPricing(ctx, productID)
-			pricingCh <- struct {
-				ProductID  int
-				FinalPrice float64
-				MarkUp     float64
-				Discount   float64
-				Error      error
-			}{
-				ProductID:  productRequest.ProductID,
-				FinalPrice: pricing.FinalPrice,
-				MarkUp:     pricing.Markup,
-				Discount:   pricing.Discount,
-				Error:      err,
-			}
-		}(productRequest.ProductID)
+	// Lines for the same product and SKU are merged so each is reserved once per saga
+	type itemKey struct {
+		productID int
+		sku       string
 	}
-
-	for range req.ProductRequests {
-		availabilityResult := <-availabilityCh
-		pricingResult := <-pricingCh
-
-		if availabilityResult.Error != nil {
-			log.Error().Err(availabilityResult.Error).Msgf("Error checking product stock for product %d", availabilityResult.ProductID)
-			return createdOrder, availabilityResult.Error
-		}
-
-		if !availabilityResult.Available {
-			log.Warn().Msgf("Product %d out of stock", availabilityResult.ProductID)
-			return createdOrder, fmt.Errorf("product out of stock")
-		}
-
-		if pricingResult.Error != nil {
-			log.Error().Err(pricingResult.Error).Msgf("Error getting pricing for product %d", pricingResult.ProductID)
-			return createdOrder, pricingResult.Error
+	var items []domain.ProductRequest
+	quantities := map[itemKey]int{}
+	for _, productRequest := range req.ProductRequests {
+		if productRequest.Quantity <= 0 {
+			return createdOrder, fmt.Errorf("invalid quantity for product %d", productRequest.ProductID)
 		}
-
-		for _, productRequest := range req.ProductRequests {
-			if productRequest.ProductID == availabilityResult.ProductID {
-				productRequestReq := domain.ProductRequest{}
-				productRequestReq.ProductID = productRequest.ProductID
-				productRequestReq.Quantity = productRequest.Quantity
-				productRequestReq.FinalPrice = float64(productRequest.Quantity) * pricingResult.FinalPrice
-				productRequestReq.MarkUp = float64(productRequest.Quantity) * pricingResult.MarkUp
-				productRequestReq.Discount = float64(productRequest.Quantity) * pricingResult.Discount
-				orderReq.ProductRequests = append(orderReq.ProductRequests, productRequestReq)
-			}
+		key := itemKey{productRequest.ProductID, productRequest.SKU}
+		if _, ok := quantities[key]; !ok {
+			items = append(items, domain.ProductRequest{ProductID: productRequest.ProductID, SKU: productRequest.SKU})
 		}
+		quantities[key] += productRequest.Quantity
+	}
+	if len(items) == 0 {
+		return createdOrder, errors.New("order has no products")
+	}
+	for i := range items {
+		items[i].Quantity = quantities[itemKey{items[i].ProductID, items[i].SKU}]
 	}
 
-	orderReq.UserID = user.ID
-	orderReq.Total = 0
-	orderReq.Status = "created"
-	orderReq.IdempotentKey = req.IdempotentKey
-	for _, productRequest := range orderReq.ProductRequests {
-		orderReq.TotalDiscount += productRequest.Discount
-		orderReq.TotalMarkUp += productRequest.MarkUp
-		orderReq.Total += productRequest.FinalPrice
-		orderReq.Quantity += productRequest.Quantity
+	// The saga is leased to this request until it stalls, so the resumer leaves it alone meanwhile
+	now := time.Now()
+	saga := domain.OrderSaga{
+		ID:             uuid.New().String(),
+		UserID:         user.ID,
+		Status:         domain.SagaStatusStarted,
+		Items:          items,
+		CouponCode:     strings.ToUpper(strings.TrimSpace(req.CouponCode)),
+		IdempotentKey:  req.IdempotentKey,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		LeaseOwner:     uuid.New().String(),
+		LeaseExpiresAt: now.Add(sagaLease),
 	}
 
-	createdOrder, err = u.repo.CreateOrder(ctx, orderReq)
-	if err != nil {
-		log.Error().Err(err).Msg("Error creating order")
-		return createdOrder, err
+	// Orders without an Idempotent-Key header still need a unique key
+	if saga.IdempotentKey == "" {
+		saga.IdempotentKey = saga.ID
 	}
 
-	err = u.publishOrderEvent(ctx, &createdOrder, "created")
+	saga, err = u.sagaRepo.CreateSaga(ctx, saga)
 	if err != nil {
+		log.Error().Err(err).Msg("Error creating order saga")
 		return createdOrder, err
 	}
 
-	return createdOrder, nil
+	return u.runSaga(ctx, saga)
 }
 
-// UpdateOrder updates an existing order
+// UpdateOrder changes the status of an existing order following the order state machine. Customers may only
+// cancel their own orders; admins and services may set any status on any order.
 func (u *orderUsecase) UpdateOrder(ctx context.Context, req domain.Order) (updateOrder domain.Order, err error) {
-	if req.Status == "paid" {
-		// check product availability
-		for _, productRequest := range req.ProductRequests {
-			available, err := u.checkProductStock(ctx, productRequest.ProductID, productRequest.Quantity)
-			if err != nil {
-				log.Error().Err(err).Msgf("Error checking product stock for product %d", productRequest.ProductID)
-				return updateOrder, err
-			}
-
-			if !available {
-				log.Warn().Msgf("Product %d out of stock", productRequest.ProductID)
-				return updateOrder, fmt.Errorf("product out of stock")
-			}
-		}
-	}
-	updateOrder, err = u.repo.UpdateOrder(ctx, req)
-	if err != nil {
-		log.Error().Err(err).Msg("Error updating order")
-		return updateOrder, err
+	eventType := domain.OrderEventUpdated
+	if req.Status == domain.OrderStatusCancelled {
+		eventType = domain.OrderEventCancelled
 	}
 
-	err = u.publishOrderEvent(ctx, &updateOrder, "updated")
+	return u.changeOrderStatus(ctx, req.ID, req.Status, eventType)
+}
+
+// CancelOrder cancels an existing order of the current user, which releases its reserved stock
+func (u *orderUsecase) CancelOrder(ctx context.Context, id int) (updatedOrder domain.Order, err error) {
+	return u.changeOrderStatus(ctx, id, domain.OrderStatusCancelled, domain.OrderEventCancelled)
+}
+
+// changeOrderStatus checks the caller may set the status, validates the transition against the current status
+// and records who changed it. Paying an order confirms its stock reservations; one that expired cancels the order.
+func (u *orderUsecase) changeOrderStatus(ctx context.Context, id int, status, eventType string) (updatedOrder domain.Order, err error) {
+	user, err := utils.GetUserFromContext(ctx)
 	if err != nil {
-		return updateOrder, err
+		return updatedOrder, err
 	}
 
-	return updateOrder, nil
-}
+	role, _ := ctx.Value(domain.UserRoleKey).(string)
+	if !domain.CanSetStatus(role, status) {
+		log.Warn().Msgf("User %d with role %q tried to set order %d to %s", user.ID, role, id, status)
+		return updatedOrder, fmt.Errorf("%w: only admins and services may set %s", domain.ErrStatusChangeForbidden, status)
+	}
 
-// CancelOrder cancels an existing order
-func (u *orderUsecase) CancelOrder(ctx context.Context, id int) (updatedOrder domain.Order, err error) {
 	order, err := u.repo.GetOrderByID(ctx, id)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return updatedOrder, domain.ErrOrderNotFound
+		}
 		log.Error().Err(err).Msgf("Error getting order by ID %d", id)
 		return updatedOrder, err
 	}
 
-	order.Status = "cancelled"
+	if order.UserID != user.ID && !domain.IsStaff(role) {
+		return updatedOrder, domain.ErrOrderNotFound
+	}
 
-	updatedOrder, err = u.repo.UpdateOrder(ctx, order)
+	err = domain.ValidateTransition(order.Status, status)
 	if err != nil {
-		log.Error().Err(err).Msg("Error updating order")
+		log.Warn().Err(err).Msgf("Rejected status change of order %d", id)
 		return updatedOrder, err
 	}
 
-	err = u.publishOrderEvent(ctx, &updatedOrder, "cancelled")
+	if status == domain.OrderStatusPaid {
+		err = u.confirmOrderStock(ctx, order)
+		if errors.Is(err, domain.ErrReservationExpired) {
+			// The stock went back when the reservation expired, so the order cannot be fulfilled
+			log.Warn().Err(err).Msgf("Cancelling order %d paid after its reservation expired", id)
+			_, cancelErr := u.repo.UpdateOrderStatus(ctx, order, domain.OrderStatusCancelled, user.ID, domain.OrderEventCancelled)
+			if cancelErr != nil {
+				log.Error().Err(cancelErr).Msgf("Error cancelling order %d", id)
+			}
+			return updatedOrder, err
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("Error confirming stock of order %d", id)
+			return updatedOrder, err
+		}
+	}
+
+	updatedOrder, err = u.repo.UpdateOrderStatus(ctx, order, status, user.ID, eventType)
 	if err != nil {
+		log.Error().Err(err).Msgf("Error updating status of order %d", id)
 		return updatedOrder, err
 	}
 
 	return updatedOrder, nil
 }
 
-func (u *orderUsecase) checkProductStock(ctx context.Context, productId int, quantity int) (avail bool, err error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/products/%d/stock", u.productServiceURL, productId), nil)
-	if err != nil {
-		return false, err
+// CancelExpiredOrder cancels the unpaid order holding a stock reservation that expired, as its stock went back.
+// Reservations are found through the saga in their reference. Events of reservations of other callers, of failed
+// sagas and of orders no longer awaiting payment are ignored. An order whose saga is still running when its
+// reservation expires is cancelled when it is paid, as the confirm of the reservation fails then.
+func (u *orderUsecase) CancelExpiredOrder(ctx context.Context, event domain.ReservationEvent) (err error) {
+	sagaID, ok := domain.SagaIDFromReference(event.Reference)
+	if !ok {
+		return nil
 	}
 
-	token, err := utils.GetTokenFromContext(ctx)
+	saga, err := u.sagaRepo.GetSaga(ctx, sagaID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn().Msgf("Expired reservation %d belongs to unknown order saga %s", event.ReservationID, sagaID)
+		return nil
+	}
 	if err != nil {
-		return
+		log.Error().Err(err).Msgf("Error getting order saga %s", sagaID)
+		return err
+	}
+	if saga.Status != domain.SagaStatusCompleted {
+		log.Info().Msgf("Reservation %d of order saga %s expired while the saga is %s", event.ReservationID, sagaID, saga.Status)
+		return nil
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := http.DefaultClient.Do(req)
+	order, err := u.repo.GetOrderByID(ctx, saga.OrderID)
 	if err != nil {
-		return false, err
+		log.Error().Err(err).Msgf("Error getting order by ID %d", saga.OrderID)
+		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("product not available")
+	if order.Status != domain.OrderStatusReserved || !order.HoldsReservation(event.ReservationID) {
+		return nil
 	}
 
-	var stockData map[string]int
-	if err := json.NewDecoder(resp.Body).Decode(&stockData); err != nil {
-		return false, err
+	log.Info().Msgf("Cancelling order %d as its reservation %d expired", order.ID, event.ReservationID)
+	_, err = u.repo.UpdateOrderStatus(ctx, order, domain.OrderStatusCancelled, 0, domain.OrderEventCancelled)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error cancelling order %d", order.ID)
+		return err
 	}
 
-	availableStock := stockData["stock"]
-
-	return availableStock >= quantity, nil
+	return nil
 }
 
-func (u *orderUsecase) getPricing(ctx context.Context, productId int) (pricing domain.Pricing, err error) {
-	payload, err := json.Marshal(map[string]int{"product_id": productId})
+// GetOrder returns an order of the current user; orders of other users are reported as not found
+func (u *orderUsecase) GetOrder(ctx context.Context, id int) (order domain.Order, err error) {
+	user, err := utils.GetUserFromContext(ctx)
 	if err != nil {
-		return pricing, err
+		return order, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/pricing", u.pricingServiceURL), bytes.NewBuffer(payload))
+	order, err = u.repo.GetOrderByID(ctx, id)
 	if err != nil {
-		return pricing, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return order, domain.ErrOrderNotFound
+		}
+		log.Error().Err(err).Msgf("Error getting order by ID %d", id)
+		return order, err
 	}
 
-	token, err := utils.GetTokenFromContext(ctx)
-	if err != nil {
-		return
+	if order.UserID != user.ID {
+		return domain.Order{}, domain.ErrOrderNotFound
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
+	return order, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// ListOrders lists the orders of the current user, which all live on the shard of the user
+func (u *orderUsecase) ListOrders(ctx context.Context, filter domain.OrderFilter) (page domain.OrderPage, err error) {
+	user, err := utils.GetUserFromContext(ctx)
 	if err != nil {
-		return pricing, err
+		return page, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return pricing, fmt.Errorf("failed to get pricing")
-	}
+	filter.UserID = user.ID
+	filter.Limit = clampOrderLimit(filter.Limit)
 
-	if err := json.NewDecoder(resp.Body).Decode(&pricing); err != nil {
-		return pricing, err
+	page, err = u.repo.GetOrdersByUser(ctx, filter)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error listing orders of user %d", user.ID)
+		return page, err
 	}
 
-	return pricing, nil
+	return page, nil
 }
 
-func (u *orderUsecase) publishOrderEvent(ctx context.Context, order *domain.Order, key string) (err error) {
-	orderJSON, err := json.Marshal(order)
+// SearchOrders searches orders of every user across all shards, for admins
+func (u *orderUsecase) SearchOrders(ctx context.Context, filter domain.OrderFilter) (page domain.OrderPage, err error) {
+	filter.Limit = clampOrderLimit(filter.Limit)
+
+	page, err = u.repo.SearchOrders(ctx, filter)
 	if err != nil {
-		return err
+		log.Error().Err(err).Msg("Error searching orders")
+		return page, err
 	}
 
-	// order-created-1 or order-updated-1
-	msg := kafka.Message{
-		Key:   []byte(fmt.Sprintf("order-%s-%d", key, order.ID)),
-		Value: orderJSON,
-	}
+	return page, nil
+}
 
-	err = u.kafkaWriter.WriteMessages(ctx, msg)
-	if err != nil {
-		return err
+func clampOrderLimit(limit int) int {
+	if limit <= 0 {
+		return domain.DefaultOrderPageLimit
 	}
-
-	return nil
+	if limit > domain.MaxOrderPageLimit {
+		return domain.MaxOrderPageLimit
+	}
+	return limit
 }
 
 func (u *orderUsecase) validateIdempotentKey(ctx context.Context, key string) (exist bool, err error) {
@@ -0,0 +1,475 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"order-service/domain"
+	"order-service/pkg/utils"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sagaLease is how long a worker may hold a saga without progress before the resumer takes it over.
+	// Every step renews it and takes at most one service request, so it is well above serviceRequestTimeout.
+	sagaLease       = 1 * time.Minute
+	sagaResumeBatch = 100
+
+	// serviceRequestTimeout bounds every request to product-service and pricing-service
+	serviceRequestTimeout = 10 * time.Second
+)
+
+// ResumeSagas continues or compensates sagas that were interrupted, e.g. by a restart. Sagas are claimed
+// first, so a saga still driven by a live request or claimed by another instance is left alone.
+func (u *orderUsecase) ResumeSagas(ctx context.Context) (err error) {
+	now := time.Now()
+	sagas, err := u.sagaRepo.ClaimStaleSagas(ctx, uuid.New().String(), now, now.Add(sagaLease), sagaResumeBatch)
+	if err != nil {
+		log.Error().Err(err).Msg("Error claiming stale order sagas")
+		return err
+	}
+
+	for _, saga := range sagas {
+		// A claim that ran out while earlier sagas were resumed is left to the next round
+		if time.Now().After(saga.LeaseExpiresAt) {
+			continue
+		}
+
+		log.Info().Msgf("Resuming order saga %s at step %s", saga.ID, saga.Status)
+		_, err := u.runSaga(ctx, saga)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Order saga %s did not complete", saga.ID)
+		}
+	}
+
+	return nil
+}
+
+// runSaga drives the saga from its persisted step: reserve stock, lock prices, redeem the coupon, then persist
+// the order. Any failing step triggers compensation, which releases every reservation and the coupon of the saga.
+// When another worker took the saga over, ErrSagaConflict is returned and the saga is left to that worker.
+func (u *orderUsecase) runSaga(ctx context.Context, saga domain.OrderSaga) (order domain.Order, err error) {
+	for {
+		fromStatus := saga.Status
+		switch saga.Status {
+		case domain.SagaStatusStarted:
+			// Items keep the reservations taken so far, so compensation releases them
+			saga.Items, err = u.reserveSagaStock(ctx, saga)
+			if err != nil {
+				return u.compensateSaga(ctx, saga, err)
+			}
+			saga.Status = domain.SagaStatusStockReserved
+
+		case domain.SagaStatusStockReserved:
+			priced, err := u.lockSagaPrices(ctx, saga.Items)
+			if err != nil {
+				return u.compensateSaga(ctx, saga, err)
+			}
+			saga.Items = priced
+			saga.Status = domain.SagaStatusPriceLocked
+
+		case domain.SagaStatusPriceLocked:
+			if saga.CouponCode != "" {
+				saga.CouponDiscount, err = u.redeemSagaCoupon(ctx, saga)
+				if err != nil {
+					return u.compensateSaga(ctx, saga, err)
+				}
+			}
+			saga.Status = domain.SagaStatusCouponRedeemed
+
+		case domain.SagaStatusCouponRedeemed:
+			order, err = u.sagaRepo.CompleteSaga(ctx, saga, buildSagaOrder(saga))
+			switch {
+			case err == nil:
+				return order, nil
+			case errors.Is(err, domain.ErrSagaConflict):
+				log.Warn().Msgf("Order saga %s was taken over by another worker", saga.ID)
+				return domain.Order{}, err
+			case errors.Is(err, domain.ErrDuplicateOrder) && order.UserID == saga.UserID:
+				return u.completeDuplicateSaga(ctx, saga, order)
+			default:
+				log.Error().Err(err).Msgf("Error creating order for saga %s", saga.ID)
+				return u.compensateSaga(ctx, saga, err)
+			}
+
+		case domain.SagaStatusCompensating:
+			return u.compensateSaga(ctx, saga, errors.New(saga.Error))
+
+		default:
+			return order, fmt.Errorf("order saga %s already %s", saga.ID, saga.Status)
+		}
+
+		err = u.advanceSaga(ctx, &saga, fromStatus)
+		if err != nil {
+			// The resumer picks the saga up again from the last persisted step
+			log.Error().Err(err).Msgf("Error saving order saga %s", saga.ID)
+			return order, err
+		}
+	}
+}
+
+// advanceSaga persists the saga moving on from fromStatus and renews the lease of this worker.
+func (u *orderUsecase) advanceSaga(ctx context.Context, saga *domain.OrderSaga, fromStatus string) (err error) {
+	saga.UpdatedAt = time.Now()
+	saga.LeaseExpiresAt = saga.UpdatedAt.Add(sagaLease)
+	return u.sagaRepo.UpdateSaga(ctx, *saga, fromStatus)
+}
+
+// compensateSaga releases the stock of every item and the coupon, then marks the saga failed. If a release
+// fails the saga stays compensating and is retried by the resumer. Nothing is released once another worker
+// took the saga over, as that worker may have completed it.
+func (u *orderUsecase) compensateSaga(ctx context.Context, saga domain.OrderSaga, cause error) (order domain.Order, err error) {
+	log.Warn().Err(cause).Msgf("Compensating order saga %s", saga.ID)
+
+	if saga.Status != domain.SagaStatusCompensating {
+		fromStatus := saga.Status
+		saga.Status = domain.SagaStatusCompensating
+		saga.Error = cause.Error()
+		err = u.advanceSaga(ctx, &saga, fromStatus)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error saving order saga %s", saga.ID)
+			return order, cause
+		}
+	}
+
+	err = u.releaseSagaHolds(ctx, saga)
+	if err != nil {
+		return order, cause
+	}
+
+	saga.Status = domain.SagaStatusFailed
+	err = u.advanceSaga(ctx, &saga, domain.SagaStatusCompensating)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error saving order saga %s", saga.ID)
+	}
+
+	return order, cause
+}
+
+// completeDuplicateSaga finishes a saga whose order an earlier saga already created under the same idempotent key,
+// e.g. for a retried request: the stock and coupon this saga holds are released and it completes with that order.
+func (u *orderUsecase) completeDuplicateSaga(ctx context.Context, saga domain.OrderSaga, existing domain.Order) (order domain.Order, err error) {
+	log.Info().Msgf("Order saga %s duplicates order %d, releasing its holds", saga.ID, existing.ID)
+
+	err = u.releaseSagaHolds(ctx, saga)
+	if err != nil {
+		// Compensation releases whatever is left and is retried by the resumer
+		return u.compensateSaga(ctx, saga, err)
+	}
+
+	fromStatus := saga.Status
+	saga.OrderID = existing.ID
+	saga.Status = domain.SagaStatusCompleted
+	err = u.advanceSaga(ctx, &saga, fromStatus)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error saving order saga %s", saga.ID)
+		return order, err
+	}
+
+	return existing, nil
+}
+
+// releaseSagaHolds releases the stock reserved and the coupon redeemed by the saga. Releases are keyed by the
+// references of the saga, so holds it never took are not released and releasing twice does nothing.
+func (u *orderUsecase) releaseSagaHolds(ctx context.Context, saga domain.OrderSaga) (err error) {
+	err = u.releaseSagaStock(ctx, saga)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error releasing stock for order saga %s", saga.ID)
+		return err
+	}
+
+	if saga.CouponCode != "" {
+		err = u.releaseSagaCoupon(ctx, saga)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error releasing coupon for order saga %s", saga.ID)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reserveSagaStock holds the stock of every item with a stock reservation in product-service, which gives the
+// stock back by itself if the order is not paid before the reservation expires. Reservations are idempotent per
+// saga and item, so a resumed saga gets the reservations it already holds. The items are returned with the
+// reservations taken, also when a later item fails.
+func (u *orderUsecase) reserveSagaStock(ctx context.Context, saga domain.OrderSaga) (reserved []domain.ProductRequest, err error) {
+	reserved = append([]domain.ProductRequest(nil), saga.Items...)
+
+	var shortfalls []domain.StockShortfall
+	for i, item := range reserved {
+		payload := map[string]interface{}{
+			"product_id": item.ProductID,
+			"sku":        item.SKU,
+			"quantity":   item.Quantity,
+			"reference":  saga.ReserveReference(item),
+		}
+		result := struct {
+			ID         int64                   `json:"id"`
+			State      string                  `json:"state"`
+			Shortfalls []domain.StockShortfall `json:"shortfalls"`
+		}{}
+		status, err := u.postProductService(ctx, "/api/products/reservations", payload, &result)
+		if err != nil {
+			return reserved, err
+		}
+
+		switch status {
+		case http.StatusCreated:
+			// A reservation of a saga that stalled past its expiry no longer holds the stock
+			if result.State != domain.ReservationStateHeld {
+				return reserved, fmt.Errorf("stock reservation %d of product %d is %s", result.ID, item.ProductID, result.State)
+			}
+			reserved[i].ReservationID = result.ID
+		case http.StatusConflict:
+			// The other items are still reserved so every shortfall is reported at once
+			shortfalls = append(shortfalls, result.Shortfalls...)
+		default:
+			return reserved, fmt.Errorf("failed to reserve stock: status %d", status)
+		}
+	}
+
+	if len(shortfalls) > 0 {
+		err = &domain.OutOfStockError{Shortfalls: shortfalls}
+		log.Warn().Msgf("Order saga %s: %s", saga.ID, err)
+		return reserved, err
+	}
+
+	return reserved, nil
+}
+
+// releaseSagaStock releases the stock reservations of the items. Items without a reservation were reserved through
+// the batch reserve by sagas started before reservations, or never reserved, which the batch release skips.
+func (u *orderUsecase) releaseSagaStock(ctx context.Context, saga domain.OrderSaga) (err error) {
+	var items []map[string]interface{}
+	for _, item := range saga.Items {
+		if item.ReservationID == 0 {
+			items = append(items, map[string]interface{}{
+				"product_id":        item.ProductID,
+				"sku":               item.SKU,
+				"quantity":          item.Quantity,
+				"reference":         saga.ReleaseReference(item),
+				"reserve_reference": saga.ReserveReference(item),
+			})
+			continue
+		}
+
+		// A conflict means the reservation expired, which already gave its stock back
+		path := fmt.Sprintf("/api/products/reservations/%d/release", item.ReservationID)
+		status, err := u.postProductService(ctx, path, nil, nil)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK && status != http.StatusConflict {
+			return fmt.Errorf("failed to release stock reservation %d: status %d", item.ReservationID, status)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	status, err := u.postProductService(ctx, "/api/products/release/batch", map[string]interface{}{"items": items}, nil)
+	if err != nil {
+		return err
+	}
+
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to release stock: status %d", status)
+	}
+
+	return nil
+}
+
+// confirmOrderStock confirms the stock reservations of a paid order, so they no longer expire. When a reservation
+// already expired its stock is gone and ErrReservationExpired is returned.
+func (u *orderUsecase) confirmOrderStock(ctx context.Context, order domain.Order) (err error) {
+	for _, item := range order.ProductRequests {
+		if item.ReservationID == 0 {
+			continue
+		}
+
+		path := fmt.Sprintf("/api/products/reservations/%d/confirm", item.ReservationID)
+		status, err := u.postProductService(ctx, path, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case http.StatusOK:
+		case http.StatusConflict:
+			return fmt.Errorf("%w: reservation %d of product %d", domain.ErrReservationExpired, item.ReservationID, item.ProductID)
+		default:
+			return fmt.Errorf("failed to confirm stock reservation %d: status %d", item.ReservationID, status)
+		}
+	}
+
+	return nil
+}
+
+// lockSagaPrices fetches the current pricing of every item in one batch request and fixes the line prices.
+// The markup and discount of a line are amounts of its final price, so they add up over the order like the price.
+func (u *orderUsecase) lockSagaPrices(ctx context.Context, items []domain.ProductRequest) (priced []domain.ProductRequest, err error) {
+	lines := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		lines[i] = map[string]interface{}{
+			"product_id": item.ProductID,
+			"quantity":   item.Quantity,
+		}
+	}
+
+	cart := domain.CartPricing{}
+	status, err := u.postJSON(ctx, u.pricingServiceURL+"/api/pricing/batch", map[string]interface{}{"items": lines}, &cart)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error getting pricing for %d items", len(items))
+		return nil, err
+	}
+	if status != http.StatusOK || len(cart.Lines) != len(items) {
+		err = fmt.Errorf("failed to get pricing: status %d", status)
+		log.Error().Err(err).Msgf("Error getting pricing for %d items", len(items))
+		return nil, err
+	}
+
+	priced = make([]domain.ProductRequest, len(items))
+	for i, item := range items {
+		line := cart.Lines[i]
+		priced[i] = domain.ProductRequest{
+			ProductID:     item.ProductID,
+			SKU:           item.SKU,
+			Quantity:      item.Quantity,
+			ReservationID: item.ReservationID,
+			FinalPrice:    line.Total,
+			MarkUp:        line.MarkupAmount(),
+			Discount:      line.DiscountAmount(),
+		}
+	}
+
+	return priced, nil
+}
+
+// redeemSagaCoupon redeems the coupon of the saga against its locked line prices in pricing-service and
+// returns the discount it gives. The redemption is idempotent per saga.
+func (u *orderUsecase) redeemSagaCoupon(ctx context.Context, saga domain.OrderSaga) (discount float64, err error) {
+	items := make([]map[string]interface{}, len(saga.Items))
+	for i, item := range saga.Items {
+		items[i] = map[string]interface{}{
+			"product_id": item.ProductID,
+			"sku":        item.SKU,
+			"quantity":   item.Quantity,
+			"price":      item.FinalPrice,
+		}
+	}
+
+	payload := map[string]interface{}{
+		"code":      saga.CouponCode,
+		"user_id":   saga.UserID,
+		"reference": saga.CouponReference(),
+		"items":     items,
+	}
+	result := struct {
+		Discount float64 `json:"discount"`
+		Error    string  `json:"error"`
+	}{}
+	status, err := u.postJSON(ctx, u.pricingServiceURL+"/api/pricing/coupons/redeem", payload, &result)
+	if err != nil {
+		return 0, err
+	}
+
+	switch status {
+	case http.StatusOK:
+		return result.Discount, nil
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity:
+		err = fmt.Errorf("%w: %s", domain.ErrCouponNotApplicable, result.Error)
+		log.Warn().Msgf("Order saga %s: %s", saga.ID, err)
+		return 0, err
+	default:
+		return 0, fmt.Errorf("failed to redeem coupon: status %d", status)
+	}
+}
+
+// releaseSagaCoupon releases the coupon redemption of the saga in pricing-service.
+func (u *orderUsecase) releaseSagaCoupon(ctx context.Context, saga domain.OrderSaga) (err error) {
+	payload := map[string]interface{}{"reference": saga.CouponReference()}
+	status, err := u.postJSON(ctx, u.pricingServiceURL+"/api/pricing/coupons/release", payload, nil)
+	if err != nil {
+		return err
+	}
+
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to release coupon: status %d", status)
+	}
+
+	return nil
+}
+
+// buildSagaOrder builds the reserved order from the reserved and priced saga items, less the coupon discount.
+func buildSagaOrder(saga domain.OrderSaga) (order domain.Order) {
+	order.UserID = saga.UserID
+	order.Status = domain.OrderStatusReserved
+	order.IdempotentKey = saga.IdempotentKey
+	order.ProductRequests = saga.Items
+	for _, productRequest := range saga.Items {
+		order.TotalDiscount += productRequest.Discount
+		order.TotalMarkUp += productRequest.MarkUp
+		order.Total += productRequest.FinalPrice
+		order.Quantity += productRequest.Quantity
+	}
+	order.CouponCode = saga.CouponCode
+	order.CouponDiscount = saga.CouponDiscount
+	order.Total -= saga.CouponDiscount
+	return order
+}
+
+// postProductService sends a JSON POST to product-service and returns the response status.
+func (u *orderUsecase) postProductService(ctx context.Context, path string, payload, out interface{}) (status int, err error) {
+	return u.postJSON(ctx, u.productServiceURL+path, payload, out)
+}
+
+// postJSON sends a JSON POST authenticated as a service and returns the response status.
+// When out is set the response body is decoded into it, whatever the status.
+func (u *orderUsecase) postJSON(ctx context.Context, url string, payload, out interface{}) (status int, err error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := u.doServiceRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		if err != nil {
+			return resp.StatusCode, err
+		}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// doServiceRequest authenticates the request with a service token, so it also works for sagas
+// resumed in the background without a user request.
+func (u *orderUsecase) doServiceRequest(req *http.Request) (resp *http.Response, err error) {
+	token, err := utils.GenerateServiceToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return u.httpClient.Do(req)
+}
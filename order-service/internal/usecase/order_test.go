@@ -0,0 +1,292 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"order-service/config"
+	"order-service/domain"
+	repo "order-service/internal/repository/mysql"
+)
+
+// stubOrders holds a single order and records the status it is moved to.
+type stubOrders struct {
+	repo.OrderRepository
+	order   domain.Order
+	updated string
+}
+
+func (s *stubOrders) GetOrderByID(ctx context.Context, id int) (domain.Order, error) {
+	return s.order, nil
+}
+
+func (s *stubOrders) UpdateOrderStatus(ctx context.Context, req domain.Order, status string, changedBy int, eventType string) (domain.Order, error) {
+	s.updated = status
+	req.Status = status
+	return req, nil
+}
+
+// userContext carries the claims the JWT middleware puts in the context.
+func userContext(userID int, role string) context.Context {
+	ctx := context.WithValue(context.Background(), domain.UserIDlKey, userID)
+	ctx = context.WithValue(ctx, domain.UserNameKey, "user")
+	ctx = context.WithValue(ctx, domain.UserEmailKey, "user@example.com")
+	return context.WithValue(ctx, domain.UserRoleKey, role)
+}
+
+func TestUpdateOrderStatusByRole(t *testing.T) {
+	const ownerID = 7
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		from   string
+		status string
+		err    error
+	}{
+		{"customer cancels", userContext(ownerID, ""), domain.OrderStatusReserved, domain.OrderStatusCancelled, nil},
+		{"customer marks paid", userContext(ownerID, ""), domain.OrderStatusReserved, domain.OrderStatusPaid, domain.ErrStatusChangeForbidden},
+		{"customer marks fulfilled", userContext(ownerID, ""), domain.OrderStatusPaid, domain.OrderStatusFulfilled, domain.ErrStatusChangeForbidden},
+		{"customer refunds", userContext(ownerID, ""), domain.OrderStatusFulfilled, domain.OrderStatusRefunded, domain.ErrStatusChangeForbidden},
+		{"customer cancels another user's order", userContext(ownerID+1, ""), domain.OrderStatusReserved, domain.OrderStatusCancelled, domain.ErrOrderNotFound},
+		{"admin marks paid", userContext(1, domain.RoleAdmin), domain.OrderStatusReserved, domain.OrderStatusPaid, nil},
+		{"admin refunds", userContext(1, domain.RoleAdmin), domain.OrderStatusPaid, domain.OrderStatusRefunded, nil},
+		{"service marks fulfilled", userContext(0, domain.RoleService), domain.OrderStatusPaid, domain.OrderStatusFulfilled, nil},
+		{"service still follows the state machine", userContext(0, domain.RoleService), domain.OrderStatusCancelled, domain.OrderStatusPaid, &domain.InvalidTransitionError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders := &stubOrders{order: domain.Order{ID: 1, UserID: ownerID, Status: tt.from}}
+			u := &orderUsecase{repo: orders}
+
+			order, err := u.UpdateOrder(tt.ctx, domain.Order{ID: 1, Status: tt.status})
+
+			var invalidTransition *domain.InvalidTransitionError
+			switch {
+			case tt.err == nil:
+				if err != nil {
+					t.Fatalf("UpdateOrder: %v", err)
+				}
+				if order.Status != tt.status || orders.updated != tt.status {
+					t.Errorf("order is %s, want %s", order.Status, tt.status)
+				}
+			case errors.As(tt.err, &invalidTransition):
+				if !errors.As(err, &invalidTransition) {
+					t.Errorf("got %v, want an invalid transition", err)
+				}
+			default:
+				if !errors.Is(err, tt.err) {
+					t.Errorf("got %v, want %v", err, tt.err)
+				}
+			}
+
+			if tt.err != nil && orders.updated != "" {
+				t.Errorf("order was moved to %s", orders.updated)
+			}
+		})
+	}
+}
+
+// newServiceStub fakes product-service or pricing-service with handler, and configures the secret service tokens are signed with.
+func newServiceStub(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{Jwt: config.JwtConfig{Secret: "test-secret"}}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPayOrderConfirmsReservations(t *testing.T) {
+	order := domain.Order{ID: 1, UserID: 7, Status: domain.OrderStatusReserved, ProductRequests: []domain.ProductRequest{
+		{ProductID: 3, Quantity: 1, ReservationID: 11},
+		{ProductID: 4, Quantity: 2}, // reserved before reservations
+		{ProductID: 5, Quantity: 1, ReservationID: 12},
+	}}
+
+	tests := []struct {
+		name      string
+		expired   map[string]bool
+		confirmed []string
+		status    string
+		err       error
+	}{
+		{
+			name:      "all held",
+			confirmed: []string{"/api/products/reservations/11/confirm", "/api/products/reservations/12/confirm"},
+			status:    domain.OrderStatusPaid,
+		},
+		{
+			name:      "one expired",
+			expired:   map[string]bool{"/api/products/reservations/12/confirm": true},
+			confirmed: []string{"/api/products/reservations/11/confirm"},
+			status:    domain.OrderStatusCancelled,
+			err:       domain.ErrReservationExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var confirmed []string
+			server := newServiceStub(t, func(w http.ResponseWriter, r *http.Request) {
+				if tt.expired[r.URL.Path] {
+					w.WriteHeader(http.StatusConflict)
+					return
+				}
+				confirmed = append(confirmed, r.URL.Path)
+			})
+			orders := &stubOrders{order: order}
+			u := &orderUsecase{repo: orders, productServiceURL: server.URL, httpClient: server.Client()}
+
+			_, err := u.UpdateOrder(userContext(0, domain.RoleService), domain.Order{ID: 1, Status: domain.OrderStatusPaid})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if orders.updated != tt.status {
+				t.Errorf("order is %s, want %s", orders.updated, tt.status)
+			}
+			if len(confirmed) != len(tt.confirmed) {
+				t.Fatalf("confirmed %v, want %v", confirmed, tt.confirmed)
+			}
+			for i := range confirmed {
+				if confirmed[i] != tt.confirmed[i] {
+					t.Errorf("confirmed %v, want %v", confirmed, tt.confirmed)
+				}
+			}
+		})
+	}
+}
+
+func TestReserveSagaStockKeepsReservationsOfAShortOrder(t *testing.T) {
+	server := newServiceStub(t, func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			ProductID int `json:"product_id"`
+			Quantity  int `json:"quantity"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ProductID == 4 {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{"shortfalls": []domain.StockShortfall{{ProductID: 4, Requested: req.Quantity, Available: 1}}})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 10 + req.ProductID, "state": domain.ReservationStateHeld})
+	})
+	u := &orderUsecase{productServiceURL: server.URL, httpClient: server.Client()}
+
+	saga := domain.OrderSaga{ID: "saga", Items: []domain.ProductRequest{
+		{ProductID: 3, Quantity: 1},
+		{ProductID: 4, Quantity: 2},
+		{ProductID: 5, Quantity: 1},
+	}}
+	reserved, err := u.reserveSagaStock(context.Background(), saga)
+
+	var outOfStock *domain.OutOfStockError
+	if !errors.As(err, &outOfStock) || len(outOfStock.Shortfalls) != 1 || outOfStock.Shortfalls[0].ProductID != 4 {
+		t.Fatalf("got %v, want product 4 out of stock", err)
+	}
+	for i, want := range []int64{13, 0, 15} {
+		if reserved[i].ReservationID != want {
+			t.Errorf("item %d has reservation %d, want %d", i, reserved[i].ReservationID, want)
+		}
+	}
+}
+
+func TestLockSagaPricesStoresAmounts(t *testing.T) {
+	server := newServiceStub(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(domain.CartPricing{Lines: []domain.Pricing{
+			// 3 units of 100 with a 20% markup, one of them free with a bundle
+			{ProductID: 3, Quantity: 3, Markup: 0.2, FinalPrice: 120, Total: 240},
+			// 2 units of 100 with a 25% discount
+			{ProductID: 4, Quantity: 2, Discount: 0.25, FinalPrice: 75, Total: 150},
+		}})
+	})
+	u := &orderUsecase{pricingServiceURL: server.URL, httpClient: server.Client()}
+
+	saga := domain.OrderSaga{Items: []domain.ProductRequest{
+		{ProductID: 3, Quantity: 3, ReservationID: 11},
+		{ProductID: 4, Quantity: 2, ReservationID: 12},
+	}}
+	priced, err := u.lockSagaPrices(context.Background(), saga.Items)
+	if err != nil {
+		t.Fatalf("lockSagaPrices: %v", err)
+	}
+
+	want := []domain.ProductRequest{
+		{ProductID: 3, Quantity: 3, ReservationID: 11, FinalPrice: 240, MarkUp: 40},
+		{ProductID: 4, Quantity: 2, ReservationID: 12, FinalPrice: 150, Discount: 50},
+	}
+	for i := range want {
+		if !reflect.DeepEqual(priced[i], want[i]) {
+			t.Errorf("item %d is %+v, want %+v", i, priced[i], want[i])
+		}
+	}
+
+	saga.Items = priced
+	order := buildSagaOrder(saga)
+	if order.Total != 390 || order.TotalMarkUp != 40 || order.TotalDiscount != 50 {
+		t.Errorf("order total %v, markup %v, discount %v, want 390, 40 and 50", order.Total, order.TotalMarkUp, order.TotalDiscount)
+	}
+}
+
+// stubSagas holds a single saga.
+type stubSagas struct {
+	repo.SagaRepository
+	saga domain.OrderSaga
+}
+
+func (s *stubSagas) GetSaga(ctx context.Context, id string) (domain.OrderSaga, error) {
+	if id != s.saga.ID {
+		return domain.OrderSaga{}, sql.ErrNoRows
+	}
+	return s.saga, nil
+}
+
+func TestCancelExpiredOrder(t *testing.T) {
+	item := domain.ProductRequest{ProductID: 3, SKU: "red", Quantity: 1, ReservationID: 11}
+	saga := domain.OrderSaga{ID: "saga-1", OrderID: 1, Status: domain.SagaStatusCompleted, Items: []domain.ProductRequest{item}}
+
+	tests := []struct {
+		name        string
+		reference   string
+		reservation int64
+		sagaStatus  string
+		orderStatus string
+		cancelled   bool
+	}{
+		{"unpaid order", saga.ReserveReference(item), 11, domain.SagaStatusCompleted, domain.OrderStatusReserved, true},
+		{"paid order", saga.ReserveReference(item), 11, domain.SagaStatusCompleted, domain.OrderStatusPaid, false},
+		{"reservation of another order", saga.ReserveReference(item), 12, domain.SagaStatusCompleted, domain.OrderStatusReserved, false},
+		{"saga still running", saga.ReserveReference(item), 11, domain.SagaStatusPriceLocked, domain.OrderStatusReserved, false},
+		{"unknown saga", "saga:saga-2:reserve:3", 11, domain.SagaStatusCompleted, domain.OrderStatusReserved, false},
+		{"reservation of another caller", "cart:7:3", 11, domain.SagaStatusCompleted, domain.OrderStatusReserved, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sagaState := saga
+			sagaState.Status = tt.sagaStatus
+			orders := &stubOrders{order: domain.Order{ID: 1, UserID: 7, Status: tt.orderStatus, ProductRequests: []domain.ProductRequest{item}}}
+			u := &orderUsecase{repo: orders, sagaRepo: &stubSagas{saga: sagaState}}
+
+			err := u.CancelExpiredOrder(context.Background(), domain.ReservationEvent{
+				Type:          domain.ReservationEventExpired,
+				ReservationID: tt.reservation,
+				Reference:     tt.reference,
+			})
+			if err != nil {
+				t.Fatalf("CancelExpiredOrder: %v", err)
+			}
+			if cancelled := orders.updated == domain.OrderStatusCancelled; cancelled != tt.cancelled {
+				t.Errorf("order moved to %q, want cancelled %v", orders.updated, tt.cancelled)
+			}
+		})
+	}
+}
@@ -10,7 +10,7 @@ import (
 func AutoMigrateOrders(retries int, dbs ...*sql.DB) error {
 	query := `
 		CREATE TABLE IF NOT EXISTS orders (
-			id INT AUTO_INCREMENT PRIMARY KEY,
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
 			user_id INT NOT NULL,
 			quantity INT NOT NULL,
 			total DOUBLE NOT NULL,
@@ -49,12 +49,14 @@ func AutoMigrateProductRequests(retries int, dbs ...*sql.DB) error {
 	query := `
 		CREATE TABLE IF NOT EXISTS product_requests (
 			id INT AUTO_INCREMENT PRIMARY KEY,
-			order_id INT NOT NULL,
+			order_id BIGINT NOT NULL,
 			product_id INT NOT NULL,
+			sku VARCHAR(64) NULL,
 			quantity INT NOT NULL,
 			mark_up DOUBLE NOT NULL,
 			discount DOUBLE NOT NULL,
 			final_price DOUBLE NOT NULL,
+			reservation_id BIGINT NULL,
 			FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
 		);
 	`
@@ -80,3 +82,352 @@ func AutoMigrateProductRequests(retries int, dbs ...*sql.DB) error {
 	}
 	return nil
 }
+
+// AutoMigrateOrderOutbox creates the order_outbox table used by the outbox relay if it does not exist.
+func AutoMigrateOrderOutbox(retries int, dbs ...*sql.DB) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS order_outbox (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			event_id VARCHAR(36) UNIQUE NOT NULL,
+			event_type VARCHAR(50) NOT NULL,
+			schema_version INT NOT NULL,
+			aggregate_id BIGINT NOT NULL,
+			payload JSON NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			last_error TEXT NULL,
+			claimed_by VARCHAR(36) NULL,
+			next_attempt_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+			created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+			sent_at DATETIME(3) NULL,
+			INDEX idx_order_outbox_status_next_attempt (status, next_attempt_at),
+			INDEX idx_order_outbox_claimed_by (claimed_by),
+			INDEX idx_order_outbox_aggregate_status (aggregate_id, status)
+		);
+	`
+	for shardIndex, db := range dbs {
+		_, err := db.Exec(query)
+		if err != nil {
+			// Retry jika gagal
+			for i := 0; i < retries; i++ {
+				time.Sleep(1 * time.Second)
+				_, err = db.Exec(query)
+				if err == nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate order_outbox on shard %d: %w", shardIndex, err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrateOrderOutboxAggregateIndex adds the index the relay uses to find earlier pending events of an order
+// to order_outbox tables created before it existed.
+func AutoMigrateOrderOutboxAggregateIndex(dbs ...*sql.DB) error {
+	for shardIndex, db := range dbs {
+		exists, err := indexExists(db, "order_outbox", "idx_order_outbox_aggregate_status")
+		if err != nil {
+			return fmt.Errorf("failed to inspect order_outbox on shard %d: %w", shardIndex, err)
+		}
+		if exists {
+			continue
+		}
+
+		_, err = db.Exec(`ALTER TABLE order_outbox ADD INDEX idx_order_outbox_aggregate_status (aggregate_id, status)`)
+		if err != nil {
+			return fmt.Errorf("failed to add aggregate index to order_outbox on shard %d: %w", shardIndex, err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrateOrderSagas creates the order_sagas table holding the state of order creation sagas if it does not exist.
+func AutoMigrateOrderSagas(retries int, dbs ...*sql.DB) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS order_sagas (
+			id VARCHAR(36) PRIMARY KEY,
+			user_id INT NOT NULL,
+			order_id BIGINT NULL,
+			status VARCHAR(20) NOT NULL,
+			items JSON NOT NULL,
+			idempotent_key VARCHAR(255) NOT NULL,
+			error TEXT NULL,
+			created_at DATETIME(3) NOT NULL,
+			updated_at DATETIME(3) NOT NULL,
+			INDEX idx_order_sagas_status_updated_at (status, updated_at)
+		);
+	`
+	for shardIndex, db := range dbs {
+		_, err := db.Exec(query)
+		if err != nil {
+			// Retry jika gagal
+			for i := 0; i < retries; i++ {
+				time.Sleep(1 * time.Second)
+				_, err = db.Exec(query)
+				if err == nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate order_sagas on shard %d: %w", shardIndex, err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrateOrderStatusHistory creates the order_status_history table recording every status change if it does not exist.
+func AutoMigrateOrderStatusHistory(retries int, dbs ...*sql.DB) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS order_status_history (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			order_id BIGINT NOT NULL,
+			from_status VARCHAR(20) NOT NULL,
+			to_status VARCHAR(20) NOT NULL,
+			changed_by INT NOT NULL,
+			changed_at DATETIME(3) NOT NULL,
+			INDEX idx_order_status_history_order_id (order_id)
+		);
+	`
+	for shardIndex, db := range dbs {
+		_, err := db.Exec(query)
+		if err != nil {
+			// Retry jika gagal
+			for i := 0; i < retries; i++ {
+				time.Sleep(1 * time.Second)
+				_, err = db.Exec(query)
+				if err == nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate order_status_history on shard %d: %w", shardIndex, err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrateOrderMoves creates the order_moves table if it does not exist. When resharding moves an order
+// off a shard, the shard keeps a row pointing to the new shard so lookups by the shard encoded in the ID still find it.
+func AutoMigrateOrderMoves(retries int, dbs ...*sql.DB) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS order_moves (
+			order_id BIGINT PRIMARY KEY,
+			user_id INT NOT NULL,
+			to_shard INT NOT NULL,
+			moved_at DATETIME(3) NOT NULL
+		);
+	`
+	for shardIndex, db := range dbs {
+		_, err := db.Exec(query)
+		if err != nil {
+			// Retry jika gagal
+			for i := 0; i < retries; i++ {
+				time.Sleep(1 * time.Second)
+				_, err = db.Exec(query)
+				if err == nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate order_moves on shard %d: %w", shardIndex, err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrateOrderCreatedAt adds the created_at column and the index used to list orders by user and date
+// to orders tables created before the column existed.
+func AutoMigrateOrderCreatedAt(dbs ...*sql.DB) error {
+	for shardIndex, db := range dbs {
+		exists, err := columnExists(db, "orders", "created_at")
+		if err != nil {
+			return fmt.Errorf("failed to inspect orders on shard %d: %w", shardIndex, err)
+		}
+		if exists {
+			continue
+		}
+
+		_, err = db.Exec(`ALTER TABLE orders
+			ADD COLUMN created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+			ADD INDEX idx_orders_user_id_created_at (user_id, created_at, id),
+			ADD INDEX idx_orders_created_at (created_at, id)`)
+		if err != nil {
+			return fmt.Errorf("failed to add created_at to orders on shard %d: %w", shardIndex, err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrateProductRequestSKU adds the variant SKU column to product_requests tables created before it existed.
+func AutoMigrateProductRequestSKU(dbs ...*sql.DB) error {
+	for shardIndex, db := range dbs {
+		exists, err := columnExists(db, "product_requests", "sku")
+		if err != nil {
+			return fmt.Errorf("failed to inspect product_requests on shard %d: %w", shardIndex, err)
+		}
+		if exists {
+			continue
+		}
+
+		_, err = db.Exec(`ALTER TABLE product_requests ADD COLUMN sku VARCHAR(64) NULL AFTER product_id`)
+		if err != nil {
+			return fmt.Errorf("failed to add sku to product_requests on shard %d: %w", shardIndex, err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrateProductRequestReservations adds the stock reservation of an item to product_requests tables created before reservations existed.
+func AutoMigrateProductRequestReservations(dbs ...*sql.DB) error {
+	for shardIndex, db := range dbs {
+		exists, err := columnExists(db, "product_requests", "reservation_id")
+		if err != nil {
+			return fmt.Errorf("failed to inspect product_requests on shard %d: %w", shardIndex, err)
+		}
+		if exists {
+			continue
+		}
+
+		_, err = db.Exec(`ALTER TABLE product_requests ADD COLUMN reservation_id BIGINT NULL AFTER final_price`)
+		if err != nil {
+			return fmt.Errorf("failed to add reservation_id to product_requests on shard %d: %w", shardIndex, err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrateOrderCoupons adds the coupon applied to an order to orders and order_sagas tables created before coupons existed.
+func AutoMigrateOrderCoupons(dbs ...*sql.DB) error {
+	for shardIndex, db := range dbs {
+		for _, table := range []string{"orders", "order_sagas"} {
+			exists, err := columnExists(db, table, "coupon_code")
+			if err != nil {
+				return fmt.Errorf("failed to inspect %s on shard %d: %w", table, shardIndex, err)
+			}
+			if exists {
+				continue
+			}
+
+			_, err = db.Exec(`ALTER TABLE ` + table + `
+				ADD COLUMN coupon_code VARCHAR(32) NULL,
+				ADD COLUMN coupon_discount DOUBLE NOT NULL DEFAULT 0`)
+			if err != nil {
+				return fmt.Errorf("failed to add coupon columns to %s on shard %d: %w", table, shardIndex, err)
+			}
+		}
+	}
+	return nil
+}
+
+// AutoMigrateOrderSagaLeases adds the lease of the worker driving a saga to order_sagas tables created before leases existed.
+func AutoMigrateOrderSagaLeases(dbs ...*sql.DB) error {
+	for shardIndex, db := range dbs {
+		exists, err := columnExists(db, "order_sagas", "lease_owner")
+		if err != nil {
+			return fmt.Errorf("failed to inspect order_sagas on shard %d: %w", shardIndex, err)
+		}
+		if exists {
+			continue
+		}
+
+		_, err = db.Exec(`ALTER TABLE order_sagas
+			ADD COLUMN lease_owner VARCHAR(36) NULL,
+			ADD COLUMN lease_expires_at DATETIME(3) NULL,
+			ADD INDEX idx_order_sagas_status_lease_expires_at (status, lease_expires_at)`)
+		if err != nil {
+			return fmt.Errorf("failed to add lease columns to order_sagas on shard %d: %w", shardIndex, err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrateOrderIDsToBigint widens order ID columns created as INT to BIGINT so they can hold
+// snowflake order IDs. The product_requests foreign key is dropped while the columns change.
+func AutoMigrateOrderIDsToBigint(dbs ...*sql.DB) error {
+	columns := []struct{ table, column, definition string }{
+		{"orders", "id", "BIGINT NOT NULL AUTO_INCREMENT"},
+		{"product_requests", "order_id", "BIGINT NOT NULL"},
+		{"order_outbox", "aggregate_id", "BIGINT NOT NULL"},
+		{"order_sagas", "order_id", "BIGINT NULL"},
+		{"order_status_history", "order_id", "BIGINT NOT NULL"},
+	}
+
+	for shardIndex, db := range dbs {
+		foreignKey, err := productRequestsForeignKey(db)
+		if err != nil {
+			return fmt.Errorf("failed to inspect product_requests on shard %d: %w", shardIndex, err)
+		}
+
+		for _, c := range columns {
+			dataType, err := columnType(db, c.table, c.column)
+			if err != nil {
+				return fmt.Errorf("failed to inspect %s on shard %d: %w", c.table, shardIndex, err)
+			}
+			if dataType == "bigint" {
+				continue
+			}
+
+			// MySQL refuses to change the type of columns used by a foreign key
+			if foreignKey != "" {
+				_, err = db.Exec(fmt.Sprintf("ALTER TABLE product_requests DROP FOREIGN KEY `%s`", foreignKey))
+				if err != nil {
+					return fmt.Errorf("failed to drop product_requests foreign key on shard %d: %w", shardIndex, err)
+				}
+				foreignKey = ""
+			}
+
+			_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY %s %s", c.table, c.column, c.definition))
+			if err != nil {
+				return fmt.Errorf("failed to widen %s.%s on shard %d: %w", c.table, c.column, shardIndex, err)
+			}
+		}
+
+		if foreignKey == "" {
+			_, err = db.Exec(`ALTER TABLE product_requests ADD FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE`)
+			if err != nil {
+				return fmt.Errorf("failed to add product_requests foreign key on shard %d: %w", shardIndex, err)
+			}
+		}
+	}
+	return nil
+}
+
+// productRequestsForeignKey returns the name of the product_requests foreign key to orders, or "" if there is none.
+func productRequestsForeignKey(db *sql.DB) (name string, err error) {
+	query := `SELECT CONSTRAINT_NAME FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'product_requests' AND COLUMN_NAME = 'order_id' AND REFERENCED_TABLE_NAME = 'orders'`
+	err = db.QueryRow(query).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return name, err
+}
+
+// columnType returns the lower-case data type of the column, e.g. "int" or "bigint".
+func columnType(db *sql.DB, table, column string) (dataType string, err error) {
+	query := `SELECT LOWER(DATA_TYPE) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+	err = db.QueryRow(query, table, column).Scan(&dataType)
+	return dataType, err
+}
+
+// columnExists reports whether the table in the current database has the column.
+func columnExists(db *sql.DB, table, column string) (exists bool, err error) {
+	query := `SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+	var count int
+	err = db.QueryRow(query, table, column).Scan(&count)
+	return count > 0, err
+}
+
+// indexExists reports whether the table in the current database has the index.
+func indexExists(db *sql.DB, table, index string) (exists bool, err error) {
+	query := `SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`
+	var count int
+	err = db.QueryRow(query, table, index).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"time"
+
+	"order-service/config"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// serviceTokenTTL is how long tokens for calls to other services stay valid
+const serviceTokenTTL = 5 * time.Minute
+
+type serviceClaims struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// GenerateServiceToken membuat token JWT berumur pendek untuk memanggil service lain
+// tanpa request user, misalnya saat saga dilanjutkan di background
+func GenerateServiceToken() (tokenString string, err error) {
+	claims := &serviceClaims{
+		Username: "order-service",
+		Role:     "service",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(serviceTokenTTL)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.AppConfig.Jwt.Secret))
+}
@@ -2,7 +2,9 @@ package app
 
 import (
 	"database/sql"
+	"time"
 
+	"pricing-service/config"
 	"pricing-service/internal/delivery/rest"
 	repo "pricing-service/internal/repository/mysql"
 	cache "pricing-service/internal/repository/redis"
@@ -14,10 +16,18 @@ import (
 
 func NewApp(router *mux.Router, db *sql.DB, rdb *redis.Client) {
 	pricingRepo := repo.NewPricingRepository(db)
-	pricingCache := cache.NewPricingCache(rdb)
-	pricingUsecase := usecase.NewPricingUsecase(pricingRepo, pricingCache, "http://localhost:8001")
+	pricingCache := cache.NewPricingCache(rdb, config.AppConfig.Cache)
+	promotionRepo := repo.NewPromotionRepository(db)
+	pricingUsecase := usecase.NewPricingUsecase(pricingRepo, promotionRepo, pricingCache, "http://localhost:8001", time.Now)
+
+	pricingRuleUsecase := usecase.NewPricingRuleUsecase(pricingRepo, pricingCache)
+	promotionUsecase := usecase.NewPromotionUsecase(promotionRepo)
+	couponUsecase := usecase.NewCouponUsecase(repo.NewCouponRepository(db), pricingUsecase, "http://localhost:8001", time.Now)
 
 	pricingHandler := rest.NewPricingHandler(pricingUsecase)
+	pricingRuleHandler := rest.NewPricingRuleHandler(pricingRuleUsecase)
+	promotionHandler := rest.NewPromotionHandler(promotionUsecase)
+	couponHandler := rest.NewCouponHandler(couponUsecase)
 
-	rest.RegisterRoutes(router, pricingHandler)
+	rest.RegisterRoutes(router, pricingHandler, pricingRuleHandler, promotionHandler, couponHandler)
 }
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,6 +18,7 @@ type Config struct {
 	Redis  RedisConfig
 	Jwt    JwtConfig
 	Log    LogConfig
+	Cache  CacheConfig
 }
 
 type ServerConfig struct {
@@ -47,6 +49,11 @@ type LogConfig struct {
 	LogFilePath    string
 }
 
+type CacheConfig struct {
+	PricingRuleTTL time.Duration
+	Jitter         float64 // share of the TTL added at random so entries do not expire together
+}
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() {
 	// Load .env file if it exists
@@ -80,6 +87,8 @@ func LoadConfig() {
 	}
 
 	AppConfig.Log.LogFileEnabled, _ = strconv.ParseBool(getEnv("LOG_FILE_ENABLED", "true"))
+	AppConfig.Cache.PricingRuleTTL, _ = time.ParseDuration(getEnv("CACHE_PRICING_RULE_TTL", "5m"))
+	AppConfig.Cache.Jitter, _ = strconv.ParseFloat(getEnv("CACHE_TTL_JITTER", "0.1"), 64)
 
 }
 
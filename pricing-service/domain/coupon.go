@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	CouponPercentage = "percentage" // takes Value, a fraction, off the eligible lines
+	CouponFixed      = "fixed"      // takes the amount Value off the eligible lines
+)
+
+var (
+	ErrCouponNotFound      = errors.New("coupon not found")
+	ErrInvalidCoupon       = errors.New("invalid coupon")
+	ErrCouponExists        = errors.New("coupon code already exists")
+	ErrCouponInUse         = errors.New("coupon has been redeemed, expire it instead")
+	ErrCouponNotApplicable = errors.New("coupon not applicable")
+	ErrInvalidCart         = errors.New("invalid cart")
+)
+
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// NormalizeCouponCode makes codes case-insensitive: they are stored and looked up uppercased.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Coupon is a discount code a user applies at checkout. It is scoped to a product, to the products
+// directly in a category, or to the whole cart when neither is set. MaxUses and MaxUsesPerUser of 0 are unlimited.
+type Coupon struct {
+	ID             int        `json:"id"`
+	Code           string     `json:"code"`
+	Type           string     `json:"type"`
+	Value          float64    `json:"value"`
+	MinOrderValue  float64    `json:"min_order_value"` // subtotal of the whole cart the coupon needs
+	MaxUses        int        `json:"max_uses"`
+	MaxUsesPerUser int        `json:"max_uses_per_user"`
+	UsedCount      int        `json:"used_count"`
+	ProductID      int        `json:"product_id,omitempty"`
+	CategoryID     int        `json:"category_id,omitempty"`
+	StartsAt       time.Time  `json:"starts_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// Validate checks the fields an admin may set on a coupon. Errors match ErrInvalidCoupon.
+func (c Coupon) Validate() error {
+	if !couponCodePattern.MatchString(c.Code) {
+		return fmt.Errorf("%w: code must be 3 to 32 letters, digits, '-' or '_'", ErrInvalidCoupon)
+	}
+
+	switch c.Type {
+	case CouponPercentage:
+		if c.Value <= 0 || c.Value > 1 {
+			return fmt.Errorf("%w: a percentage value must be above 0 and at most 1", ErrInvalidCoupon)
+		}
+	case CouponFixed:
+		if c.Value <= 0 {
+			return fmt.Errorf("%w: a fixed value must be positive", ErrInvalidCoupon)
+		}
+	default:
+		return fmt.Errorf("%w: type must be %s or %s", ErrInvalidCoupon, CouponPercentage, CouponFixed)
+	}
+
+	if c.MinOrderValue < 0 {
+		return fmt.Errorf("%w: min_order_value must not be negative", ErrInvalidCoupon)
+	}
+	if c.MaxUses < 0 || c.MaxUsesPerUser < 0 {
+		return fmt.Errorf("%w: usage limits must not be negative", ErrInvalidCoupon)
+	}
+	if c.ProductID < 0 || c.CategoryID < 0 || (c.ProductID != 0 && c.CategoryID != 0) {
+		return fmt.Errorf("%w: at most one of product_id and category_id may be set", ErrInvalidCoupon)
+	}
+	if c.ExpiresAt != nil && !c.ExpiresAt.After(c.StartsAt) {
+		return fmt.Errorf("%w: expires_at must be after starts_at", ErrInvalidCoupon)
+	}
+	return nil
+}
+
+// ActiveAt reports whether the coupon can be used at t. StartsAt is inclusive, ExpiresAt exclusive.
+func (c Coupon) ActiveAt(t time.Time) bool {
+	return !t.Before(c.StartsAt) && (c.ExpiresAt == nil || t.Before(*c.ExpiresAt))
+}
+
+// CartItem is a line of a cart a coupon is applied to. Price is the price of the whole line.
+type CartItem struct {
+	ProductID int     `json:"product_id"`
+	SKU       string  `json:"sku,omitempty"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+}
+
+// CouponApplication is the outcome of applying a coupon to a cart.
+type CouponApplication struct {
+	CouponID         int     `json:"coupon_id"`
+	Code             string  `json:"code"`
+	Subtotal         float64 `json:"subtotal"`          // price of the whole cart
+	EligibleSubtotal float64 `json:"eligible_subtotal"` // price of the lines in the coupon's scope
+	Discount         float64 `json:"discount"`
+	Total            float64 `json:"total"`
+}
+
+// Apply applies the coupon to the cart at now for a user who redeemed it userUses times before. categories
+// maps the products of the cart to their category and is only needed for category coupons. Errors match
+// ErrCouponNotApplicable. A fixed discount never exceeds the eligible subtotal.
+func (c Coupon) Apply(items []CartItem, categories map[int]int, userUses int, now time.Time) (application CouponApplication, err error) {
+	if !c.ActiveAt(now) {
+		return application, fmt.Errorf("%w: coupon %s is not active", ErrCouponNotApplicable, c.Code)
+	}
+	if c.MaxUses > 0 && c.UsedCount >= c.MaxUses {
+		return application, fmt.Errorf("%w: coupon %s has been used up", ErrCouponNotApplicable, c.Code)
+	}
+	if c.MaxUsesPerUser > 0 && userUses >= c.MaxUsesPerUser {
+		return application, fmt.Errorf("%w: coupon %s was already used the maximum number of times", ErrCouponNotApplicable, c.Code)
+	}
+
+	application = CouponApplication{CouponID: c.ID, Code: c.Code}
+	for _, item := range items {
+		application.Subtotal += item.Price
+		if c.inScope(item.ProductID, categories) {
+			application.EligibleSubtotal += item.Price
+		}
+	}
+
+	if application.Subtotal < c.MinOrderValue {
+		return application, fmt.Errorf("%w: coupon %s needs an order of at least %g", ErrCouponNotApplicable, c.Code, c.MinOrderValue)
+	}
+	if application.EligibleSubtotal <= 0 {
+		return application, fmt.Errorf("%w: coupon %s does not apply to any product in the cart", ErrCouponNotApplicable, c.Code)
+	}
+
+	switch c.Type {
+	case CouponPercentage:
+		application.Discount = application.EligibleSubtotal * c.Value
+	case CouponFixed:
+		application.Discount = math.Min(c.Value, application.EligibleSubtotal)
+	}
+	application.Total = application.Subtotal - application.Discount
+	return application, nil
+}
+
+func (c Coupon) inScope(productID int, categories map[int]int) bool {
+	switch {
+	case c.ProductID != 0:
+		return productID == c.ProductID
+	case c.CategoryID != 0:
+		return categories[productID] == c.CategoryID
+	default:
+		return true
+	}
+}
+
+// CouponCart is a cart a user applies a coupon code to. UserID comes from the caller, never from the payload.
+type CouponCart struct {
+	Code   string     `json:"code"`
+	UserID int        `json:"-"`
+	Items  []CartItem `json:"items"`
+}
+
+// CouponRedemption records a use of a coupon. Reference identifies the checkout that used it, so
+// redeeming again under the same reference returns the earlier redemption.
+type CouponRedemption struct {
+	ID        int64     `json:"id"`
+	CouponID  int       `json:"coupon_id"`
+	Code      string    `json:"code"`
+	UserID    int       `json:"user_id"`
+	Reference string    `json:"reference"`
+	Discount  float64   `json:"discount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CouponPage is a page of coupons with the total number of coupons.
+type CouponPage struct {
+	Coupons []Coupon `json:"coupons"`
+	Total   int      `json:"total"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+}
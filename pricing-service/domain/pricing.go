@@ -1,5 +1,25 @@
 package domain
 
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxMarkup caps markups at ten times the product price
+const MaxMarkup = 10.0
+
+// MaxPricingBatchItems caps the number of lines priced in one batch
+const MaxPricingBatchItems = 100
+
+var (
+	ErrPricingRuleNotFound = errors.New("pricing rule not found")
+	ErrInvalidPricingRule  = errors.New("invalid pricing rule")
+	ErrPricingRuleExists   = errors.New("pricing rule already exists")
+	ErrInvalidPricingBatch = fmt.Errorf("a pricing batch must have between 1 and %d items with a product_id and a positive quantity", MaxPricingBatchItems)
+	ErrInvalidQuantity     = errors.New("quantity must be positive")
+)
+
+// PricingRule prices a product. Markups and discounts are fractions of the price, e.g. 0.2 for 20%.
 type PricingRule struct {
 	ID                int     `json:"id"`
 	ProductID         int     `json:"product_id"`
@@ -7,14 +27,115 @@ type PricingRule struct {
 	DefaultMarkup     float64 `json:"default_markup"`
 	DefaultDiscount   float64 `json:"default_discount"`
 	StockThreshold    int     `json:"stock_threshold"`    // If stock is less than this, apply price adjustments
-	MarkupIncrease    float64 `json:"markup_increase"`    // Increase markup by this percentage
-	DiscountReduction float64 `json:"discount_reduction"` // Reduce discount by this percentage
+	MarkupIncrease    float64 `json:"markup_increase"`    // Increase markup by this fraction
+	DiscountReduction float64 `json:"discount_reduction"` // Reduce discount by this fraction
+
+	// Quantity pricing, applied to the unit price after markups, discounts and promotions
+	Tiers  []QuantityTier `json:"tiers,omitempty"`  // quantity breaks, by ascending min_quantity
+	Bundle *Bundle        `json:"bundle,omitempty"` // buy X get Y free
+}
+
+// QuantityTier takes Discount, a fraction, off the unit price when at least MinQuantity units are bought.
+type QuantityTier struct {
+	MinQuantity int     `json:"min_quantity"`
+	Discount    float64 `json:"discount"`
+}
+
+// Bundle gives FreeQuantity units away for every BuyQuantity units paid for, e.g. buy 2 get 1 free.
+type Bundle struct {
+	BuyQuantity  int `json:"buy_quantity"`
+	FreeQuantity int `json:"free_quantity"`
+}
+
+// FreeUnits returns how many of quantity units are free: FreeQuantity of every complete group of
+// BuyQuantity+FreeQuantity units.
+func (b Bundle) FreeUnits(quantity int) int {
+	return quantity / (b.BuyQuantity + b.FreeQuantity) * b.FreeQuantity
 }
 
-// Pricing represents the pricing data for a product.
+// TierFor returns the tier applying to quantity units, the one with the highest MinQuantity not above
+// it, or nil when quantity is below every tier.
+func (r PricingRule) TierFor(quantity int) *QuantityTier {
+	var applied *QuantityTier
+	for i := range r.Tiers {
+		if r.Tiers[i].MinQuantity <= quantity {
+			applied = &r.Tiers[i]
+		}
+	}
+	return applied
+}
+
+// Validate checks the rule prices its product at a positive price whether stock is low or not.
+// Errors match ErrInvalidPricingRule.
+func (r PricingRule) Validate() error {
+	if r.ProductID <= 0 {
+		return fmt.Errorf("%w: product_id is required", ErrInvalidPricingRule)
+	}
+	if r.ProductPrice <= 0 {
+		return fmt.Errorf("%w: product_price must be positive", ErrInvalidPricingRule)
+	}
+	if r.DefaultMarkup < 0 || r.DefaultMarkup > MaxMarkup {
+		return fmt.Errorf("%w: default_markup must be between 0 and %g", ErrInvalidPricingRule, MaxMarkup)
+	}
+	if r.MarkupIncrease < 0 || r.DefaultMarkup+r.MarkupIncrease > MaxMarkup {
+		return fmt.Errorf("%w: markup_increase must not be negative or raise the markup above %g", ErrInvalidPricingRule, MaxMarkup)
+	}
+	if r.DefaultDiscount < 0 || r.DefaultDiscount >= 1 {
+		return fmt.Errorf("%w: default_discount must be at least 0 and below 1", ErrInvalidPricingRule)
+	}
+	if r.DiscountReduction < 0 || r.DiscountReduction > r.DefaultDiscount {
+		return fmt.Errorf("%w: discount_reduction must be between 0 and default_discount", ErrInvalidPricingRule)
+	}
+	if r.StockThreshold < 0 {
+		return fmt.Errorf("%w: stock_threshold must not be negative", ErrInvalidPricingRule)
+	}
+	for i, tier := range r.Tiers {
+		if tier.MinQuantity < 2 || (i > 0 && tier.MinQuantity <= r.Tiers[i-1].MinQuantity) {
+			return fmt.Errorf("%w: tiers must have a min_quantity of at least 2, in ascending order", ErrInvalidPricingRule)
+		}
+		if tier.Discount <= 0 || tier.Discount >= 1 {
+			return fmt.Errorf("%w: a tier discount must be above 0 and below 1", ErrInvalidPricingRule)
+		}
+	}
+	if r.Bundle != nil && (r.Bundle.BuyQuantity < 1 || r.Bundle.FreeQuantity < 1) {
+		return fmt.Errorf("%w: a bundle needs a buy_quantity and a free_quantity of at least 1", ErrInvalidPricingRule)
+	}
+	return nil
+}
+
+// PricingRulePage is a page of pricing rules with the total number of rules.
+type PricingRulePage struct {
+	Rules  []PricingRule `json:"rules"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+// Pricing represents the pricing data for a quantity of a product. FinalPrice is the price of one unit;
+// the quantity tier and bundle reached by Quantity are applied on top of it to give Total.
 type Pricing struct {
-	ProductID  int     `json:"product_id"`
-	Markup     float64 `json:"markup"`      // Markup percentage
-	Discount   float64 `json:"discount"`    // Discount percentage
-	FinalPrice float64 `json:"final_price"` // Calculated final price
+	ProductID  int           `json:"product_id"`
+	Quantity   int           `json:"quantity"`
+	Markup     float64       `json:"markup"`               // Markup fraction
+	Discount   float64       `json:"discount"`             // Discount fraction
+	FinalPrice float64       `json:"final_price"`          // Calculated final price
+	Promotions []Promotion   `json:"promotions,omitempty"` // promotions included in the final price
+	Tier       *QuantityTier `json:"tier,omitempty"`       // quantity tier applied to the unit price
+	UnitPrice  float64       `json:"unit_price"`           // FinalPrice less the tier discount
+	Bundle     *Bundle       `json:"bundle,omitempty"`     // bundle giving units away, if any
+	FreeUnits  int           `json:"free_units,omitempty"`
+	Total      float64       `json:"total"` // UnitPrice for every unit that is not free
+}
+
+// PricingItem is a line of a cart to price: a quantity of a product.
+type PricingItem struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// CartPricing prices every line of a cart, in the order of the request, and the cart as a whole.
+type CartPricing struct {
+	Lines    []Pricing `json:"lines"`
+	Quantity int       `json:"quantity"`
+	Total    float64   `json:"total"`
 }
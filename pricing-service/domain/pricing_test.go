@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPricingRuleValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  PricingRule
+		valid bool
+	}{
+		{"fractions", PricingRule{ProductID: 1, ProductPrice: 100, DefaultMarkup: 0.2, DefaultDiscount: 0.03, MarkupIncrease: 0.05, DiscountReduction: 0.015}, true},
+		// Seeded rules 1 and 9 as migration 000006 converts them
+		{"converted seed rule", PricingRule{ProductID: 1, ProductPrice: 14500000, DefaultMarkup: 0.2, DefaultDiscount: 0.03, StockThreshold: 10, MarkupIncrease: 0.05, DiscountReduction: 0.015}, true},
+		{"converted seed rule with capped reduction", PricingRule{ProductID: 9, ProductPrice: 32000000, DefaultMarkup: 0.25, DefaultDiscount: 0.02, StockThreshold: 5, MarkupIncrease: 0.06, DiscountReduction: 0.02}, true},
+		{"percent discount", PricingRule{ProductID: 1, ProductPrice: 100, DefaultMarkup: 0.2, DefaultDiscount: 3}, false},
+		{"percent markup", PricingRule{ProductID: 1, ProductPrice: 100, DefaultMarkup: 20}, false},
+		{"reduction above the discount", PricingRule{ProductID: 1, ProductPrice: 100, DefaultDiscount: 0.02, DiscountReduction: 0.025}, false},
+		{"no price", PricingRule{ProductID: 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidPricingRule) {
+				t.Errorf("got %v, want %v", err, ErrInvalidPricingRule)
+			}
+		})
+	}
+}
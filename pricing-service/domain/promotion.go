@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	PromotionDiscount    = "discount"     // takes Discount off the calculated price
+	PromotionPriceChange = "price_change" // replaces the product price of the pricing rule with Price
+)
+
+const MaxPromotionNameLength = 100
+
+var (
+	ErrPromotionNotFound = errors.New("promotion not found")
+	ErrInvalidPromotion  = errors.New("invalid promotion")
+)
+
+// Promotion changes the price of a product, or of every product directly in a category, between StartsAt and
+// EndsAt. Without EndsAt it runs until deleted, which makes a price change permanent.
+type Promotion struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	ProductID  int        `json:"product_id,omitempty"`
+	CategoryID int        `json:"category_id,omitempty"`
+	Discount   float64    `json:"discount,omitempty"` // fraction of the price, e.g. 0.2 for 20% off
+	Price      float64    `json:"price,omitempty"`
+	Priority   int        `json:"priority"`  // higher goes first
+	Stackable  bool       `json:"stackable"` // whether the discount combines with other stackable discounts
+	StartsAt   time.Time  `json:"starts_at"`
+	EndsAt     *time.Time `json:"ends_at,omitempty"`
+}
+
+// Validate checks the fields an admin may set on a promotion. Errors match ErrInvalidPromotion.
+func (p Promotion) Validate() error {
+	name := strings.TrimSpace(p.Name)
+	if name == "" || utf8.RuneCountInString(name) > MaxPromotionNameLength {
+		return fmt.Errorf("%w: name must be between 1 and %d characters", ErrInvalidPromotion, MaxPromotionNameLength)
+	}
+	if p.ProductID < 0 || p.CategoryID < 0 || (p.ProductID == 0) == (p.CategoryID == 0) {
+		return fmt.Errorf("%w: exactly one of product_id and category_id is required", ErrInvalidPromotion)
+	}
+
+	switch p.Type {
+	case PromotionDiscount:
+		if p.Discount <= 0 || p.Discount >= 1 {
+			return fmt.Errorf("%w: discount must be above 0 and below 1", ErrInvalidPromotion)
+		}
+		if p.Price != 0 {
+			return fmt.Errorf("%w: a discount does not set a price", ErrInvalidPromotion)
+		}
+	case PromotionPriceChange:
+		if p.ProductID == 0 {
+			return fmt.Errorf("%w: a price change applies to a single product", ErrInvalidPromotion)
+		}
+		if p.Price <= 0 {
+			return fmt.Errorf("%w: price must be positive", ErrInvalidPromotion)
+		}
+		if p.Discount != 0 {
+			return fmt.Errorf("%w: a price change does not set a discount", ErrInvalidPromotion)
+		}
+	default:
+		return fmt.Errorf("%w: type must be %s or %s", ErrInvalidPromotion, PromotionDiscount, PromotionPriceChange)
+	}
+
+	if p.StartsAt.IsZero() {
+		return fmt.Errorf("%w: starts_at is required", ErrInvalidPromotion)
+	}
+	if p.EndsAt != nil && !p.EndsAt.After(p.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromotion)
+	}
+	return nil
+}
+
+// ActiveAt reports whether the promotion runs at t. StartsAt is inclusive, EndsAt exclusive.
+func (p Promotion) ActiveAt(t time.Time) bool {
+	return !t.Before(p.StartsAt) && (p.EndsAt == nil || t.Before(*p.EndsAt))
+}
+
+// SelectPromotions picks the promotions in effect at now among those of a product and its category.
+// The price change with the highest priority, if any, sets the price. Discounts are taken by priority:
+// the first always applies, the others only when they and every discount applied so far are stackable.
+// Ties go to the promotion that started last, then to the newest.
+func SelectPromotions(promotions []Promotion, now time.Time) (priceChange *Promotion, discounts []Promotion) {
+	var active []Promotion
+	for _, promotion := range promotions {
+		if promotion.ActiveAt(now) {
+			active = append(active, promotion)
+		}
+	}
+
+	sort.SliceStable(active, func(i, j int) bool {
+		a, b := active[i], active[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if !a.StartsAt.Equal(b.StartsAt) {
+			return a.StartsAt.After(b.StartsAt)
+		}
+		return a.ID > b.ID
+	})
+
+	for i, promotion := range active {
+		switch promotion.Type {
+		case PromotionPriceChange:
+			if priceChange == nil {
+				priceChange = &active[i]
+			}
+		case PromotionDiscount:
+			if len(discounts) == 0 || (promotion.Stackable && discounts[0].Stackable) {
+				discounts = append(discounts, promotion)
+			}
+		}
+	}
+
+	return priceChange, discounts
+}
+
+// PromotionFilter selects a page of promotions. ActiveAt, when set, keeps the promotions running then.
+type PromotionFilter struct {
+	ProductID  int
+	CategoryID int
+	ActiveAt   *time.Time
+	Limit      int
+	Offset     int
+}
+
+// PromotionPage is a page of promotions with the total number matching the filter.
+type PromotionPage struct {
+	Promotions []Promotion `json:"promotions"`
+	Total      int         `json:"total"`
+	Limit      int         `json:"limit"`
+	Offset     int         `json:"offset"`
+}
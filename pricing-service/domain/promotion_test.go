@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectPromotions(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return now.Add(d) }
+	until := func(d time.Duration) *time.Time { end := now.Add(d); return &end }
+
+	discount := func(id, priority int, stackable bool) Promotion {
+		return Promotion{ID: id, Type: PromotionDiscount, Discount: 0.1, Priority: priority, Stackable: stackable, StartsAt: at(-time.Hour)}
+	}
+	priceChange := func(id, priority int, price float64) Promotion {
+		return Promotion{ID: id, Type: PromotionPriceChange, Price: price, Priority: priority, StartsAt: at(-time.Hour)}
+	}
+	startingAt := func(p Promotion, startsAt time.Time) Promotion { p.StartsAt = startsAt; return p }
+	endingAt := func(p Promotion, endsAt *time.Time) Promotion { p.EndsAt = endsAt; return p }
+
+	tests := []struct {
+		name        string
+		promotions  []Promotion
+		priceChange int   // ID of the selected price change, 0 for none
+		discounts   []int // IDs of the selected discounts in order
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:       "highest priority discount first",
+			promotions: []Promotion{discount(1, 1, false), discount(2, 5, false), discount(3, 3, false)},
+			discounts:  []int{2},
+		},
+		{
+			name:       "stackable discounts combine by priority",
+			promotions: []Promotion{discount(1, 1, true), discount(2, 5, true), discount(3, 3, true)},
+			discounts:  []int{2, 3, 1},
+		},
+		{
+			name:       "non-stackable first discount stands alone",
+			promotions: []Promotion{discount(1, 5, false), discount(2, 3, true), discount(3, 1, true)},
+			discounts:  []int{1},
+		},
+		{
+			name:       "non-stackable later discount is skipped",
+			promotions: []Promotion{discount(1, 5, true), discount(2, 3, false), discount(3, 1, true)},
+			discounts:  []int{1, 3},
+		},
+		{
+			name:        "highest priority price change wins",
+			promotions:  []Promotion{priceChange(1, 1, 8), priceChange(2, 4, 9), discount(3, 2, false)},
+			priceChange: 2,
+			discounts:   []int{3},
+		},
+		{
+			name:       "tie goes to the later start",
+			promotions: []Promotion{discount(1, 2, false), startingAt(discount(2, 2, false), at(-time.Minute))},
+			discounts:  []int{2},
+		},
+		{
+			name:       "tie on start goes to the newest",
+			promotions: []Promotion{discount(2, 2, false), discount(7, 2, false), discount(4, 2, false)},
+			discounts:  []int{7},
+		},
+		{
+			name:       "starts now",
+			promotions: []Promotion{startingAt(discount(1, 1, false), now)},
+			discounts:  []int{1},
+		},
+		{
+			name:       "starts just after now",
+			promotions: []Promotion{startingAt(discount(1, 1, false), at(time.Nanosecond))},
+		},
+		{
+			name:        "ends now",
+			promotions:  []Promotion{endingAt(priceChange(1, 1, 8), until(0))},
+			priceChange: 0,
+		},
+		{
+			name:        "ends just after now",
+			promotions:  []Promotion{endingAt(priceChange(1, 1, 8), until(time.Nanosecond))},
+			priceChange: 1,
+		},
+		{
+			name:       "ended promotion does not block a lower priority one",
+			promotions: []Promotion{endingAt(discount(1, 9, false), until(-time.Minute)), discount(2, 1, true)},
+			discounts:  []int{2},
+		},
+		{
+			name:       "scheduled promotion does not block a lower priority one",
+			promotions: []Promotion{startingAt(discount(1, 9, false), at(time.Hour)), discount(2, 1, true), discount(3, 0, true)},
+			discounts:  []int{2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priceChange, discounts := SelectPromotions(tt.promotions, now)
+
+			gotPriceChange := 0
+			if priceChange != nil {
+				gotPriceChange = priceChange.ID
+			}
+			if gotPriceChange != tt.priceChange {
+				t.Errorf("price change %d, want %d", gotPriceChange, tt.priceChange)
+			}
+
+			var gotDiscounts []int
+			for _, discount := range discounts {
+				gotDiscounts = append(gotDiscounts, discount.ID)
+			}
+			if !reflect.DeepEqual(gotDiscounts, tt.discounts) {
+				t.Errorf("discounts %v, want %v", gotDiscounts, tt.discounts)
+			}
+		})
+	}
+}
+
+func TestPromotionActiveAt(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		endsAt *time.Time
+		want   bool
+	}{
+		{"before start", start.Add(-time.Nanosecond), &end, false},
+		{"at start", start, &end, true},
+		{"before end", end.Add(-time.Nanosecond), &end, true},
+		{"at end", end, &end, false},
+		{"long after start without end", start.AddDate(5, 0, 0), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Promotion{StartsAt: start, EndsAt: tt.endsAt}
+			if got := p.ActiveAt(tt.t); got != tt.want {
+				t.Errorf("ActiveAt(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
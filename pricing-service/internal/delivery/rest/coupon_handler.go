@@ -0,0 +1,204 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"pricing-service/domain"
+	"pricing-service/internal/usecase"
+	"pricing-service/pkg/utils"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type CouponHandler struct {
+	couponUsecase usecase.CouponUsecase
+}
+
+func NewCouponHandler(couponUsecase usecase.CouponUsecase) *CouponHandler {
+	return &CouponHandler{couponUsecase: couponUsecase}
+}
+
+// ListCoupons lists coupons, newest first --> /pricing/coupons?limit=20&offset=0
+func (h *CouponHandler) ListCoupons(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, offset, err := parsePagination(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	page, err := h.couponUsecase.ListCoupons(r.Context(), limit, offset)
+	if err != nil {
+		respondWithCouponError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// GetCoupon gets a coupon with its number of uses --> /pricing/coupons/:id
+func (h *CouponHandler) GetCoupon(w http.ResponseWriter, r *http.Request) {
+	id, ok := couponID(w, r)
+	if !ok {
+		return
+	}
+
+	coupon, err := h.couponUsecase.GetCoupon(r.Context(), id)
+	if err != nil {
+		respondWithCouponError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, coupon)
+}
+
+// CreateCoupon adds a coupon --> /pricing/coupons
+func (h *CouponHandler) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	coupon := domain.Coupon{}
+	if err := json.NewDecoder(r.Body).Decode(&coupon); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	coupon.ID = 0
+
+	createdCoupon, err := h.couponUsecase.CreateCoupon(r.Context(), coupon)
+	if err != nil {
+		respondWithCouponError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, createdCoupon)
+}
+
+// UpdateCoupon replaces the settings of a coupon --> /pricing/coupons/:id
+func (h *CouponHandler) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	id, ok := couponID(w, r)
+	if !ok {
+		return
+	}
+
+	coupon := domain.Coupon{}
+	if err := json.NewDecoder(r.Body).Decode(&coupon); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	coupon.ID = id
+
+	updatedCoupon, err := h.couponUsecase.UpdateCoupon(r.Context(), coupon)
+	if err != nil {
+		respondWithCouponError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, updatedCoupon)
+}
+
+// DeleteCoupon deletes a coupon that was never redeemed --> /pricing/coupons/:id
+func (h *CouponHandler) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	id, ok := couponID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.couponUsecase.DeleteCoupon(r.Context(), id)
+	if err != nil {
+		respondWithCouponError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Coupon deleted"})
+}
+
+// ValidateCoupon checks a code against the cart of the current user and returns the discount it gives --> /pricing/coupons/validate
+func (h *CouponHandler) ValidateCoupon(w http.ResponseWriter, r *http.Request) {
+	user, err := utils.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		return
+	}
+
+	cart := domain.CouponCart{}
+	if err := json.NewDecoder(r.Body).Decode(&cart); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	cart.UserID = user.ID
+
+	application, err := h.couponUsecase.ValidateCoupon(r.Context(), cart)
+	if err != nil {
+		respondWithCouponError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, application)
+}
+
+// RedeemCoupon records the use of a code by an order, for services only --> /pricing/coupons/redeem
+func (h *CouponHandler) RedeemCoupon(w http.ResponseWriter, r *http.Request) {
+	var redeemRequest struct {
+		Code      string            `json:"code"`
+		UserID    int               `json:"user_id"`
+		Reference string            `json:"reference"`
+		Items     []domain.CartItem `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&redeemRequest); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	cart := domain.CouponCart{Code: redeemRequest.Code, UserID: redeemRequest.UserID, Items: redeemRequest.Items}
+	redemption, err := h.couponUsecase.RedeemCoupon(r.Context(), cart, redeemRequest.Reference)
+	if err != nil {
+		respondWithCouponError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, redemption)
+}
+
+// ReleaseCoupon takes back the use of a code by an order that failed, for services only --> /pricing/coupons/release
+func (h *CouponHandler) ReleaseCoupon(w http.ResponseWriter, r *http.Request) {
+	var releaseRequest struct {
+		Reference string `json:"reference"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&releaseRequest); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	err := h.couponUsecase.ReleaseCoupon(r.Context(), releaseRequest.Reference)
+	if err != nil {
+		respondWithCouponError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Coupon released"})
+}
+
+func couponID(w http.ResponseWriter, r *http.Request) (id int, ok bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondWithCouponError maps coupon errors to their HTTP status. Errors pricing the cart are mapped
+// like pricing rule errors.
+func respondWithCouponError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrCouponNotFound):
+		utils.RespondWithJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidCoupon), errors.Is(err, domain.ErrInvalidCart):
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrCouponExists), errors.Is(err, domain.ErrCouponInUse):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrCouponNotApplicable):
+		utils.RespondWithJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	default:
+		respondWithPricingRuleError(w, err)
+	}
+}
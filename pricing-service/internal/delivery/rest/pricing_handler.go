@@ -3,6 +3,7 @@ package rest
 import (
 	"encoding/json"
 	"net/http"
+	"pricing-service/domain"
 	"pricing-service/internal/usecase"
 	"pricing-service/pkg/utils"
 )
@@ -18,6 +19,7 @@ func NewPricingHandler(pricingUsecase usecase.PricingUsecase) *PricingHandler {
 func (h *PricingHandler) GetPricing(w http.ResponseWriter, r *http.Request) {
 	var pricingRequest struct {
 		ProductID int `json:"product_id"`
+		Quantity  int `json:"quantity"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&pricingRequest); err != nil {
@@ -25,11 +27,36 @@ func (h *PricingHandler) GetPricing(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pricing, err := h.pricingUsecase.CalculatePricing(r.Context(), pricingRequest.ProductID)
+	// Without a quantity a single unit is priced, as before quantity pricing
+	if pricingRequest.Quantity == 0 {
+		pricingRequest.Quantity = 1
+	}
+
+	pricing, err := h.pricingUsecase.CalculatePricing(r.Context(), pricingRequest.ProductID, pricingRequest.Quantity)
 	if err != nil {
-		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondWithPricingRuleError(w, err)
 		return
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, pricing)
 }
+
+// GetCartPricing prices many lines at once, returning each line and the cart total --> /pricing/batch
+func (h *PricingHandler) GetCartPricing(w http.ResponseWriter, r *http.Request) {
+	var batchRequest struct {
+		Items []domain.PricingItem `json:"items"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&batchRequest); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	cart, err := h.pricingUsecase.CalculateCartPricing(r.Context(), batchRequest.Items)
+	if err != nil {
+		respondWithPricingRuleError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, cart)
+}
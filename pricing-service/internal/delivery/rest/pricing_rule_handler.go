@@ -0,0 +1,163 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"pricing-service/domain"
+	"pricing-service/internal/usecase"
+	"pricing-service/pkg/utils"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+type PricingRuleHandler struct {
+	pricingRuleUsecase usecase.PricingRuleUsecase
+}
+
+func NewPricingRuleHandler(pricingRuleUsecase usecase.PricingRuleUsecase) *PricingRuleHandler {
+	return &PricingRuleHandler{pricingRuleUsecase: pricingRuleUsecase}
+}
+
+// ListPricingRules lists pricing rules by product --> /pricing/rules?limit=20&offset=0
+func (h *PricingRuleHandler) ListPricingRules(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, offset, err := parsePagination(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	page, err := h.pricingRuleUsecase.ListPricingRules(r.Context(), limit, offset)
+	if err != nil {
+		respondWithPricingRuleError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// GetPricingRule gets the pricing rule of a product --> /pricing/rules/:productID
+func (h *PricingRuleHandler) GetPricingRule(w http.ResponseWriter, r *http.Request) {
+	productID, ok := pricingRuleProductID(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := h.pricingRuleUsecase.GetPricingRule(r.Context(), productID)
+	if err != nil {
+		respondWithPricingRuleError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, rule)
+}
+
+// CreatePricingRule adds the pricing rule of a product --> /pricing/rules
+func (h *PricingRuleHandler) CreatePricingRule(w http.ResponseWriter, r *http.Request) {
+	rule := domain.PricingRule{}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	rule.ID = 0
+
+	createdRule, err := h.pricingRuleUsecase.CreatePricingRule(r.Context(), rule)
+	if err != nil {
+		respondWithPricingRuleError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, createdRule)
+}
+
+// UpdatePricingRule replaces the pricing rule of a product --> /pricing/rules/:productID
+func (h *PricingRuleHandler) UpdatePricingRule(w http.ResponseWriter, r *http.Request) {
+	productID, ok := pricingRuleProductID(w, r)
+	if !ok {
+		return
+	}
+
+	rule := domain.PricingRule{}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	rule.ProductID = productID
+
+	updatedRule, err := h.pricingRuleUsecase.UpdatePricingRule(r.Context(), rule)
+	if err != nil {
+		respondWithPricingRuleError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, updatedRule)
+}
+
+// DeletePricingRule deletes the pricing rule of a product --> /pricing/rules/:productID
+func (h *PricingRuleHandler) DeletePricingRule(w http.ResponseWriter, r *http.Request) {
+	productID, ok := pricingRuleProductID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.pricingRuleUsecase.DeletePricingRule(r.Context(), productID)
+	if err != nil {
+		respondWithPricingRuleError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Pricing rule deleted"})
+}
+
+func pricingRuleProductID(w http.ResponseWriter, r *http.Request) (productID int, ok bool) {
+	productID, err := strconv.Atoi(mux.Vars(r)["productID"])
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid product ID"})
+		return 0, false
+	}
+	return productID, true
+}
+
+func parsePagination(limitStr, offsetStr string) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return 0, 0, errors.New("Invalid limit")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+	}
+
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("Invalid offset")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// respondWithPricingRuleError maps pricing rule errors to their HTTP status
+func respondWithPricingRuleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrPricingRuleNotFound):
+		utils.RespondWithJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidPricingRule), errors.Is(err, domain.ErrInvalidPricingBatch),
+		errors.Is(err, domain.ErrInvalidQuantity):
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrPricingRuleExists):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
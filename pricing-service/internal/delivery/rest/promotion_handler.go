@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"pricing-service/domain"
+	"pricing-service/internal/usecase"
+	"pricing-service/pkg/utils"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type PromotionHandler struct {
+	promotionUsecase usecase.PromotionUsecase
+}
+
+func NewPromotionHandler(promotionUsecase usecase.PromotionUsecase) *PromotionHandler {
+	return &PromotionHandler{promotionUsecase: promotionUsecase}
+}
+
+// ListPromotions lists promotions, newest first --> /pricing/promotions?product_id=1&category_id=2&active_at=2025-01-01T00:00:00Z&limit=20&offset=0
+func (h *PromotionHandler) ListPromotions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, offset, err := parsePagination(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	filter := domain.PromotionFilter{Limit: limit, Offset: offset}
+	if filter.ProductID, err = optionalID(query.Get("product_id")); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid product_id"})
+		return
+	}
+	if filter.CategoryID, err = optionalID(query.Get("category_id")); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid category_id"})
+		return
+	}
+	if activeAt := query.Get("active_at"); activeAt != "" {
+		t, err := time.Parse(time.RFC3339, activeAt)
+		if err != nil {
+			utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid active_at"})
+			return
+		}
+		filter.ActiveAt = &t
+	}
+
+	page, err := h.promotionUsecase.ListPromotions(r.Context(), filter)
+	if err != nil {
+		respondWithPromotionError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// GetPromotion gets a promotion --> /pricing/promotions/:id
+func (h *PromotionHandler) GetPromotion(w http.ResponseWriter, r *http.Request) {
+	id, ok := promotionID(w, r)
+	if !ok {
+		return
+	}
+
+	promotion, err := h.promotionUsecase.GetPromotion(r.Context(), id)
+	if err != nil {
+		respondWithPromotionError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, promotion)
+}
+
+// CreatePromotion schedules a promotion --> /pricing/promotions
+func (h *PromotionHandler) CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	promotion := domain.Promotion{}
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	promotion.ID = 0
+
+	createdPromotion, err := h.promotionUsecase.CreatePromotion(r.Context(), promotion)
+	if err != nil {
+		respondWithPromotionError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, createdPromotion)
+}
+
+// UpdatePromotion replaces a promotion --> /pricing/promotions/:id
+func (h *PromotionHandler) UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	id, ok := promotionID(w, r)
+	if !ok {
+		return
+	}
+
+	promotion := domain.Promotion{}
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	promotion.ID = id
+
+	updatedPromotion, err := h.promotionUsecase.UpdatePromotion(r.Context(), promotion)
+	if err != nil {
+		respondWithPromotionError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, updatedPromotion)
+}
+
+// DeletePromotion deletes a promotion --> /pricing/promotions/:id
+func (h *PromotionHandler) DeletePromotion(w http.ResponseWriter, r *http.Request) {
+	id, ok := promotionID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.promotionUsecase.DeletePromotion(r.Context(), id)
+	if err != nil {
+		respondWithPromotionError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Promotion deleted"})
+}
+
+func promotionID(w http.ResponseWriter, r *http.Request) (id int, ok bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// optionalID parses an optional positive ID query parameter, 0 when it is empty
+func optionalID(value string) (id int, err error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err = strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid ID")
+	}
+	return id, nil
+}
+
+// respondWithPromotionError maps promotion errors to their HTTP status
+func respondWithPromotionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrPromotionNotFound):
+		utils.RespondWithJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidPromotion):
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
@@ -8,7 +8,7 @@ import (
 )
 
 // RegisterRoutes registers all API routes
-func RegisterRoutes(router *mux.Router, pricingHandler *PricingHandler) {
+func RegisterRoutes(router *mux.Router, pricingHandler *PricingHandler, pricingRuleHandler *PricingRuleHandler, promotionHandler *PromotionHandler, couponHandler *CouponHandler) {
 	// Logger Middleware
 	router.Use(middleware.LoggingMiddleware)
 
@@ -23,6 +23,15 @@ func RegisterRoutes(router *mux.Router, pricingHandler *PricingHandler) {
 
 	// Register Pricing routes
 	registerPricingRoutes(apiRouter, pricingHandler, jwtMiddleware)
+
+	// Register pricing rule routes
+	registerPricingRuleRoutes(apiRouter, pricingRuleHandler, jwtMiddleware)
+
+	// Register promotion routes
+	registerPromotionRoutes(apiRouter, promotionHandler, jwtMiddleware)
+
+	// Register coupon routes
+	registerCouponRoutes(apiRouter, couponHandler, jwtMiddleware)
 }
 
 // registerUserRoutes registers user related routes
@@ -34,7 +43,50 @@ func registerPricingRoutes(router *mux.Router, handler *PricingHandler, jwtMiddl
 	protected := PricingRouter.PathPrefix("").Subrouter()
 	protected.Use(jwtMiddleware.RequireAuth)
 	protected.HandleFunc("", handler.GetPricing).Methods("POST")
+	protected.HandleFunc("/batch", handler.GetCartPricing).Methods("POST")
+
+}
+
+// registerPricingRuleRoutes registers pricing rule administration routes, admin only
+func registerPricingRuleRoutes(router *mux.Router, handler *PricingRuleHandler, jwtMiddleware *middleware.JWTMiddleware) {
+	admin := router.PathPrefix("/pricing/rules").Subrouter()
+	admin.Use(jwtMiddleware.RequireAdmin)
+	admin.HandleFunc("", handler.ListPricingRules).Methods("GET")
+	admin.HandleFunc("", handler.CreatePricingRule).Methods("POST")
+	admin.HandleFunc("/{productID:[0-9]+}", handler.GetPricingRule).Methods("GET")
+	admin.HandleFunc("/{productID:[0-9]+}", handler.UpdatePricingRule).Methods("PUT")
+	admin.HandleFunc("/{productID:[0-9]+}", handler.DeletePricingRule).Methods("DELETE")
+}
+
+// registerPromotionRoutes registers promotion administration routes, admin only
+func registerPromotionRoutes(router *mux.Router, handler *PromotionHandler, jwtMiddleware *middleware.JWTMiddleware) {
+	admin := router.PathPrefix("/pricing/promotions").Subrouter()
+	admin.Use(jwtMiddleware.RequireAdmin)
+	admin.HandleFunc("", handler.ListPromotions).Methods("GET")
+	admin.HandleFunc("", handler.CreatePromotion).Methods("POST")
+	admin.HandleFunc("/{id:[0-9]+}", handler.GetPromotion).Methods("GET")
+	admin.HandleFunc("/{id:[0-9]+}", handler.UpdatePromotion).Methods("PUT")
+	admin.HandleFunc("/{id:[0-9]+}", handler.DeletePromotion).Methods("DELETE")
+}
+
+// registerCouponRoutes registers coupon routes: validation for users, redemption for services and administration for admins
+func registerCouponRoutes(router *mux.Router, handler *CouponHandler, jwtMiddleware *middleware.JWTMiddleware) {
+	protected := router.PathPrefix("/pricing/coupons").Subrouter()
+	protected.Use(jwtMiddleware.RequireAuth)
+	protected.HandleFunc("/validate", handler.ValidateCoupon).Methods("POST")
+
+	service := router.PathPrefix("/pricing/coupons").Subrouter()
+	service.Use(jwtMiddleware.RequireService)
+	service.HandleFunc("/redeem", handler.RedeemCoupon).Methods("POST")
+	service.HandleFunc("/release", handler.ReleaseCoupon).Methods("POST")
 
+	admin := router.PathPrefix("/pricing/coupons").Subrouter()
+	admin.Use(jwtMiddleware.RequireAdmin)
+	admin.HandleFunc("", handler.ListCoupons).Methods("GET")
+	admin.HandleFunc("", handler.CreateCoupon).Methods("POST")
+	admin.HandleFunc("/{id:[0-9]+}", handler.GetCoupon).Methods("GET")
+	admin.HandleFunc("/{id:[0-9]+}", handler.UpdateCoupon).Methods("PUT")
+	admin.HandleFunc("/{id:[0-9]+}", handler.DeleteCoupon).Methods("DELETE")
 }
 
 // HealthCheck handler for the health endpoint
@@ -0,0 +1,265 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"pricing-service/domain"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type CouponRepository interface {
+	CreateCoupon(ctx context.Context, coupon domain.Coupon) (created domain.Coupon, err error)
+	UpdateCoupon(ctx context.Context, coupon domain.Coupon) (err error)
+	DeleteCoupon(ctx context.Context, id int) (err error)
+	GetCoupon(ctx context.Context, id int) (coupon domain.Coupon, err error)
+	GetCouponByCode(ctx context.Context, code string) (coupon domain.Coupon, err error)
+	ListCoupons(ctx context.Context, limit, offset int) (coupons []domain.Coupon, total int, err error)
+	CountUserRedemptions(ctx context.Context, couponID, userID int) (count int, err error)
+	RedeemCoupon(ctx context.Context, cart domain.CouponCart, reference string, categories map[int]int, now time.Time) (redemption domain.CouponRedemption, err error)
+	ReleaseRedemption(ctx context.Context, reference string) (released bool, err error)
+}
+
+type couponRepository struct {
+	db *sql.DB
+}
+
+func NewCouponRepository(db *sql.DB) CouponRepository {
+	return &couponRepository{db}
+}
+
+const couponColumns = `id, code, type, value, min_order_value, max_uses, max_uses_per_user, used_count, product_id, category_id, starts_at, expires_at`
+
+// CreateCoupon adds a coupon. A code that is already taken is reported as ErrCouponExists.
+func (r *couponRepository) CreateCoupon(ctx context.Context, coupon domain.Coupon) (created domain.Coupon, err error) {
+	query := `INSERT INTO coupons (code, type, value, min_order_value, max_uses, max_uses_per_user, product_id, category_id, starts_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := r.db.ExecContext(ctx, query, coupon.Code, coupon.Type, coupon.Value, coupon.MinOrderValue, coupon.MaxUses, coupon.MaxUsesPerUser,
+		nullInt(coupon.ProductID), nullInt(coupon.CategoryID), coupon.StartsAt.UTC(), nullTime(coupon.ExpiresAt))
+	if err != nil {
+		return created, couponWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return created, err
+	}
+
+	created = coupon
+	created.ID = int(id)
+	created.UsedCount = 0
+	return created, nil
+}
+
+// UpdateCoupon replaces the settings of a coupon. Its used count is kept.
+func (r *couponRepository) UpdateCoupon(ctx context.Context, coupon domain.Coupon) (err error) {
+	query := `UPDATE coupons SET code = ?, type = ?, value = ?, min_order_value = ?, max_uses = ?, max_uses_per_user = ?, product_id = ?, category_id = ?,
+		starts_at = ?, expires_at = ? WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, coupon.Code, coupon.Type, coupon.Value, coupon.MinOrderValue, coupon.MaxUses, coupon.MaxUsesPerUser,
+		nullInt(coupon.ProductID), nullInt(coupon.CategoryID), coupon.StartsAt.UTC(), nullTime(coupon.ExpiresAt), coupon.ID)
+	return couponWriteError(err)
+}
+
+// DeleteCoupon deletes a coupon, returning sql.ErrNoRows when there is none. A coupon that was
+// redeemed is kept for its redemptions and reported as ErrCouponInUse; it can be expired instead.
+func (r *couponRepository) DeleteCoupon(ctx context.Context, id int) (err error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM coupons WHERE id = ?`, id)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrRowIsReferenced {
+			return domain.ErrCouponInUse
+		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *couponRepository) GetCoupon(ctx context.Context, id int) (coupon domain.Coupon, err error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons WHERE id = ?`
+	return scanCoupon(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *couponRepository) GetCouponByCode(ctx context.Context, code string) (coupon domain.Coupon, err error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons WHERE code = ?`
+	return scanCoupon(r.db.QueryRowContext(ctx, query, code))
+}
+
+// ListCoupons returns a page of coupons, newest first, with the number of coupons.
+func (r *couponRepository) ListCoupons(ctx context.Context, limit, offset int) (coupons []domain.Coupon, total int, err error) {
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM coupons`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + couponColumns + ` FROM coupons ORDER BY id DESC LIMIT ? OFFSET ?`
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		coupons = append(coupons, coupon)
+	}
+
+	return coupons, total, rows.Err()
+}
+
+func (r *couponRepository) CountUserRedemptions(ctx context.Context, couponID, userID int) (count int, err error) {
+	query := `SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = ? AND user_id = ?`
+	err = r.db.QueryRowContext(ctx, query, couponID, userID).Scan(&count)
+	return count, err
+}
+
+// RedeemCoupon applies the coupon of the cart and records its use under reference in one transaction.
+// The coupon row is locked so concurrent redemptions cannot exceed its usage limits. When reference
+// was redeemed before, that redemption is returned unchanged.
+func (r *couponRepository) RedeemCoupon(ctx context.Context, cart domain.CouponCart, reference string, categories map[int]int, now time.Time) (redemption domain.CouponRedemption, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return redemption, err
+	}
+
+	redemption, err = redeemCoupon(ctx, tx, cart, reference, categories, now)
+	if err != nil {
+		tx.Rollback()
+		return redemption, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return redemption, err
+	}
+
+	return redemption, nil
+}
+
+func redeemCoupon(ctx context.Context, tx *sql.Tx, cart domain.CouponCart, reference string, categories map[int]int, now time.Time) (redemption domain.CouponRedemption, err error) {
+	coupon, err := scanCoupon(tx.QueryRowContext(ctx, `SELECT `+couponColumns+` FROM coupons WHERE code = ? FOR UPDATE`, cart.Code))
+	if err != nil {
+		return redemption, err
+	}
+
+	query := `SELECT id, coupon_id, user_id, reference, discount, created_at FROM coupon_redemptions WHERE reference = ?`
+	err = tx.QueryRowContext(ctx, query, reference).Scan(&redemption.ID, &redemption.CouponID, &redemption.UserID, &redemption.Reference,
+		&redemption.Discount, &redemption.CreatedAt)
+	if err == nil {
+		redemption.Code = coupon.Code
+		return redemption, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return redemption, err
+	}
+
+	var userUses int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = ? AND user_id = ?`, coupon.ID, cart.UserID).Scan(&userUses)
+	if err != nil {
+		return redemption, err
+	}
+
+	application, err := coupon.Apply(cart.Items, categories, userUses, now)
+	if err != nil {
+		return redemption, err
+	}
+
+	redemption = domain.CouponRedemption{
+		CouponID:  coupon.ID,
+		Code:      coupon.Code,
+		UserID:    cart.UserID,
+		Reference: reference,
+		Discount:  application.Discount,
+		CreatedAt: now.UTC(),
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO coupon_redemptions (coupon_id, user_id, reference, discount, created_at) VALUES (?, ?, ?, ?, ?)`,
+		redemption.CouponID, redemption.UserID, redemption.Reference, redemption.Discount, redemption.CreatedAt)
+	if err != nil {
+		return redemption, err
+	}
+
+	redemption.ID, err = res.LastInsertId()
+	if err != nil {
+		return redemption, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE coupons SET used_count = used_count + 1 WHERE id = ?`, coupon.ID)
+	return redemption, err
+}
+
+// ReleaseRedemption takes back the redemption recorded under reference, freeing its use of the coupon.
+// Releasing a reference that was never redeemed, or already released, does nothing.
+func (r *couponRepository) ReleaseRedemption(ctx context.Context, reference string) (released bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var id int64
+	var couponID int
+	err = tx.QueryRowContext(ctx, `SELECT id, coupon_id FROM coupon_redemptions WHERE reference = ? FOR UPDATE`, reference).Scan(&id, &couponID)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return false, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM coupon_redemptions WHERE id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE coupons SET used_count = GREATEST(used_count - 1, 0) WHERE id = ?`, couponID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func couponWriteError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+		return domain.ErrCouponExists
+	}
+	return err
+}
+
+func scanCoupon(row interface{ Scan(dest ...any) error }) (coupon domain.Coupon, err error) {
+	var productID, categoryID sql.NullInt64
+	var expiresAt sql.NullTime
+	err = row.Scan(&coupon.ID, &coupon.Code, &coupon.Type, &coupon.Value, &coupon.MinOrderValue, &coupon.MaxUses, &coupon.MaxUsesPerUser,
+		&coupon.UsedCount, &productID, &categoryID, &coupon.StartsAt, &expiresAt)
+	if err != nil {
+		return coupon, err
+	}
+
+	coupon.ProductID = int(productID.Int64)
+	coupon.CategoryID = int(categoryID.Int64)
+	if expiresAt.Valid {
+		coupon.ExpiresAt = &expiresAt.Time
+	}
+	return coupon, nil
+}
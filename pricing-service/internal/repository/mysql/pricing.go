@@ -3,16 +3,28 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"pricing-service/domain"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
 )
 
+// mysqlErrDuplicateEntry is the MySQL error number for a duplicate unique key
+const mysqlErrDuplicateEntry = 1062
+
+// mysqlErrRowIsReferenced is the MySQL error number for deleting a row a foreign key still points to
+const mysqlErrRowIsReferenced = 1451
+
 type PricingRepository interface {
-	CreatePricingRule(ctx context.Context, rule domain.PricingRule) (err error)
+	CreatePricingRule(ctx context.Context, rule domain.PricingRule) (created domain.PricingRule, err error)
 	UpdatePricingRule(ctx context.Context, rule domain.PricingRule) (err error)
 	DeletePricingRule(ctx context.Context, productID int) (err error)
 	GetPricingRule(ctx context.Context, productID int) (rule domain.PricingRule, err error)
+	GetPricingRules(ctx context.Context, productIDs []int) (rules []domain.PricingRule, err error)
+	ListPricingRules(ctx context.Context, limit, offset int) (rules []domain.PricingRule, total int, err error)
 }
 
 type pricingRepository struct {
@@ -23,40 +35,179 @@ func NewPricingRepository(db *sql.DB) PricingRepository {
 	return &pricingRepository{db}
 }
 
-// CreatePricingRule creates a new pricing rule in the database
-func (r *pricingRepository) CreatePricingRule(ctx context.Context, rule domain.PricingRule) (err error) {
-	query := `INSERT INTO pricing_rules (product_id, product_price, default_markup, default_discount, stock_threshold, markup_increase, discount_reduction)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err = r.db.ExecContext(ctx, query, rule.ProductID, rule.ProductPrice, rule.DefaultMarkup, rule.DefaultDiscount, rule.StockThreshold, rule.MarkupIncrease, rule.DiscountReduction)
-	return err
+const pricingRuleColumns = `id, product_id, product_price, default_markup, default_discount, stock_threshold, markup_increase, discount_reduction,
+	tiers, bundle_buy_quantity, bundle_free_quantity`
+
+// CreatePricingRule creates a new pricing rule in the database. A product that already has a rule is
+// reported as ErrPricingRuleExists.
+func (r *pricingRepository) CreatePricingRule(ctx context.Context, rule domain.PricingRule) (created domain.PricingRule, err error) {
+	tiers, err := marshalTiers(rule.Tiers)
+	if err != nil {
+		return created, err
+	}
+	bundle := bundleColumns(rule.Bundle)
+
+	query := `INSERT INTO pricing_rules (product_id, product_price, default_markup, default_discount, stock_threshold, markup_increase, discount_reduction,
+		tiers, bundle_buy_quantity, bundle_free_quantity)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := r.db.ExecContext(ctx, query, rule.ProductID, rule.ProductPrice, rule.DefaultMarkup, rule.DefaultDiscount, rule.StockThreshold, rule.MarkupIncrease, rule.DiscountReduction,
+		tiers, bundle.BuyQuantity, bundle.FreeQuantity)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+			return created, fmt.Errorf("%w for product %d", domain.ErrPricingRuleExists, rule.ProductID)
+		}
+		return created, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return created, err
+	}
+
+	created = rule
+	created.ID = int(id)
+	return created, nil
 }
 
 // UpdatePricingRule updates an existing pricing rule in the database
 func (r *pricingRepository) UpdatePricingRule(ctx context.Context, rule domain.PricingRule) (err error) {
-	query := `UPDATE pricing_rules SET product_price = ?, default_markup = ?, default_discount = ?, stock_threshold = ?, markup_increase = ?, discount_reduction = ? WHERE product_id = ?`
-	_, err = r.db.ExecContext(ctx, query, rule.ProductPrice, rule.DefaultMarkup, rule.DefaultDiscount, rule.StockThreshold, rule.MarkupIncrease, rule.DiscountReduction, rule.ProductID)
+	tiers, err := marshalTiers(rule.Tiers)
+	if err != nil {
+		return err
+	}
+	bundle := bundleColumns(rule.Bundle)
+
+	query := `UPDATE pricing_rules SET product_price = ?, default_markup = ?, default_discount = ?, stock_threshold = ?, markup_increase = ?, discount_reduction = ?,
+		tiers = ?, bundle_buy_quantity = ?, bundle_free_quantity = ? WHERE product_id = ?`
+	_, err = r.db.ExecContext(ctx, query, rule.ProductPrice, rule.DefaultMarkup, rule.DefaultDiscount, rule.StockThreshold, rule.MarkupIncrease, rule.DiscountReduction,
+		tiers, bundle.BuyQuantity, bundle.FreeQuantity, rule.ProductID)
 	return err
 }
 
-// DeletePricingRule deletes a pricing rule from the database
+// DeletePricingRule deletes a pricing rule from the database, returning sql.ErrNoRows when there is none
 func (r *pricingRepository) DeletePricingRule(ctx context.Context, productID int) (err error) {
 	query := `DELETE FROM pricing_rules WHERE product_id = ?`
-	_, err = r.db.ExecContext(ctx, query, productID)
-	return err
+	res, err := r.db.ExecContext(ctx, query, productID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
 // GetPricingRule fetches the pricing rule for a specific product from the database
 func (r *pricingRepository) GetPricingRule(ctx context.Context, productID int) (rule domain.PricingRule, err error) {
-	query := `SELECT id, product_id, product_price, default_markup, default_discount, stock_threshold, markup_increase, discount_reduction 
-		FROM pricing_rules WHERE product_id = ?`
-	row := r.db.QueryRowContext(ctx, query, productID)
-
-	err = row.Scan(&rule.ID, &rule.ProductID, &rule.ProductPrice, &rule.DefaultMarkup, &rule.DefaultDiscount, &rule.StockThreshold, &rule.MarkupIncrease, &rule.DiscountReduction)
+	query := `SELECT ` + pricingRuleColumns + ` FROM pricing_rules WHERE product_id = ?`
+	rule, err = scanPricingRule(r.db.QueryRowContext(ctx, query, productID))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return rule, fmt.Errorf("pricing rule not found for product %d", productID)
+			return rule, fmt.Errorf("%w for product %d", domain.ErrPricingRuleNotFound, productID)
+		}
+		return rule, err
+	}
+	return rule, nil
+}
+
+// GetPricingRules returns the rules of the products in one query. Products without a rule are left out.
+func (r *pricingRepository) GetPricingRules(ctx context.Context, productIDs []int) (rules []domain.PricingRule, err error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+
+	query := `SELECT ` + pricingRuleColumns + ` FROM pricing_rules WHERE product_id IN (?` + strings.Repeat(", ?", len(productIDs)-1) + `)`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule, err := scanPricingRule(rows)
+		if err != nil {
+			return nil, err
 		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// ListPricingRules returns a page of pricing rules ordered by product, with the number of rules.
+func (r *pricingRepository) ListPricingRules(ctx context.Context, limit, offset int) (rules []domain.PricingRule, total int, err error) {
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pricing_rules`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + pricingRuleColumns + ` FROM pricing_rules ORDER BY product_id LIMIT ? OFFSET ?`
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule, err := scanPricingRule(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, total, rows.Err()
+}
+
+func scanPricingRule(row interface{ Scan(dest ...any) error }) (rule domain.PricingRule, err error) {
+	var tiers sql.NullString
+	var bundle domain.Bundle
+	err = row.Scan(&rule.ID, &rule.ProductID, &rule.ProductPrice, &rule.DefaultMarkup, &rule.DefaultDiscount, &rule.StockThreshold, &rule.MarkupIncrease, &rule.DiscountReduction,
+		&tiers, &bundle.BuyQuantity, &bundle.FreeQuantity)
+	if err != nil {
 		return rule, err
 	}
+
+	if tiers.Valid {
+		err = json.Unmarshal([]byte(tiers.String), &rule.Tiers)
+		if err != nil {
+			return rule, err
+		}
+	}
+	if bundle.BuyQuantity > 0 {
+		rule.Bundle = &bundle
+	}
 	return rule, nil
 }
+
+// marshalTiers encodes the quantity tiers of a rule for a JSON column, NULL when there are none.
+func marshalTiers(tiers []domain.QuantityTier) (value interface{}, err error) {
+	if len(tiers) == 0 {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(tiers)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// bundleColumns returns the bundle of a rule as stored, with zero quantities when it has none.
+func bundleColumns(bundle *domain.Bundle) domain.Bundle {
+	if bundle == nil {
+		return domain.Bundle{}
+	}
+	return *bundle
+}
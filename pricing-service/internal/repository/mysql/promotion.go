@@ -0,0 +1,173 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"pricing-service/domain"
+	"strings"
+	"time"
+)
+
+type PromotionRepository interface {
+	CreatePromotion(ctx context.Context, promotion domain.Promotion) (created domain.Promotion, err error)
+	UpdatePromotion(ctx context.Context, promotion domain.Promotion) (err error)
+	DeletePromotion(ctx context.Context, id int) (err error)
+	GetPromotion(ctx context.Context, id int) (promotion domain.Promotion, err error)
+	ListPromotions(ctx context.Context, filter domain.PromotionFilter) (promotions []domain.Promotion, total int, err error)
+	GetActivePromotions(ctx context.Context, productIDs []int, now time.Time) (promotions []domain.Promotion, err error)
+}
+
+type promotionRepository struct {
+	db *sql.DB
+}
+
+func NewPromotionRepository(db *sql.DB) PromotionRepository {
+	return &promotionRepository{db}
+}
+
+const promotionColumns = `id, name, type, product_id, category_id, discount, price, priority, stackable, starts_at, ends_at`
+
+func (r *promotionRepository) CreatePromotion(ctx context.Context, promotion domain.Promotion) (created domain.Promotion, err error) {
+	query := `INSERT INTO promotions (name, type, product_id, category_id, discount, price, priority, stackable, starts_at, ends_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := r.db.ExecContext(ctx, query, promotion.Name, promotion.Type, nullInt(promotion.ProductID), nullInt(promotion.CategoryID),
+		promotion.Discount, promotion.Price, promotion.Priority, promotion.Stackable, promotion.StartsAt.UTC(), nullTime(promotion.EndsAt))
+	if err != nil {
+		return created, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return created, err
+	}
+
+	created = promotion
+	created.ID = int(id)
+	return created, nil
+}
+
+func (r *promotionRepository) UpdatePromotion(ctx context.Context, promotion domain.Promotion) (err error) {
+	query := `UPDATE promotions SET name = ?, type = ?, product_id = ?, category_id = ?, discount = ?, price = ?, priority = ?, stackable = ?, starts_at = ?, ends_at = ?
+		WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, promotion.Name, promotion.Type, nullInt(promotion.ProductID), nullInt(promotion.CategoryID),
+		promotion.Discount, promotion.Price, promotion.Priority, promotion.Stackable, promotion.StartsAt.UTC(), nullTime(promotion.EndsAt), promotion.ID)
+	return err
+}
+
+// DeletePromotion deletes a promotion, returning sql.ErrNoRows when there is none
+func (r *promotionRepository) DeletePromotion(ctx context.Context, id int) (err error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM promotions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *promotionRepository) GetPromotion(ctx context.Context, id int) (promotion domain.Promotion, err error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions WHERE id = ?`
+	return scanPromotion(r.db.QueryRowContext(ctx, query, id))
+}
+
+// ListPromotions returns a page of the promotions matching the filter, newest first, with their number.
+func (r *promotionRepository) ListPromotions(ctx context.Context, filter domain.PromotionFilter) (promotions []domain.Promotion, total int, err error) {
+	var conditions []string
+	var args []interface{}
+	if filter.ProductID != 0 {
+		conditions = append(conditions, "product_id = ?")
+		args = append(args, filter.ProductID)
+	}
+	if filter.CategoryID != 0 {
+		conditions = append(conditions, "category_id = ?")
+		args = append(args, filter.CategoryID)
+	}
+	if filter.ActiveAt != nil {
+		conditions = append(conditions, "starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)")
+		args = append(args, filter.ActiveAt.UTC(), filter.ActiveAt.UTC())
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM promotions`+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + promotionColumns + ` FROM promotions` + where + ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	promotions, err = r.queryPromotions(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return promotions, total, nil
+}
+
+// GetActivePromotions returns the promotions of the products running at now, together with every
+// category-wide promotion running then; the caller keeps those of each product's category.
+func (r *promotionRepository) GetActivePromotions(ctx context.Context, productIDs []int, now time.Time) (promotions []domain.Promotion, err error) {
+	args := make([]interface{}, 0, len(productIDs)+2)
+	for _, id := range productIDs {
+		args = append(args, id)
+	}
+	args = append(args, now.UTC(), now.UTC())
+
+	query := `SELECT ` + promotionColumns + ` FROM promotions
+		WHERE (product_id IN (?` + strings.Repeat(", ?", len(productIDs)-1) + `) OR category_id IS NOT NULL) AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)
+		ORDER BY id`
+	return r.queryPromotions(ctx, query, args...)
+}
+
+func (r *promotionRepository) queryPromotions(ctx context.Context, query string, args ...interface{}) (promotions []domain.Promotion, err error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		promotion, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, promotion)
+	}
+
+	return promotions, rows.Err()
+}
+
+func scanPromotion(row interface{ Scan(dest ...any) error }) (promotion domain.Promotion, err error) {
+	var productID, categoryID sql.NullInt64
+	var endsAt sql.NullTime
+	err = row.Scan(&promotion.ID, &promotion.Name, &promotion.Type, &productID, &categoryID, &promotion.Discount, &promotion.Price,
+		&promotion.Priority, &promotion.Stackable, &promotion.StartsAt, &endsAt)
+	if err != nil {
+		return promotion, err
+	}
+
+	promotion.ProductID = int(productID.Int64)
+	promotion.CategoryID = int(categoryID.Int64)
+	if endsAt.Valid {
+		promotion.EndsAt = &endsAt.Time
+	}
+	return promotion, nil
+}
+
+func nullInt(value int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(value), Valid: value != 0}
+}
+
+func nullTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: value.UTC(), Valid: true}
+}
@@ -2,57 +2,101 @@ package redis
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
+	"pricing-service/config"
 	"pricing-service/domain"
+	"shared/rediscache"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
 )
 
+// Pricing rules are cached by the product they price
+const pricingRuleKeyPrefix = "pricing_rule"
+
 type PricingCache interface {
-	GetPricingRule(ctx context.Context, productID int) (rule domain.PricingRule, err error)
-	SetProduct(ctx context.Context, rule domain.PricingRule, expiration time.Duration) (err error)
+	FetchPricingRule(ctx context.Context, productID int, load func(ctx context.Context) (domain.PricingRule, error)) (rule domain.PricingRule, err error)
+	FetchPricingRules(ctx context.Context, productIDs []int, load func(ctx context.Context, productIDs []int) ([]domain.PricingRule, error)) (rules map[int]domain.PricingRule, err error)
+	SetPricingRule(ctx context.Context, rule domain.PricingRule, expiration time.Duration) (err error)
+	DeletePricingRule(ctx context.Context, productID int) (err error)
 }
 
 type pricingCache struct {
-	rdb *redis.Client
+	cache *rediscache.Cache
+	ttl   time.Duration
+}
+
+func NewPricingCache(rdb *redis.Client, cfg config.CacheConfig) PricingCache {
+	return &pricingCache{
+		cache: rediscache.NewCache(rdb, cfg.Jitter),
+		ttl:   cfg.PricingRuleTTL,
+	}
 }
 
-func NewPricingCache(rdb *redis.Client) PricingCache {
-	return &pricingCache{rdb}
+// FetchPricingRule reads the rule of the product through the cache, loading it on a miss.
+func (r *pricingCache) FetchPricingRule(ctx context.Context, productID int, load func(ctx context.Context) (domain.PricingRule, error)) (rule domain.PricingRule, err error) {
+	err = r.cache.Fetch(ctx, rediscache.Key(pricingRuleKeyPrefix, productID), r.ttl, &rule, func(ctx context.Context) (interface{}, error) {
+		return load(ctx)
+	})
+	return rule, err
 }
 
-func (r *pricingCache) GetPricingRule(ctx context.Context, productID int) (rule domain.PricingRule, err error) {
-	key := fmt.Sprintf("pricing_rule:%d", productID)
-	pricingCache, err := r.rdb.Get(ctx, key).Result()
+// FetchPricingRules reads the rules of the products with one MGET and loads the missing ones in a single
+// call, caching what it loads. Products without a rule are left out of rules. As with FetchPricingRule, an
+// unreadable cache counts as a miss and a failed store is only logged.
+func (r *pricingCache) FetchPricingRules(ctx context.Context, productIDs []int, load func(ctx context.Context, productIDs []int) ([]domain.PricingRule, error)) (rules map[int]domain.PricingRule, err error) {
+	keys := make([]string, len(productIDs))
+	for i, productID := range productIDs {
+		keys[i] = rediscache.Key(pricingRuleKeyPrefix, productID)
+	}
+
+	cached := make([]domain.PricingRule, len(productIDs))
+	found, err := r.cache.GetMany(ctx, keys, func(i int) interface{} { return &cached[i] })
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return rule, nil
+		log.Warn().Err(err).Msgf("Error reading pricing rules of %d products from cache", len(productIDs))
+	}
+
+	rules = map[int]domain.PricingRule{}
+	var missing []int
+	for i, productID := range productIDs {
+		if found[i] {
+			rules[productID] = cached[i]
 		} else {
-			return rule, err
+			missing = append(missing, productID)
 		}
 	}
+	if len(missing) == 0 {
+		return rules, nil
+	}
 
-	err = json.Unmarshal([]byte(pricingCache), &rule)
+	loaded, err := load(ctx, missing)
 	if err != nil {
-		return rule, err
+		return nil, err
 	}
 
-	return rule, nil
-}
+	keys = make([]string, len(loaded))
+	values := make([]interface{}, len(loaded))
+	for i, rule := range loaded {
+		rules[rule.ProductID] = rule
+		keys[i] = rediscache.Key(pricingRuleKeyPrefix, rule.ProductID)
+		values[i] = rule
+	}
 
-func (r *pricingCache) SetProduct(ctx context.Context, rule domain.PricingRule, expiration time.Duration) (err error) {
-	ruleByte, err := json.Marshal(rule)
+	err = r.cache.SetMany(ctx, keys, values, r.ttl)
 	if err != nil {
-		return err
+		log.Warn().Err(err).Msgf("Error writing pricing rules of %d products to cache", len(loaded))
 	}
+	return rules, nil
+}
 
-	key := fmt.Sprintf("pricing_rule:%d", rule.ID)
-	err = r.rdb.Set(ctx, key, ruleByte, 0).Err()
-	if err != nil {
-		return err
+// SetPricingRule caches the rule under its product for expiration, or for the configured TTL when expiration is zero.
+func (r *pricingCache) SetPricingRule(ctx context.Context, rule domain.PricingRule, expiration time.Duration) (err error) {
+	if expiration == 0 {
+		expiration = r.ttl
 	}
-	return nil
+	return r.cache.Set(ctx, rediscache.Key(pricingRuleKeyPrefix, rule.ProductID), rule, expiration)
+}
+
+func (r *pricingCache) DeletePricingRule(ctx context.Context, productID int) (err error) {
+	return r.cache.Delete(ctx, rediscache.Key(pricingRuleKeyPrefix, productID))
 }
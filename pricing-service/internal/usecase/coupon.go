@@ -0,0 +1,288 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pricing-service/domain"
+	repo "pricing-service/internal/repository/mysql"
+
+	"github.com/rs/zerolog/log"
+)
+
+type CouponUsecase interface {
+	ListCoupons(ctx context.Context, limit, offset int) (page domain.CouponPage, err error)
+	GetCoupon(ctx context.Context, id int) (coupon domain.Coupon, err error)
+	CreateCoupon(ctx context.Context, req domain.Coupon) (coupon domain.Coupon, err error)
+	UpdateCoupon(ctx context.Context, req domain.Coupon) (coupon domain.Coupon, err error)
+	DeleteCoupon(ctx context.Context, id int) (err error)
+	ValidateCoupon(ctx context.Context, cart domain.CouponCart) (application domain.CouponApplication, err error)
+	RedeemCoupon(ctx context.Context, cart domain.CouponCart, reference string) (redemption domain.CouponRedemption, err error)
+	ReleaseCoupon(ctx context.Context, reference string) (err error)
+}
+
+type couponUsecase struct {
+	repo              repo.CouponRepository
+	pricingUsecase    PricingUsecase
+	productServiceURL string
+	now               func() time.Time
+}
+
+// NewCouponUsecase builds the coupon usecase. Carts being validated are priced with pricingUsecase;
+// coupons are checked against the time now returns.
+func NewCouponUsecase(repo repo.CouponRepository, pricingUsecase PricingUsecase, productServiceURL string, now func() time.Time) CouponUsecase {
+	return &couponUsecase{
+		repo:              repo,
+		pricingUsecase:    pricingUsecase,
+		productServiceURL: productServiceURL,
+		now:               now,
+	}
+}
+
+func (u *couponUsecase) ListCoupons(ctx context.Context, limit, offset int) (page domain.CouponPage, err error) {
+	coupons, total, err := u.repo.ListCoupons(ctx, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Error listing coupons")
+		return page, err
+	}
+
+	if coupons == nil {
+		coupons = []domain.Coupon{}
+	}
+	return domain.CouponPage{Coupons: coupons, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+func (u *couponUsecase) GetCoupon(ctx context.Context, id int) (coupon domain.Coupon, err error) {
+	coupon, err = u.repo.GetCoupon(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return coupon, domain.ErrCouponNotFound
+		}
+		log.Error().Err(err).Msgf("Error getting coupon %d", id)
+		return coupon, err
+	}
+	return coupon, nil
+}
+
+// CreateCoupon adds a coupon. Without starts_at it can be used right away.
+func (u *couponUsecase) CreateCoupon(ctx context.Context, req domain.Coupon) (coupon domain.Coupon, err error) {
+	req.Code = domain.NormalizeCouponCode(req.Code)
+	if req.StartsAt.IsZero() {
+		req.StartsAt = u.now().UTC().Truncate(time.Second)
+	}
+	err = req.Validate()
+	if err != nil {
+		return coupon, err
+	}
+
+	coupon, err = u.repo.CreateCoupon(ctx, req)
+	if err != nil {
+		if !errors.Is(err, domain.ErrCouponExists) {
+			log.Error().Err(err).Msgf("Error creating coupon %s", req.Code)
+		}
+		return coupon, err
+	}
+	return coupon, nil
+}
+
+// UpdateCoupon replaces the settings of a coupon, e.g. to expire it early. Its uses so far are kept.
+func (u *couponUsecase) UpdateCoupon(ctx context.Context, req domain.Coupon) (coupon domain.Coupon, err error) {
+	req.Code = domain.NormalizeCouponCode(req.Code)
+	existing, err := u.GetCoupon(ctx, req.ID)
+	if err != nil {
+		return coupon, err
+	}
+
+	if req.StartsAt.IsZero() {
+		req.StartsAt = existing.StartsAt
+	}
+	err = req.Validate()
+	if err != nil {
+		return coupon, err
+	}
+
+	err = u.repo.UpdateCoupon(ctx, req)
+	if err != nil {
+		if !errors.Is(err, domain.ErrCouponExists) {
+			log.Error().Err(err).Msgf("Error updating coupon %d", req.ID)
+		}
+		return coupon, err
+	}
+
+	req.UsedCount = existing.UsedCount
+	return req, nil
+}
+
+func (u *couponUsecase) DeleteCoupon(ctx context.Context, id int) (err error) {
+	err = u.repo.DeleteCoupon(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return domain.ErrCouponNotFound
+		case errors.Is(err, domain.ErrCouponInUse):
+		default:
+			log.Error().Err(err).Msgf("Error deleting coupon %d", id)
+		}
+		return err
+	}
+	return nil
+}
+
+// ValidateCoupon prices the cart of the user at the current prices and applies the coupon to it, without using it up.
+func (u *couponUsecase) ValidateCoupon(ctx context.Context, cart domain.CouponCart) (application domain.CouponApplication, err error) {
+	cart.Code = domain.NormalizeCouponCode(cart.Code)
+	err = validateCart(cart)
+	if err != nil {
+		return application, err
+	}
+
+	cart.Items, err = u.priceCart(ctx, cart.Items)
+	if err != nil {
+		return application, err
+	}
+
+	coupon, err := u.getCouponByCode(ctx, cart.Code)
+	if err != nil {
+		return application, err
+	}
+
+	categories, err := u.cartCategories(ctx, coupon, cart.Items)
+	if err != nil {
+		return application, err
+	}
+
+	userUses, err := u.repo.CountUserRedemptions(ctx, coupon.ID, cart.UserID)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error counting redemptions of coupon %s", coupon.Code)
+		return application, err
+	}
+
+	return coupon.Apply(cart.Items, categories, userUses, u.now())
+}
+
+// RedeemCoupon uses the coupon on a cart whose line prices were locked by the caller, recording the use
+// under reference. Redeeming a reference again returns its first redemption.
+func (u *couponUsecase) RedeemCoupon(ctx context.Context, cart domain.CouponCart, reference string) (redemption domain.CouponRedemption, err error) {
+	cart.Code = domain.NormalizeCouponCode(cart.Code)
+	err = validateCart(cart)
+	if err != nil {
+		return redemption, err
+	}
+	if reference == "" || cart.UserID <= 0 {
+		return redemption, fmt.Errorf("%w: reference and user_id are required", domain.ErrInvalidCart)
+	}
+
+	coupon, err := u.getCouponByCode(ctx, cart.Code)
+	if err != nil {
+		return redemption, err
+	}
+
+	categories, err := u.cartCategories(ctx, coupon, cart.Items)
+	if err != nil {
+		return redemption, err
+	}
+
+	redemption, err = u.repo.RedeemCoupon(ctx, cart, reference, categories, u.now())
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return redemption, domain.ErrCouponNotFound
+		case errors.Is(err, domain.ErrCouponNotApplicable):
+			log.Warn().Msgf("Redemption %s: %s", reference, err)
+		default:
+			log.Error().Err(err).Msgf("Error redeeming coupon %s for %s", cart.Code, reference)
+		}
+		return redemption, err
+	}
+
+	return redemption, nil
+}
+
+// ReleaseCoupon takes back the redemption recorded under reference, e.g. when the order using it failed.
+func (u *couponUsecase) ReleaseCoupon(ctx context.Context, reference string) (err error) {
+	if reference == "" {
+		return fmt.Errorf("%w: reference is required", domain.ErrInvalidCart)
+	}
+
+	released, err := u.repo.ReleaseRedemption(ctx, reference)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error releasing coupon redemption %s", reference)
+		return err
+	}
+
+	if released {
+		log.Info().Msgf("Released coupon redemption %s", reference)
+	}
+	return nil
+}
+
+func (u *couponUsecase) getCouponByCode(ctx context.Context, code string) (coupon domain.Coupon, err error) {
+	coupon, err = u.repo.GetCouponByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return coupon, domain.ErrCouponNotFound
+		}
+		log.Error().Err(err).Msgf("Error getting coupon %s", code)
+		return coupon, err
+	}
+	return coupon, nil
+}
+
+// priceCart sets the price of every line from the current pricing of its product, priced as one batch.
+func (u *couponUsecase) priceCart(ctx context.Context, items []domain.CartItem) (priced []domain.CartItem, err error) {
+	pricingItems := make([]domain.PricingItem, len(items))
+	for i, item := range items {
+		pricingItems[i] = domain.PricingItem{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+
+	cart, err := u.pricingUsecase.CalculateCartPricing(ctx, pricingItems)
+	if err != nil {
+		return nil, err
+	}
+
+	priced = make([]domain.CartItem, len(items))
+	for i, item := range items {
+		priced[i] = item
+		priced[i].Price = cart.Lines[i].Total
+	}
+	return priced, nil
+}
+
+// cartCategories looks up the category of every product in the cart when the coupon is scoped to a category.
+func (u *couponUsecase) cartCategories(ctx context.Context, coupon domain.Coupon, items []domain.CartItem) (categories map[int]int, err error) {
+	if coupon.CategoryID == 0 {
+		return nil, nil
+	}
+
+	categories = map[int]int{}
+	for _, item := range items {
+		if _, ok := categories[item.ProductID]; ok {
+			continue
+		}
+
+		categories[item.ProductID], err = getProductCategory(ctx, u.productServiceURL, item.ProductID)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error getting category of product %d", item.ProductID)
+			return nil, err
+		}
+	}
+	return categories, nil
+}
+
+func validateCart(cart domain.CouponCart) error {
+	if cart.Code == "" {
+		return fmt.Errorf("%w: code is required", domain.ErrInvalidCart)
+	}
+	if len(cart.Items) == 0 {
+		return fmt.Errorf("%w: the cart has no items", domain.ErrInvalidCart)
+	}
+	for _, item := range cart.Items {
+		if item.ProductID <= 0 || item.Quantity <= 0 || item.Price < 0 {
+			return fmt.Errorf("%w: every item needs a product_id and a positive quantity", domain.ErrInvalidCart)
+		}
+	}
+	return nil
+}
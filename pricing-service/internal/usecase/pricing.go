@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -9,54 +10,137 @@ import (
 	repo "pricing-service/internal/repository/mysql"
 	cache "pricing-service/internal/repository/redis"
 	"pricing-service/pkg/utils"
+	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
 type PricingUsecase interface {
-	CalculatePricing(ctx context.Context, productID int) (price domain.Pricing, err error)
+	CalculatePricing(ctx context.Context, productID, quantity int) (price domain.Pricing, err error)
+	CalculateCartPricing(ctx context.Context, items []domain.PricingItem) (cart domain.CartPricing, err error)
 }
 
 type pricingUsecase struct {
 	repo              repo.PricingRepository
+	promotionRepo     repo.PromotionRepository
 	cache             cache.PricingCache
 	productServiceURL string
+	now               func() time.Time
 }
 
-func NewPricingUsecase(repo repo.PricingRepository, cache cache.PricingCache, productServiceURL string) PricingUsecase {
+// NewPricingUsecase builds the price calculation. Promotions are evaluated at the time now returns,
+// which is time.Now outside of tests.
+func NewPricingUsecase(repo repo.PricingRepository, promotionRepo repo.PromotionRepository, cache cache.PricingCache, productServiceURL string, now func() time.Time) PricingUsecase {
 	return &pricingUsecase{
 		repo:              repo,
+		promotionRepo:     promotionRepo,
 		cache:             cache,
 		productServiceURL: productServiceURL,
+		now:               now,
 	}
 }
 
-// CalculatePricing calculates the final price for a product based on pricing rules.
-func (u *pricingUsecase) CalculatePricing(ctx context.Context, productID int) (price domain.Pricing, err error) {
+// CalculatePricing calculates the final price for a quantity of a product based on pricing rules and the promotions running now.
+func (u *pricingUsecase) CalculatePricing(ctx context.Context, productID, quantity int) (price domain.Pricing, err error) {
+	if quantity <= 0 {
+		return price, domain.ErrInvalidQuantity
+	}
+
 	//  Get the pricing rule for the product
-	pricingRule, err := u.cache.GetPricingRule(ctx, productID)
+	pricingRule, err := u.cache.FetchPricingRule(ctx, productID, func(ctx context.Context) (domain.PricingRule, error) {
+		return u.repo.GetPricingRule(ctx, productID)
+	})
+	if err != nil {
+		return price, err
+	}
+
+	// Step 2: Check product stock
+	available, err := u.checkProductStock(ctx, productID)
 	if err != nil {
 		return
 	}
 
-	if pricingRule.ID == 0 {
-		pricingRule, err = u.repo.GetPricingRule(ctx, productID)
-		if err != nil {
-			return price, fmt.Errorf("pricing rule not found for product %d", productID)
+	// Step 3: Get the promotions running now. The category is only looked up when a category-wide promotion is running.
+	now := u.now()
+	promotions, err := u.promotionRepo.GetActivePromotions(ctx, []int{productID}, now)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error getting promotions of product %d", productID)
+		return price, err
+	}
+
+	categoryID := 0
+	for _, promotion := range promotions {
+		if promotion.CategoryID != 0 {
+			categoryID, err = getProductCategory(ctx, u.productServiceURL, productID)
+			if err != nil {
+				return price, err
+			}
+			break
 		}
+	}
 
-		err = u.cache.SetProduct(ctx, pricingRule, 0)
-		if err != nil {
-			return
+	// Step 4: Calculate and return the pricing
+	return priceProduct(pricingRule, quantity, available, categoryID, promotions, now), nil
+}
+
+// CalculateCartPricing prices every line of a cart like CalculatePricing, but with one cache MGET (and at most one
+// query) for the rules, one stock request for all products and one query for the promotions.
+func (u *pricingUsecase) CalculateCartPricing(ctx context.Context, items []domain.PricingItem) (cart domain.CartPricing, err error) {
+	if len(items) == 0 || len(items) > domain.MaxPricingBatchItems {
+		return cart, domain.ErrInvalidPricingBatch
+	}
+
+	var productIDs []int
+	seen := map[int]bool{}
+	for _, item := range items {
+		if item.ProductID <= 0 || item.Quantity <= 0 {
+			return cart, domain.ErrInvalidPricingBatch
+		}
+		if !seen[item.ProductID] {
+			seen[item.ProductID] = true
+			productIDs = append(productIDs, item.ProductID)
 		}
+	}
 
+	rules, err := u.cache.FetchPricingRules(ctx, productIDs, u.repo.GetPricingRules)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error getting pricing rules of %d products", len(productIDs))
+		return cart, err
+	}
+	for _, productID := range productIDs {
+		if _, ok := rules[productID]; !ok {
+			return cart, fmt.Errorf("%w for product %d", domain.ErrPricingRuleNotFound, productID)
+		}
 	}
 
-	// Step 2: Check product stock
-	available, err := u.checkProductStock(ctx, productID)
+	stock, err := u.getStockLevels(ctx, productIDs)
 	if err != nil {
-		return
+		return cart, err
 	}
 
-	// Step 3: Calculate price based on stock
+	now := u.now()
+	promotions, err := u.promotionRepo.GetActivePromotions(ctx, productIDs, now)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error getting promotions of %d products", len(productIDs))
+		return cart, err
+	}
+
+	cart.Lines = make([]domain.Pricing, len(items))
+	for i, item := range items {
+		level := stock[item.ProductID]
+		cart.Lines[i] = priceProduct(rules[item.ProductID], item.Quantity, level.Stock, level.CategoryID, promotions, now)
+		cart.Quantity += item.Quantity
+		cart.Total += cart.Lines[i].Total
+	}
+
+	return cart, nil
+}
+
+// priceProduct calculates the price of quantity units of a product from its rule, its available stock and the
+// promotions running at now. Promotions of other products and categories are ignored. The quantity tier and
+// bundle of the rule only change the unit price and total, FinalPrice stays the price of a single unit.
+func priceProduct(pricingRule domain.PricingRule, quantity, available, categoryID int, promotions []domain.Promotion, now time.Time) domain.Pricing {
+	productID := pricingRule.ProductID
 	markup := pricingRule.DefaultMarkup
 	discount := pricingRule.DefaultDiscount
 
@@ -66,23 +150,85 @@ func (u *pricingUsecase) CalculatePricing(ctx context.Context, productID int) (p
 		discount -= pricingRule.DiscountReduction
 	}
 
-	// Step 4: Calculate the final price
+	// Pick the promotions of the product and its category
+	var candidates []domain.Promotion
+	for _, promotion := range promotions {
+		if promotion.ProductID == productID || (promotion.CategoryID != 0 && promotion.CategoryID == categoryID) {
+			candidates = append(candidates, promotion)
+		}
+	}
+	priceChange, discounts := domain.SelectPromotions(candidates, now)
+
+	// Calculate the final price, with a scheduled price change replacing the rule's price
 	productPrice := pricingRule.ProductPrice
+	var applied []domain.Promotion
+	if priceChange != nil {
+		productPrice = priceChange.Price
+		applied = append(applied, *priceChange)
+	}
+
 	finalPrice := productPrice * (1 + markup) * (1 - discount)
+	for _, promotion := range discounts {
+		finalPrice *= 1 - promotion.Discount
+		applied = append(applied, promotion)
+	}
 
-	// Step 5: Return the calculated pricing
-	price = domain.Pricing{
+	pricing := domain.Pricing{
 		ProductID:  productID,
+		Quantity:   quantity,
 		Markup:     markup,
 		Discount:   discount,
 		FinalPrice: finalPrice,
+		Promotions: applied,
+		UnitPrice:  finalPrice,
+	}
+
+	// Apply the tier reached by the quantity, then give away the free units of the bundle
+	if tier := pricingRule.TierFor(quantity); tier != nil {
+		pricing.Tier = tier
+		pricing.UnitPrice *= 1 - tier.Discount
 	}
-	return price, nil
+	if pricingRule.Bundle != nil {
+		pricing.FreeUnits = pricingRule.Bundle.FreeUnits(quantity)
+		if pricing.FreeUnits > 0 {
+			pricing.Bundle = pricingRule.Bundle
+		}
+	}
+	pricing.Total = pricing.UnitPrice * float64(quantity-pricing.FreeUnits)
+
+	return pricing
+}
+
+// getProductCategory returns the category of the product, or 0 when it has none.
+func getProductCategory(ctx context.Context, productServiceURL string, productID int) (categoryID int, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/products/%d", productServiceURL, productID), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("product not available")
+	}
+
+	var product struct {
+		CategoryID int `json:"category_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&product); err != nil {
+		return 0, err
+	}
+
+	return product.CategoryID, nil
 }
 
 // checkProductStock checks if the product is available in the required quantity.
 func (u *pricingUsecase) checkProductStock(ctx context.Context, productID int) (availableStock int, err error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/products/%d/stock", u.productServiceURL, productID), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/products/%d/stock", u.productServiceURL, productID), nil)
 	if err != nil {
 		return 0, err
 	}
@@ -104,11 +250,70 @@ func (u *pricingUsecase) checkProductStock(ctx context.Context, productID int) (
 		return 0, fmt.Errorf("product not available")
 	}
 
-	var stockData map[string]int
+	// The response also breaks the stock down per warehouse; pricing only needs the total
+	var stockData struct {
+		Stock int `json:"stock"`
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&stockData); err != nil {
 		return 0, err
 	}
 
-	availableStock = stockData["stock"]
+	availableStock = stockData.Stock
 	return availableStock, nil
 }
+
+// productStockLevel is the stock and category of a product as returned by the batch stock lookup of product-service.
+type productStockLevel struct {
+	ProductID  int `json:"product_id"`
+	CategoryID int `json:"category_id"`
+	Stock      int `json:"stock"`
+}
+
+// getStockLevels gets the stock and category of all products in one request. A product product-service
+// does not know is reported as not available, like checkProductStock does.
+func (u *pricingUsecase) getStockLevels(ctx context.Context, productIDs []int) (levels map[int]productStockLevel, err error) {
+	body, err := json.Marshal(map[string][]int{"product_ids": productIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.productServiceURL+"/api/products/stock/batch", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	token, err := utils.GetTokenFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get stock: status %d", resp.StatusCode)
+	}
+
+	var stockData struct {
+		Products []productStockLevel `json:"products"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stockData); err != nil {
+		return nil, err
+	}
+
+	levels = make(map[int]productStockLevel, len(stockData.Products))
+	for _, level := range stockData.Products {
+		levels[level.ProductID] = level
+	}
+	for _, productID := range productIDs {
+		if _, ok := levels[productID]; !ok {
+			return nil, fmt.Errorf("product %d not available", productID)
+		}
+	}
+	return levels, nil
+}
@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"pricing-service/domain"
+	repo "pricing-service/internal/repository/mysql"
+	cache "pricing-service/internal/repository/redis"
+
+	"github.com/rs/zerolog/log"
+)
+
+type PricingRuleUsecase interface {
+	ListPricingRules(ctx context.Context, limit, offset int) (page domain.PricingRulePage, err error)
+	GetPricingRule(ctx context.Context, productID int) (rule domain.PricingRule, err error)
+	CreatePricingRule(ctx context.Context, req domain.PricingRule) (rule domain.PricingRule, err error)
+	UpdatePricingRule(ctx context.Context, req domain.PricingRule) (rule domain.PricingRule, err error)
+	DeletePricingRule(ctx context.Context, productID int) (err error)
+}
+
+type pricingRuleUsecase struct {
+	repo  repo.PricingRepository
+	cache cache.PricingCache
+}
+
+func NewPricingRuleUsecase(repo repo.PricingRepository, cache cache.PricingCache) PricingRuleUsecase {
+	return &pricingRuleUsecase{repo: repo, cache: cache}
+}
+
+func (u *pricingRuleUsecase) ListPricingRules(ctx context.Context, limit, offset int) (page domain.PricingRulePage, err error) {
+	rules, total, err := u.repo.ListPricingRules(ctx, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Error listing pricing rules")
+		return page, err
+	}
+
+	if rules == nil {
+		rules = []domain.PricingRule{}
+	}
+	return domain.PricingRulePage{Rules: rules, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// GetPricingRule reads the rule of a product from the database, so admins see what is stored rather than what is cached.
+func (u *pricingRuleUsecase) GetPricingRule(ctx context.Context, productID int) (rule domain.PricingRule, err error) {
+	rule, err = u.repo.GetPricingRule(ctx, productID)
+	if err != nil && !errors.Is(err, domain.ErrPricingRuleNotFound) {
+		log.Error().Err(err).Msgf("Error getting pricing rule of product %d", productID)
+	}
+	return rule, err
+}
+
+func (u *pricingRuleUsecase) CreatePricingRule(ctx context.Context, req domain.PricingRule) (rule domain.PricingRule, err error) {
+	err = req.Validate()
+	if err != nil {
+		return rule, err
+	}
+
+	rule, err = u.repo.CreatePricingRule(ctx, req)
+	if err != nil {
+		if !errors.Is(err, domain.ErrPricingRuleExists) {
+			log.Error().Err(err).Msgf("Error creating pricing rule of product %d", req.ProductID)
+		}
+		return rule, err
+	}
+
+	return rule, nil
+}
+
+// UpdatePricingRule replaces the rule of a product. Prices calculated from then on use the new rule.
+func (u *pricingRuleUsecase) UpdatePricingRule(ctx context.Context, req domain.PricingRule) (rule domain.PricingRule, err error) {
+	err = req.Validate()
+	if err != nil {
+		return rule, err
+	}
+
+	existing, err := u.GetPricingRule(ctx, req.ProductID)
+	if err != nil {
+		return rule, err
+	}
+
+	err = u.repo.UpdatePricingRule(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error updating pricing rule of product %d", req.ProductID)
+		return rule, err
+	}
+
+	u.invalidatePricingRule(ctx, req.ProductID)
+	req.ID = existing.ID
+	return req, nil
+}
+
+func (u *pricingRuleUsecase) DeletePricingRule(ctx context.Context, productID int) (err error) {
+	err = u.repo.DeletePricingRule(ctx, productID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrPricingRuleNotFound
+		}
+		log.Error().Err(err).Msgf("Error deleting pricing rule of product %d", productID)
+		return err
+	}
+
+	u.invalidatePricingRule(ctx, productID)
+	return nil
+}
+
+// invalidatePricingRule drops the cached rule after a write has been committed.
+func (u *pricingRuleUsecase) invalidatePricingRule(ctx context.Context, productID int) {
+	err := u.cache.DeletePricingRule(ctx, productID)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error invalidating pricing rule of product %d in cache", productID)
+	}
+}
@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"pricing-service/domain"
+	repo "pricing-service/internal/repository/mysql"
+	cache "pricing-service/internal/repository/redis"
+)
+
+// stubPromotions returns every promotion it holds, leaving it to the usecase to pick those running now.
+type stubPromotions struct {
+	repo.PromotionRepository
+	promotions []domain.Promotion
+}
+
+func (s stubPromotions) GetActivePromotions(ctx context.Context, productIDs []int, now time.Time) ([]domain.Promotion, error) {
+	return s.promotions, nil
+}
+
+// stubRules serves pricing rules as if they were all cached, so the repository is never asked.
+type stubRules struct {
+	cache.PricingCache
+	rules map[int]domain.PricingRule
+}
+
+type stubRuleRepository struct {
+	repo.PricingRepository
+}
+
+func (stubRuleRepository) GetPricingRules(ctx context.Context, productIDs []int) ([]domain.PricingRule, error) {
+	return nil, nil
+}
+
+func (s stubRules) FetchPricingRules(ctx context.Context, productIDs []int, load func(ctx context.Context, productIDs []int) ([]domain.PricingRule, error)) (map[int]domain.PricingRule, error) {
+	return s.rules, nil
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCalculateCartPricingEvaluatesPromotionsAtClock(t *testing.T) {
+	start := time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC)
+	end := start.Add(72 * time.Hour)
+
+	rules := stubRules{rules: map[int]domain.PricingRule{1: {ProductID: 1, ProductPrice: 100}}}
+	promotions := stubPromotions{promotions: []domain.Promotion{
+		{ID: 1, Type: domain.PromotionDiscount, ProductID: 1, Discount: 0.2, Priority: 1, StartsAt: start, EndsAt: &end},
+		{ID: 2, Type: domain.PromotionPriceChange, ProductID: 1, Price: 90, Priority: 1, StartsAt: end},
+	}}
+	server := newProductService(t, []productStockLevel{{ProductID: 1, Stock: 50}})
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want float64
+	}{
+		{"before the sale", start.Add(-time.Nanosecond), 100},
+		{"sale starts", start, 80},
+		{"last moment of the sale", end.Add(-time.Nanosecond), 80},
+		{"new price from the end of the sale", end, 90},
+		{"new price after the sale", end.Add(time.Hour), 90},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := NewPricingUsecase(stubRuleRepository{}, promotions, rules, server.URL, func() time.Time { return tt.now })
+
+			cart, err := u.CalculateCartPricing(authorizedContext(), []domain.PricingItem{{ProductID: 1, Quantity: 1}})
+			if err != nil {
+				t.Fatalf("CalculateCartPricing: %v", err)
+			}
+			if !almostEqual(cart.Total, tt.want) {
+				t.Errorf("total %v, want %v", cart.Total, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceProduct(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tiers := []domain.QuantityTier{{MinQuantity: 5, Discount: 0.1}, {MinQuantity: 10, Discount: 0.2}}
+	bundle := &domain.Bundle{BuyQuantity: 2, FreeQuantity: 1}
+	saleOf := func(productID, categoryID int, discount float64) domain.Promotion {
+		return domain.Promotion{ID: 1, Type: domain.PromotionDiscount, ProductID: productID, CategoryID: categoryID, Discount: discount, Priority: 1, StartsAt: now.Add(-time.Hour)}
+	}
+	newPrice := domain.Promotion{ID: 2, Type: domain.PromotionPriceChange, ProductID: 1, Price: 50, Priority: 1, StartsAt: now.Add(-time.Hour)}
+
+	tests := []struct {
+		name       string
+		rule       domain.PricingRule
+		quantity   int
+		available  int
+		promotions []domain.Promotion
+		finalPrice float64 // price of one unit before the tier
+		unitPrice  float64
+		freeUnits  int
+		total      float64
+	}{
+		{"below the first tier", domain.PricingRule{Tiers: tiers}, 4, 100, nil, 100, 100, 0, 400},
+		{"at the first tier", domain.PricingRule{Tiers: tiers}, 5, 100, nil, 100, 90, 0, 450},
+		{"just below the second tier", domain.PricingRule{Tiers: tiers}, 9, 100, nil, 100, 90, 0, 810},
+		{"at the second tier", domain.PricingRule{Tiers: tiers}, 10, 100, nil, 100, 80, 0, 800},
+		{"above the last tier", domain.PricingRule{Tiers: tiers}, 50, 100, nil, 100, 80, 0, 4000},
+
+		{"bundle not complete", domain.PricingRule{Bundle: bundle}, 2, 100, nil, 100, 100, 0, 200},
+		{"one complete bundle", domain.PricingRule{Bundle: bundle}, 3, 100, nil, 100, 100, 1, 200},
+		{"incomplete second bundle rounds down", domain.PricingRule{Bundle: bundle}, 5, 100, nil, 100, 100, 1, 400},
+		{"two complete bundles", domain.PricingRule{Bundle: bundle}, 6, 100, nil, 100, 100, 2, 400},
+		{"tier and bundle", domain.PricingRule{Tiers: tiers, Bundle: bundle}, 6, 100, nil, 100, 90, 2, 360},
+
+		{"low stock markup below the tier", domain.PricingRule{DefaultMarkup: 0.1, MarkupIncrease: 0.1, StockThreshold: 10, Tiers: tiers}, 4, 5, nil, 120, 120, 0, 480},
+		{"sale and tier", domain.PricingRule{Tiers: tiers}, 5, 100, []domain.Promotion{saleOf(1, 0, 0.2)}, 80, 72, 0, 360},
+		{"category sale and bundle", domain.PricingRule{Bundle: bundle}, 3, 100, []domain.Promotion{saleOf(0, 7, 0.5)}, 50, 50, 1, 100},
+		{"price change, tier and bundle", domain.PricingRule{Tiers: tiers, Bundle: bundle}, 6, 100, []domain.Promotion{newPrice}, 50, 45, 2, 180},
+		{"sale of another product", domain.PricingRule{Tiers: tiers}, 5, 100, []domain.Promotion{saleOf(2, 8, 0.5)}, 100, 90, 0, 450},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			rule.ProductID = 1
+			rule.ProductPrice = 100
+
+			pricing := priceProduct(rule, tt.quantity, tt.available, 7, tt.promotions, now)
+
+			if !almostEqual(pricing.FinalPrice, tt.finalPrice) {
+				t.Errorf("final price %v, want %v", pricing.FinalPrice, tt.finalPrice)
+			}
+			if !almostEqual(pricing.UnitPrice, tt.unitPrice) {
+				t.Errorf("unit price %v, want %v", pricing.UnitPrice, tt.unitPrice)
+			}
+			if pricing.FreeUnits != tt.freeUnits {
+				t.Errorf("%d free units, want %d", pricing.FreeUnits, tt.freeUnits)
+			}
+			if (pricing.Bundle != nil) != (tt.freeUnits > 0) {
+				t.Errorf("bundle %v with %d free units", pricing.Bundle, pricing.FreeUnits)
+			}
+			if !almostEqual(pricing.Total, tt.total) {
+				t.Errorf("total %v, want %v", pricing.Total, tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"pricing-service/domain"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+)
+
+// newProductService serves the product-service routes pricing calls, mounted under /api like
+// product-service mounts them, and fails the test on a request without the caller's token.
+func newProductService(t *testing.T, levels []productStockLevel) *httptest.Server {
+	t.Helper()
+
+	router := mux.NewRouter()
+	products := router.PathPrefix("/api").Subrouter().PathPrefix("/products").Subrouter()
+	products.HandleFunc("/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		for _, level := range levels {
+			if mux.Vars(r)["id"] == strconv.Itoa(level.ProductID) {
+				json.NewEncoder(w).Encode(map[string]int{"id": level.ProductID, "category_id": level.CategoryID})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+	products.HandleFunc("/stock/batch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("stock batch called with Authorization %q", r.Header.Get("Authorization"))
+		}
+
+		var req struct {
+			ProductIDs []int `json:"product_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding stock batch request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string][]productStockLevel{"products": levels})
+	}).Methods("POST")
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// authorizedContext carries a parsed token like the JWT middleware leaves it.
+func authorizedContext() context.Context {
+	return context.WithValue(context.Background(), domain.AuthorizationKey, &jwt.Token{Raw: "test-token"})
+}
+
+func TestGetStockLevelsCallsProductServiceRoute(t *testing.T) {
+	server := newProductService(t, []productStockLevel{
+		{ProductID: 1, CategoryID: 7, Stock: 12},
+		{ProductID: 2, Stock: 0},
+	})
+	u := &pricingUsecase{productServiceURL: server.URL}
+
+	levels, err := u.getStockLevels(authorizedContext(), []int{1, 2})
+	if err != nil {
+		t.Fatalf("getStockLevels: %v", err)
+	}
+
+	if levels[1].Stock != 12 || levels[1].CategoryID != 7 {
+		t.Errorf("product 1: got %+v", levels[1])
+	}
+	if _, ok := levels[2]; !ok {
+		t.Errorf("product 2 is missing")
+	}
+}
+
+func TestGetStockLevelsReportsUnknownProducts(t *testing.T) {
+	server := newProductService(t, []productStockLevel{{ProductID: 1, Stock: 3}})
+	u := &pricingUsecase{productServiceURL: server.URL}
+
+	_, err := u.getStockLevels(authorizedContext(), []int{1, 2})
+	if err == nil {
+		t.Fatal("expected an error for product 2")
+	}
+}
+
+func TestGetProductCategoryCallsProductServiceRoute(t *testing.T) {
+	server := newProductService(t, []productStockLevel{{ProductID: 5, CategoryID: 9}})
+
+	categoryID, err := getProductCategory(context.Background(), server.URL, 5)
+	if err != nil {
+		t.Fatalf("getProductCategory: %v", err)
+	}
+	if categoryID != 9 {
+		t.Errorf("got category %d, want 9", categoryID)
+	}
+
+	_, err = getProductCategory(context.Background(), server.URL, 6)
+	if err == nil {
+		t.Error("expected an error for an unknown product")
+	}
+}
@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"pricing-service/domain"
+	repo "pricing-service/internal/repository/mysql"
+
+	"github.com/rs/zerolog/log"
+)
+
+type PromotionUsecase interface {
+	ListPromotions(ctx context.Context, filter domain.PromotionFilter) (page domain.PromotionPage, err error)
+	GetPromotion(ctx context.Context, id int) (promotion domain.Promotion, err error)
+	CreatePromotion(ctx context.Context, req domain.Promotion) (promotion domain.Promotion, err error)
+	UpdatePromotion(ctx context.Context, req domain.Promotion) (promotion domain.Promotion, err error)
+	DeletePromotion(ctx context.Context, id int) (err error)
+}
+
+type promotionUsecase struct {
+	repo repo.PromotionRepository
+}
+
+func NewPromotionUsecase(repo repo.PromotionRepository) PromotionUsecase {
+	return &promotionUsecase{repo: repo}
+}
+
+func (u *promotionUsecase) ListPromotions(ctx context.Context, filter domain.PromotionFilter) (page domain.PromotionPage, err error) {
+	promotions, total, err := u.repo.ListPromotions(ctx, filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Error listing promotions")
+		return page, err
+	}
+
+	if promotions == nil {
+		promotions = []domain.Promotion{}
+	}
+	return domain.PromotionPage{Promotions: promotions, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+func (u *promotionUsecase) GetPromotion(ctx context.Context, id int) (promotion domain.Promotion, err error) {
+	promotion, err = u.repo.GetPromotion(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return promotion, domain.ErrPromotionNotFound
+		}
+		log.Error().Err(err).Msgf("Error getting promotion %d", id)
+		return promotion, err
+	}
+	return promotion, nil
+}
+
+// CreatePromotion schedules a promotion. It is picked up by price calculations once it starts.
+func (u *promotionUsecase) CreatePromotion(ctx context.Context, req domain.Promotion) (promotion domain.Promotion, err error) {
+	req.Name = strings.TrimSpace(req.Name)
+	err = req.Validate()
+	if err != nil {
+		return promotion, err
+	}
+
+	promotion, err = u.repo.CreatePromotion(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error creating promotion %s", req.Name)
+		return promotion, err
+	}
+	return promotion, nil
+}
+
+// UpdatePromotion replaces a promotion, e.g. to end it early or move it.
+func (u *promotionUsecase) UpdatePromotion(ctx context.Context, req domain.Promotion) (promotion domain.Promotion, err error) {
+	req.Name = strings.TrimSpace(req.Name)
+	err = req.Validate()
+	if err != nil {
+		return promotion, err
+	}
+
+	_, err = u.GetPromotion(ctx, req.ID)
+	if err != nil {
+		return promotion, err
+	}
+
+	err = u.repo.UpdatePromotion(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error updating promotion %d", req.ID)
+		return promotion, err
+	}
+	return req, nil
+}
+
+func (u *promotionUsecase) DeletePromotion(ctx context.Context, id int) (err error) {
+	err = u.repo.DeletePromotion(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrPromotionNotFound
+		}
+		log.Error().Err(err).Msgf("Error deleting promotion %d", id)
+		return err
+	}
+	return nil
+}
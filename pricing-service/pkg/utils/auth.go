@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"pricing-service/domain"
+
+	"github.com/golang-jwt/jwt/v4"
 )
 
 type UserAuth struct {
@@ -29,10 +31,11 @@ func GetUserFromContext(ctx context.Context) (user UserAuth, err error) {
 	return user, nil
 }
 
+// GetTokenFromContext returns the raw JWT of the request, which the JWT middleware stores parsed
 func GetTokenFromContext(ctx context.Context) (token string, err error) {
-	token, ok := ctx.Value(domain.AuthorizationKey).(string)
+	parsed, ok := ctx.Value(domain.AuthorizationKey).(*jwt.Token)
 	if !ok {
 		return "", errors.New("could not get token from context")
 	}
-	return
+	return parsed.Raw, nil
 }
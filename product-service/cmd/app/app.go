@@ -1,27 +1,67 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 
+	"product-service/config"
+	"product-service/config/kafka"
 	"product-service/internal/consumer"
 	"product-service/internal/delivery/rest"
 	repo "product-service/internal/repository/mysql"
 	cache "product-service/internal/repository/redis"
+	"product-service/internal/sweeper"
 	"product-service/internal/usecase"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
 )
 
-func NewApp(router *mux.Router, db *sql.DB, rdb *redis.Client) {
+func NewApp(ctx context.Context, router *mux.Router, db *sql.DB, rdb *redis.Client) {
 	productRepo := repo.NewProductRepository(db)
-	productCache := cache.NewProductCache(rdb)
-	productUsecase := usecase.NewProductUsecase(productRepo, productCache)
+	variantRepo := repo.NewVariantRepository(db)
+	categoryRepo := repo.NewCategoryRepository(db)
+	warehouseRepo := repo.NewWarehouseRepository(db)
+	productCache := cache.NewProductCache(rdb, config.AppConfig.Cache)
+	productUsecase := usecase.NewProductUsecase(productRepo, variantRepo, categoryRepo, warehouseRepo, productCache)
+	categoryUsecase := usecase.NewCategoryUsecase(categoryRepo)
+	warehouseUsecase := usecase.NewWarehouseUsecase(warehouseRepo, productCache)
+
+	// Stock columns are checked against the inventory ledger in the background, and stock level
+	// events are published on the inventory topic
+	inventoryWriter := kafka.NewKafkaWriter(config.AppConfig, sweeper.InventoryTopic)
+	inventoryRepo := repo.NewInventoryRepository(db)
+	inventoryUsecase := usecase.NewInventoryUsecase(inventoryRepo, productCache, inventoryWriter)
+	inventoryReconciler := sweeper.NewInventoryReconciler(inventoryUsecase, config.AppConfig.Inventory.ReconcileInterval)
+	go inventoryReconciler.Start(ctx)
+	inventoryEventPublisher := sweeper.NewInventoryEventPublisher(inventoryUsecase, config.AppConfig.Inventory.EventInterval)
+	go inventoryEventPublisher.Start(ctx)
+
+	// Failed order events go to the dead-letter topic and can be re-driven onto the order topic
+	orderWriter := kafka.NewKafkaWriter(config.AppConfig, consumer.OrderTopic)
+	dlqWriter := kafka.NewKafkaWriter(config.AppConfig, consumer.OrderDeadLetterTopic)
+	deadLetterRepo := repo.NewDeadLetterRepository(db)
+	deadLetterUsecase := usecase.NewDeadLetterUsecase(deadLetterRepo, orderWriter)
+
+	// Expired reservations are released in the background and announced on the reservation topic
+	reservationWriter := kafka.NewKafkaWriter(config.AppConfig, sweeper.ReservationTopic)
+	reservationRepo := repo.NewReservationRepository(db)
+	reservationUsecase := usecase.NewReservationUsecase(reservationRepo, productCache, reservationWriter, config.AppConfig.Reservation)
+	reservationSweeper := sweeper.NewReservationSweeper(reservationUsecase, config.AppConfig.Reservation.SweepInterval)
+	go reservationSweeper.Start(ctx)
 
 	productHandler := rest.NewProductHandler(productUsecase)
+	categoryHandler := rest.NewCategoryHandler(categoryUsecase)
+	reservationHandler := rest.NewReservationHandler(reservationUsecase)
+	warehouseHandler := rest.NewWarehouseHandler(warehouseUsecase)
+	inventoryHandler := rest.NewInventoryHandler(inventoryUsecase)
+	deadLetterHandler := rest.NewDeadLetterHandler(deadLetterUsecase)
+
+	orderConsumer := consumer.NewConsumer(productUsecase, reservationUsecase, dlqWriter, config.AppConfig.Kafka)
+	go orderConsumer.StartKafkaConsumer()
 
-	consumer := consumer.NewConsumer(productUsecase)
-	go consumer.StartKafkaConsumer()
+	deadLetterConsumer := consumer.NewDeadLetterConsumer(deadLetterUsecase, config.AppConfig.Kafka)
+	go deadLetterConsumer.StartKafkaConsumer()
 
-	rest.RegisterRoutes(router, productHandler)
+	rest.RegisterRoutes(router, productHandler, categoryHandler, reservationHandler, warehouseHandler, inventoryHandler, deadLetterHandler)
 }
@@ -57,7 +57,7 @@ func main() {
 	// Router setup
 	router := mux.NewRouter()
 
-	app.NewApp(router, db, rdb)
+	app.NewApp(ctx, router, db, rdb)
 
 	// Start server
 	server := &http.Server{
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,6 +18,11 @@ type Config struct {
 	Redis  RedisConfig
 	Jwt    JwtConfig
 	Log    LogConfig
+	Kafka  KafkaConfig
+	Cache  CacheConfig
+	// Stock reservations
+	Reservation ReservationConfig
+	Inventory   InventoryConfig
 }
 
 type ServerConfig struct {
@@ -47,6 +53,31 @@ type LogConfig struct {
 	LogFilePath    string
 }
 
+type KafkaConfig struct {
+	Host string
+	Port string
+	// Consumer retry policy before a message is sent to the dead-letter topic
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+type CacheConfig struct {
+	ProductTTL time.Duration
+	Jitter     float64 // share of the TTL added at random so entries do not expire together
+}
+
+type ReservationConfig struct {
+	DefaultTTL    time.Duration
+	MaxTTL        time.Duration
+	SweepInterval time.Duration // how often expired reservations are released
+}
+
+type InventoryConfig struct {
+	ReconcileInterval time.Duration // how often stock is checked against the inventory ledger
+	EventInterval     time.Duration // how often queued stock level events are published
+}
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() {
 	// Load .env file if it exists
@@ -77,9 +108,23 @@ func LoadConfig() {
 			Type:        getEnv("LOG_TYPE", "json"),
 			LogFilePath: getEnv("LOG_FILE_PATH", "logs/app.log"),
 		},
+		Kafka: KafkaConfig{
+			Host: getEnv("KAFKA_HOST", "localhost"),
+			Port: getEnv("KAFKA_PORT", "9092"),
+		},
 	}
 
 	AppConfig.Log.LogFileEnabled, _ = strconv.ParseBool(getEnv("LOG_FILE_ENABLED", "true"))
+	AppConfig.Kafka.MaxRetries, _ = strconv.Atoi(getEnv("KAFKA_CONSUMER_MAX_RETRIES", "5"))
+	AppConfig.Kafka.InitialBackoff = getEnvDuration("KAFKA_CONSUMER_INITIAL_BACKOFF", 500*time.Millisecond)
+	AppConfig.Kafka.MaxBackoff = getEnvDuration("KAFKA_CONSUMER_MAX_BACKOFF", 30*time.Second)
+	AppConfig.Cache.ProductTTL, _ = time.ParseDuration(getEnv("CACHE_PRODUCT_TTL", "10m"))
+	AppConfig.Cache.Jitter, _ = strconv.ParseFloat(getEnv("CACHE_TTL_JITTER", "0.1"), 64)
+	AppConfig.Reservation.DefaultTTL = getEnvDuration("RESERVATION_TTL", 15*time.Minute)
+	AppConfig.Reservation.MaxTTL = getEnvDuration("RESERVATION_MAX_TTL", 24*time.Hour)
+	AppConfig.Reservation.SweepInterval = getEnvDuration("RESERVATION_SWEEP_INTERVAL", 30*time.Second)
+	AppConfig.Inventory.ReconcileInterval = getEnvDuration("INVENTORY_RECONCILE_INTERVAL", time.Hour)
+	AppConfig.Inventory.EventInterval = getEnvDuration("INVENTORY_EVENT_INTERVAL", 5*time.Second)
 
 }
 
@@ -90,3 +135,19 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getEnvDuration reads a positive duration such as "30s" from the environment. Values that do not parse or are
+// not positive fall back to the default, as a zero interval would make time.NewTicker panic.
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Warning: %s=%q is not a positive duration such as \"30s\", using %s", key, value, fallback)
+		return fallback
+	}
+	return duration
+}
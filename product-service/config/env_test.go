@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetEnvDuration(t *testing.T) {
+	const fallback = 30 * time.Second
+
+	tests := []struct {
+		name  string
+		value string // empty for unset
+		want  time.Duration
+	}{
+		{"unset", "", fallback},
+		{"duration", "2m", 2 * time.Minute},
+		{"number without unit", "30", fallback},
+		{"zero", "0", fallback},
+		{"zero with unit", "0s", fallback},
+		{"negative", "-5s", fallback},
+		{"garbage", "soon", fallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.value != "" {
+				t.Setenv("TEST_INTERVAL", tt.value)
+			}
+
+			if got := getEnvDuration("TEST_INTERVAL", fallback); got != tt.want {
+				t.Errorf("getEnvDuration with %q = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
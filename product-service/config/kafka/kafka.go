@@ -0,0 +1,19 @@
+package kafka
+
+import (
+	"fmt"
+	"product-service/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func NewKafkaWriter(cfg *config.Config, topic string) *kafka.Writer {
+	kafkaConfig := cfg.Kafka
+	broker := fmt.Sprintf("%s:%s", kafkaConfig.Host, kafkaConfig.Port)
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(broker),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{}, // Same key always lands on the same partition
+		AllowAutoTopicCreation: true,
+	}
+}
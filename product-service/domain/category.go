@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const MaxCategoryNameLength = 255
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrInvalidCategory  = errors.New("invalid category")
+	ErrCategoryInUse    = errors.New("category still has subcategories or products")
+)
+
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Category groups products. Categories form a tree through ParentID; root categories have none.
+type Category struct {
+	ID       int        `json:"id"`
+	ParentID *int       `json:"parent_id"`
+	Name     string     `json:"name"`
+	Slug     string     `json:"slug"`
+	Children []Category `json:"children,omitempty"`
+}
+
+// Validate checks the fields an admin may set on a category.
+func (c Category) Validate() error {
+	name := strings.TrimSpace(c.Name)
+	if name == "" || utf8.RuneCountInString(name) > MaxCategoryNameLength {
+		return fmt.Errorf("%w: name must be between 1 and %d characters", ErrInvalidCategory, MaxCategoryNameLength)
+	}
+	if len(c.Slug) > MaxCategoryNameLength || !categorySlugPattern.MatchString(c.Slug) {
+		return fmt.Errorf("%w: slug must be lowercase letters and digits separated by dashes", ErrInvalidCategory)
+	}
+	if c.ParentID != nil && *c.ParentID == c.ID && c.ID != 0 {
+		return fmt.Errorf("%w: a category cannot be its own parent", ErrInvalidCategory)
+	}
+	return nil
+}
+
+// BuildCategoryTree nests flat categories under their parents and returns the roots. Categories whose
+// parent is not in the list are returned as roots.
+func BuildCategoryTree(categories []Category) []Category {
+	children := map[int][]Category{}
+	known := make(map[int]bool, len(categories))
+	for _, category := range categories {
+		known[category.ID] = true
+	}
+
+	var roots []Category
+	for _, category := range categories {
+		if category.ParentID == nil || !known[*category.ParentID] {
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], category)
+	}
+
+	var attach func(category Category) Category
+	attach = func(category Category) Category {
+		for _, child := range children[category.ID] {
+			category.Children = append(category.Children, attach(child))
+		}
+		return category
+	}
+
+	tree := make([]Category, 0, len(roots))
+	for _, root := range roots {
+		tree = append(tree, attach(root))
+	}
+	return tree
+}
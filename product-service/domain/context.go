@@ -7,5 +7,11 @@ const (
 	UserIDlKey       contextKey = "user_id"
 	UserNameKey      contextKey = "username"
 	UserEmailKey     contextKey = "email"
+	UserRoleKey      contextKey = "role"
 	AuthorizationKey contextKey = "Authorization"
 )
+
+const (
+	RoleAdmin   = "admin"   // JWT role allowed to use admin endpoints
+	RoleService = "service" // JWT role of other services, e.g. order-service reserving stock for orders
+)
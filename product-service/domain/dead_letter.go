@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+const (
+	DeadLetterStatusPending  = "pending"
+	DeadLetterStatusRedriven = "redriven"
+)
+
+// Kafka headers added to messages published to a dead-letter topic
+const (
+	DeadLetterHeaderError             = "dlq-error"
+	DeadLetterHeaderAttempts          = "dlq-attempts"
+	DeadLetterHeaderOriginalTopic     = "dlq-original-topic"
+	DeadLetterHeaderOriginalPartition = "dlq-original-partition"
+	DeadLetterHeaderOriginalOffset    = "dlq-original-offset"
+	DeadLetterHeaderFailedAt          = "dlq-failed-at"
+)
+
+// DeadLetter is a message that could not be processed after all retries, kept so an admin can re-drive it.
+type DeadLetter struct {
+	ID                int               `json:"id"`
+	OriginalTopic     string            `json:"original_topic"`
+	OriginalPartition int               `json:"original_partition"`
+	OriginalOffset    int64             `json:"original_offset"`
+	Key               string            `json:"key"`
+	Value             string            `json:"value"`
+	Headers           map[string]string `json:"headers"`
+	Error             string            `json:"error"`
+	Attempts          int               `json:"attempts"`
+	Status            string            `json:"status"`
+	FailedAt          time.Time         `json:"failed_at"`
+	RedrivenAt        *time.Time        `json:"redriven_at"`
+}
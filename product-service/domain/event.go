@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SupportedOrderEventSchemaVersion is the only order event envelope version this service understands.
+const SupportedOrderEventSchemaVersion = 1
+
+const (
+	OrderEventCreated   = "order.created"
+	OrderEventUpdated   = "order.updated"
+	OrderEventCancelled = "order.cancelled"
+)
+
+// Kafka headers carried alongside every order event
+const (
+	EventHeaderID            = "event-id"
+	EventHeaderType          = "event-type"
+	EventHeaderSchemaVersion = "schema-version"
+)
+
+var (
+	ErrUnknownEventType         = errors.New("unknown order event type")
+	ErrUnsupportedSchemaVersion = errors.New("unsupported order event schema version")
+)
+
+// OrderEvent is the envelope consumed from order-topic, as produced by order-service.
+type OrderEvent struct {
+	EventID       string          `json:"event_id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	AggregateID   int             `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Validate rejects envelopes of an unknown type or version instead of guessing their shape.
+func (e OrderEvent) Validate() error {
+	if e.SchemaVersion != SupportedOrderEventSchemaVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedSchemaVersion, e.SchemaVersion)
+	}
+
+	switch e.Type {
+	case OrderEventCreated, OrderEventUpdated, OrderEventCancelled:
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownEventType, e.Type)
+	}
+
+	if e.EventID == "" || e.AggregateID == 0 || len(e.Payload) == 0 {
+		return errors.New("order event is missing event_id, aggregate_id or payload")
+	}
+
+	return nil
+}
+
+// Order decodes the envelope payload.
+func (e OrderEvent) Order() (order Order, err error) {
+	err = json.Unmarshal(e.Payload, &order)
+	return order, err
+}
@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reasons of the movements in the inventory ledger
+const (
+	MovementReservation    = "reservation"
+	MovementRelease        = "release"
+	MovementRestock        = "restock"
+	MovementAdjustment     = "adjustment" // manual correction, e.g. after a stock count
+	MovementReturn         = "return"
+	MovementTransfer       = "transfer"
+	MovementOpeningBalance = "opening_balance" // stock held when the ledger started
+)
+
+// SystemActor is recorded on movements nobody signed in caused, e.g. expired reservations or order events.
+const SystemActor = "system"
+
+// StockOperationAdjustment records applied adjustments with a reference in processed_events.
+const StockOperationAdjustment = "adjustment"
+
+// Inventory events, published when a stock change crosses a threshold of a product or variant
+const (
+	InventoryEventLowStock    = "inventory.low_stock"    // stock fell to or below the reorder point
+	InventoryEventOutOfStock  = "inventory.out_of_stock" // stock ran out
+	InventoryEventBackInStock = "inventory.back_in_stock"
+)
+
+var (
+	ErrInvalidAdjustment = errors.New("invalid stock adjustment")
+)
+
+// InventoryMovement is one entry of the append-only inventory ledger. Quantity is signed: stock coming in
+// is positive, stock going out negative.
+type InventoryMovement struct {
+	ID          int64     `json:"id"`
+	ProductID   int       `json:"product_id"`
+	SKU         string    `json:"sku,omitempty"`
+	WarehouseID int       `json:"warehouse_id"`
+	Quantity    int       `json:"quantity"`
+	Reason      string    `json:"reason"`
+	Reference   string    `json:"reference,omitempty"`
+	OrderID     int       `json:"order_id,omitempty"`
+	Actor       string    `json:"actor"`
+	Note        string    `json:"note,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// MovementSource describes what caused a stock change, for its ledger entries. Without a Reason the
+// entries are reservations or releases by the sign of the change; the Reference of a change wins over this one.
+type MovementSource struct {
+	Reason    string
+	Reference string
+	OrderID   int
+	Actor     string
+	Note      string
+}
+
+// MovementFilter selects a page of the ledger, newest first.
+type MovementFilter struct {
+	ProductID   int
+	SKU         string
+	WarehouseID int
+	Reason      string
+	Limit       int
+	Offset      int
+}
+
+// StockAdjustment is a manual stock change posted by an admin. Quantity is signed; Reference, when set,
+// makes the adjustment idempotent.
+type StockAdjustment struct {
+	ProductID   int    `json:"product_id"`
+	SKU         string `json:"sku,omitempty"`
+	WarehouseID int    `json:"warehouse_id,omitempty"`
+	Quantity    int    `json:"quantity"`
+	Reason      string `json:"reason"`
+	Reference   string `json:"reference,omitempty"`
+	OrderID     int    `json:"order_id,omitempty"`
+	Note        string `json:"note,omitempty"`
+}
+
+// Validate checks the adjustment changes stock for one of the reasons an admin may post.
+func (a StockAdjustment) Validate() error {
+	switch a.Reason {
+	case MovementRestock, MovementAdjustment, MovementReturn:
+	default:
+		return fmt.Errorf("%w: reason must be %s, %s or %s", ErrInvalidAdjustment, MovementRestock, MovementAdjustment, MovementReturn)
+	}
+	if a.Quantity == 0 {
+		return fmt.Errorf("%w: quantity must not be zero", ErrInvalidAdjustment)
+	}
+	if a.Quantity < 0 && a.Reason != MovementAdjustment {
+		return fmt.Errorf("%w: only an adjustment may remove stock", ErrInvalidAdjustment)
+	}
+	if len(a.Note) > 255 {
+		return fmt.Errorf("%w: note must be at most 255 characters", ErrInvalidAdjustment)
+	}
+	return nil
+}
+
+// StockDiscrepancy is stock that does not match the sum of its ledger entries. Without a warehouse it
+// compares the stock column of the product or variant.
+type StockDiscrepancy struct {
+	ProductID   int    `json:"product_id"`
+	SKU         string `json:"sku,omitempty"`
+	WarehouseID int    `json:"warehouse_id,omitempty"`
+	Stock       int    `json:"stock"`
+	LedgerStock int    `json:"ledger_stock"`
+}
+
+// InventoryEvent is published on the inventory topic so other services and notifiers can react to stock levels.
+type InventoryEvent struct {
+	ID           int64     `json:"-"`
+	EventID      string    `json:"event_id"`
+	Type         string    `json:"type"`
+	OccurredAt   time.Time `json:"occurred_at"`
+	ProductID    int       `json:"product_id"`
+	SKU          string    `json:"sku,omitempty"`
+	Stock        int       `json:"stock"`
+	ReorderPoint int       `json:"reorder_point"`
+}
+
+// StockLevelEvents returns the inventory events a change of stock from before to after triggers.
+// Running out is reported as out of stock rather than low; coming back above zero as back in stock.
+func StockLevelEvents(before, after, reorderPoint int) (events []string) {
+	switch {
+	case before > 0 && after <= 0:
+		events = append(events, InventoryEventOutOfStock)
+	case before <= 0 && after > 0:
+		events = append(events, InventoryEventBackInStock)
+		if after <= reorderPoint {
+			events = append(events, InventoryEventLowStock)
+		}
+	case before > reorderPoint && after <= reorderPoint && after > 0:
+		events = append(events, InventoryEventLowStock)
+	}
+	return events
+}
@@ -12,9 +12,11 @@ type Order struct {
 }
 
 type ProductRequest struct {
-	ProductID  int     `json:"product_id"`
-	Quantity   int     `json:"quantity"`
-	MarkUp     float64 `json:"mark_up"`
-	Discount   float64 `json:"discount"`
-	FinalPrice float64 `json:"final_price"`
+	ProductID     int     `json:"product_id"`
+	SKU           string  `json:"sku,omitempty"`
+	Quantity      int     `json:"quantity"`
+	MarkUp        float64 `json:"mark_up"`
+	Discount      float64 `json:"discount"`
+	FinalPrice    float64 `json:"final_price"`
+	ReservationID int64   `json:"reservation_id,omitempty"` // stock reservation holding the item, 0 for orders reserved before reservations
 }
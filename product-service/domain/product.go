@@ -1,9 +1,109 @@
 package domain
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MaxProductNameLength        = 255
+	MaxProductDescriptionLength = 65535
+	MaxAttributes               = 50
+	MaxAttributeKeyLength       = 64
+	MaxAttributeValueLength     = 255
+)
+
+// Sortable product fields for listing the catalog
+const (
+	ProductSortID    = "id"
+	ProductSortName  = "name"
+	ProductSortPrice = "price"
+	ProductSortStock = "stock"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrInvalidProduct  = errors.New("invalid product")
+)
+
 type Product struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
+	ID           int               `json:"id"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	Price        float64           `json:"price"`
+	Stock        int               `json:"stock"`         // products sold in variants keep their stock on the variants
+	ReorderPoint int               `json:"reorder_point"` // stock at or below it is low, for the product and each variant; 0 for no alerts
+	CategoryID   *int              `json:"category_id,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"` // free-form, e.g. brand or material
+	Variants     []Variant         `json:"variants,omitempty"`
+	Warehouses   []WarehouseStock  `json:"warehouses,omitempty"` // stock per warehouse, only on single product reads
+}
+
+// Validate checks the fields an admin may set on a product. Errors match ErrInvalidProduct.
+func (p Product) Validate() error {
+	name := strings.TrimSpace(p.Name)
+	if name == "" || utf8.RuneCountInString(name) > MaxProductNameLength {
+		return fmt.Errorf("%w: name must be between 1 and %d characters", ErrInvalidProduct, MaxProductNameLength)
+	}
+	if len(p.Description) > MaxProductDescriptionLength {
+		return fmt.Errorf("%w: description must be at most %d bytes", ErrInvalidProduct, MaxProductDescriptionLength)
+	}
+	if p.Price < 0 {
+		return fmt.Errorf("%w: price must not be negative", ErrInvalidProduct)
+	}
+	if p.Stock < 0 {
+		return fmt.Errorf("%w: stock must not be negative", ErrInvalidProduct)
+	}
+	if p.ReorderPoint < 0 {
+		return fmt.Errorf("%w: reorder point must not be negative", ErrInvalidProduct)
+	}
+	if err := ValidateAttributes(p.Attributes); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidProduct, err)
+	}
+	return nil
+}
+
+// ValidateAttributes checks the number and size of free-form attributes.
+func ValidateAttributes(attributes map[string]string) error {
+	if len(attributes) > MaxAttributes {
+		return fmt.Errorf("at most %d attributes are allowed", MaxAttributes)
+	}
+	for key, value := range attributes {
+		if key == "" || utf8.RuneCountInString(key) > MaxAttributeKeyLength {
+			return fmt.Errorf("attribute names must be between 1 and %d characters", MaxAttributeKeyLength)
+		}
+		if utf8.RuneCountInString(value) > MaxAttributeValueLength {
+			return fmt.Errorf("attribute %q must be at most %d characters", key, MaxAttributeValueLength)
+		}
+	}
+	return nil
+}
+
+// ProductFilter selects a page of the catalog. Sort is one of the ProductSort fields.
+// CategoryIDs limits the page to products in any of the categories.
+type ProductFilter struct {
+	CategoryIDs []int
+	Sort        string
+	Desc        bool
+	Limit       int
+	Offset      int
+}
+
+// ProductPage is a page of the catalog with the total number of products.
+type ProductPage struct {
+	Products []Product `json:"products"`
+	Total    int       `json:"total"`
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+}
+
+// IsProductSort reports whether the catalog can be sorted by field.
+func IsProductSort(field string) bool {
+	switch field {
+	case ProductSortID, ProductSortName, ProductSortPrice, ProductSortStock:
+		return true
+	}
+	return false
 }
@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ProductSortRelevance sorts search results by how well they match the keywords
+const ProductSortRelevance = "relevance"
+
+var (
+	ErrInvalidProductSearch = errors.New("invalid product search")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+)
+
+// ProductSearch selects products matching keywords and filters. Empty fields do not filter.
+type ProductSearch struct {
+	Query    string
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  bool
+	Sort     string
+	Desc     bool
+	Cursor   *ProductCursor
+	Limit    int
+}
+
+// ProductCursor points at the last product of a page by its sort value and ID. It is only valid
+// for the sort it was issued for.
+type ProductCursor struct {
+	Sort  string      `json:"sort"`
+	Desc  bool        `json:"desc"`
+	Value interface{} `json:"value"`
+	ID    int         `json:"id"`
+}
+
+// ProductSearchPage is one page of search results with the cursor of the next page, empty on the last page.
+type ProductSearchPage struct {
+	Products   []Product `json:"products"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// Validate checks the search is consistent, e.g. that the cursor belongs to the requested sort.
+func (s ProductSearch) Validate() error {
+	if s.Sort == ProductSortRelevance && s.Query == "" {
+		return fmt.Errorf("%w: sorting by relevance needs a query", ErrInvalidProductSearch)
+	}
+	if s.Sort != ProductSortRelevance && !IsProductSort(s.Sort) {
+		return fmt.Errorf("%w: unknown sort", ErrInvalidProductSearch)
+	}
+	if (s.MinPrice != nil && *s.MinPrice < 0) || (s.MaxPrice != nil && *s.MaxPrice < 0) {
+		return fmt.Errorf("%w: prices must not be negative", ErrInvalidProductSearch)
+	}
+	if s.MinPrice != nil && s.MaxPrice != nil && *s.MinPrice > *s.MaxPrice {
+		return fmt.Errorf("%w: min_price must not exceed max_price", ErrInvalidProductSearch)
+	}
+	if s.Cursor != nil && (s.Cursor.Sort != s.Sort || s.Cursor.Desc != s.Desc) {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c ProductCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeProductCursor parses a cursor produced by ProductCursor.Encode.
+func DecodeProductCursor(value string) (cursor ProductCursor, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	if err = json.Unmarshal(raw, &cursor); err != nil || cursor.ID == 0 || cursor.Value == nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	switch cursor.Value.(type) {
+	case float64, string:
+	default:
+		return cursor, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
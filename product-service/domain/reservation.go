@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Stock reservation states. Held reservations keep stock until they are confirmed, released or expire.
+const (
+	ReservationStateHeld      = "held"
+	ReservationStateConfirmed = "confirmed"
+	ReservationStateReleased  = "released"
+	ReservationStateExpired   = "expired"
+)
+
+// ReservationEventExpired is published when the sweeper gives the stock of an expired reservation back.
+const ReservationEventExpired = "reservation.expired"
+
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationConflict = errors.New("reservation is not in a state that allows this change")
+)
+
+// StockReservation holds stock of a product for an order until it is confirmed or released, or until ExpiresAt.
+type StockReservation struct {
+	ID        int64     `json:"id"`
+	OrderID   int       `json:"order_id,omitempty"`
+	Reference string    `json:"reference,omitempty"` // idempotency key of the reserve call
+	ProductID int       `json:"product_id"`
+	SKU       string    `json:"sku,omitempty"`
+	Quantity  int       `json:"quantity"`
+	State     string    `json:"state"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReservationEvent is published on the reservation topic, e.g. so order-service can cancel the order of an expired reservation.
+type ReservationEvent struct {
+	EventID       string    `json:"event_id"`
+	Type          string    `json:"type"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	ReservationID int64     `json:"reservation_id"`
+	OrderID       int       `json:"order_id,omitempty"`
+	Reference     string    `json:"reference,omitempty"`
+	ProductID     int       `json:"product_id"`
+	SKU           string    `json:"sku,omitempty"`
+	Quantity      int       `json:"quantity"`
+}
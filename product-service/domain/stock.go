@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInsufficientStock = errors.New("product out of stock")
+	ErrInvalidQuantity   = errors.New("quantity must be positive")
+	ErrInvalidStockBatch = fmt.Errorf("a stock batch must have between 1 and %d items", MaxStockBatchItems)
+)
+
+// MaxStockBatchItems caps the number of items reserved or released in one batch
+const MaxStockBatchItems = 100
+
+// Stock operations recorded in the processed_events ledger
+const (
+	StockOperationReserve          = "reserve"
+	StockOperationRelease          = "release"
+	StockOperationReserveCancelled = "reserve_cancelled" // release arrived before its reservation
+)
+
+// StockChange is a signed stock adjustment for a product; negative deltas reserve stock, positive ones release it.
+// With a SKU the stock of that variant of the product changes instead.
+type StockChange struct {
+	ProductID   int    `json:"product_id"`
+	SKU         string `json:"sku,omitempty"`
+	Delta       int    `json:"delta"`
+	WarehouseID int    `json:"warehouse_id,omitempty"` // set to use one warehouse; releases without one go to the first warehouse by priority
+	Reference   string `json:"reference,omitempty"`    // recorded on the ledger entries of the change
+}
+
+// StockItem is one line of a batch reserve or release. Reference makes the line idempotent; on a release,
+// ReserveReference limits it to stock actually held by that reservation.
+type StockItem struct {
+	ProductID        int    `json:"product_id"`
+	SKU              string `json:"sku,omitempty"`
+	Quantity         int    `json:"quantity"`
+	Reference        string `json:"reference,omitempty"`
+	ReserveReference string `json:"reserve_reference,omitempty"`
+}
+
+// StockLevel is the total stock of a product, with its category, as returned by a batch stock lookup.
+type StockLevel struct {
+	ProductID  int `json:"product_id"`
+	CategoryID int `json:"category_id,omitempty"`
+	Stock      int `json:"stock"`
+}
+
+// StockShortfall describes a product that does not have enough stock for a request.
+type StockShortfall struct {
+	ProductID int    `json:"product_id"`
+	SKU       string `json:"sku,omitempty"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// InsufficientStockError lists every product that is short. It matches ErrInsufficientStock with errors.Is.
+type InsufficientStockError struct {
+	Shortfalls []StockShortfall
+}
+
+func (e *InsufficientStockError) Error() string {
+	products := make([]string, len(e.Shortfalls))
+	for i, shortfall := range e.Shortfalls {
+		product := fmt.Sprintf("product %d", shortfall.ProductID)
+		if shortfall.SKU != "" {
+			product += fmt.Sprintf(" sku %s", shortfall.SKU)
+		}
+		products[i] = fmt.Sprintf("%s (requested %d, available %d)", product, shortfall.Requested, shortfall.Available)
+	}
+	return fmt.Sprintf("%s: %s", ErrInsufficientStock, strings.Join(products, ", "))
+}
+
+func (e *InsufficientStockError) Unwrap() error { return ErrInsufficientStock }
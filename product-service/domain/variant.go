@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrInvalidVariant  = errors.New("invalid variant")
+)
+
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Variant is a sellable version of a product, e.g. a size and colour, identified by its SKU.
+// Variants keep their own stock; products sold in variants keep no stock of their own.
+type Variant struct {
+	ID         int               `json:"id"`
+	ProductID  int               `json:"product_id"`
+	SKU        string            `json:"sku"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Price      *float64          `json:"price,omitempty"` // overrides the product price when set
+	Stock      int               `json:"stock"`
+}
+
+// Validate checks the fields an admin may set on a variant.
+func (v Variant) Validate() error {
+	if !skuPattern.MatchString(v.SKU) {
+		return fmt.Errorf("%w: sku must be 1 to 64 letters, digits, dots, dashes or underscores", ErrInvalidVariant)
+	}
+	if v.Price != nil && *v.Price < 0 {
+		return fmt.Errorf("%w: price must not be negative", ErrInvalidVariant)
+	}
+	if v.Stock < 0 {
+		return fmt.Errorf("%w: stock must not be negative", ErrInvalidVariant)
+	}
+	if err := ValidateAttributes(v.Attributes); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidVariant, err)
+	}
+	return nil
+}
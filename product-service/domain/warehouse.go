@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Allocation strategies for taking reserved stock from warehouses
+const (
+	AllocationSplit    = "split"    // take from as many warehouses as needed, in priority order
+	AllocationPriority = "priority" // ship everything from the first warehouse by priority that has it all
+	AllocationNearest  = "nearest"  // ship everything from the nearest warehouse that has it all
+)
+
+var (
+	ErrWarehouseNotFound = errors.New("warehouse not found")
+	ErrInvalidWarehouse  = errors.New("invalid warehouse")
+	ErrNoWarehouse       = errors.New("no active warehouse to hold stock")
+	ErrInvalidAllocation = errors.New("invalid allocation")
+	ErrInvalidTransfer   = errors.New("invalid stock transfer")
+)
+
+var warehouseCodePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{0,31}$`)
+
+// Warehouse is a location stock is kept and shipped from. Warehouses with a lower priority value
+// are used first; inactive warehouses keep their stock but are not reserved from.
+type Warehouse struct {
+	ID        int      `json:"id"`
+	Code      string   `json:"code"`
+	Name      string   `json:"name"`
+	Priority  int      `json:"priority"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Active    bool     `json:"active"`
+}
+
+// Validate checks the fields an admin may set on a warehouse.
+func (w Warehouse) Validate() error {
+	if !warehouseCodePattern.MatchString(w.Code) {
+		return fmt.Errorf("%w: code must be 1 to 32 upper-case letters, digits, dashes or underscores", ErrInvalidWarehouse)
+	}
+	if strings.TrimSpace(w.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidWarehouse)
+	}
+	if (w.Latitude == nil) != (w.Longitude == nil) {
+		return fmt.Errorf("%w: latitude and longitude must be set together", ErrInvalidWarehouse)
+	}
+	if w.Latitude != nil && (math.Abs(*w.Latitude) > 90 || math.Abs(*w.Longitude) > 180) {
+		return fmt.Errorf("%w: coordinates out of range", ErrInvalidWarehouse)
+	}
+	return nil
+}
+
+// WarehouseStock is the stock of a product, or of one of its variants, in one warehouse.
+type WarehouseStock struct {
+	WarehouseID   int    `json:"warehouse_id"`
+	WarehouseCode string `json:"warehouse_code"`
+	SKU           string `json:"sku,omitempty"`
+	Stock         int    `json:"stock"`
+}
+
+// ProductStock is the stock of a product, or of one of its variants, in total and per warehouse.
+type ProductStock struct {
+	ProductID  int              `json:"product_id"`
+	SKU        string           `json:"sku,omitempty"`
+	Stock      int              `json:"stock"`
+	Warehouses []WarehouseStock `json:"warehouses"`
+}
+
+// AllocationPolicy chooses the warehouses reserved stock is taken from. A WarehouseID takes
+// everything from that warehouse; otherwise Strategy applies, split when empty.
+type AllocationPolicy struct {
+	Strategy    string   `json:"strategy,omitempty"`
+	WarehouseID int      `json:"warehouse_id,omitempty"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+}
+
+// Validate checks the strategy is known and that nearest comes with a location.
+func (p AllocationPolicy) Validate() error {
+	switch p.Strategy {
+	case "", AllocationSplit, AllocationPriority:
+	case AllocationNearest:
+		if p.Latitude == nil || p.Longitude == nil {
+			return fmt.Errorf("%w: nearest needs a latitude and longitude", ErrInvalidAllocation)
+		}
+	default:
+		return fmt.Errorf("%w: unknown strategy %q", ErrInvalidAllocation, p.Strategy)
+	}
+	return nil
+}
+
+// Single reports whether the policy ships every item from one warehouse.
+func (p AllocationPolicy) Single() bool {
+	return p.WarehouseID != 0 || p.Strategy == AllocationPriority || p.Strategy == AllocationNearest
+}
+
+// Candidates orders the warehouses stock may be taken from under the policy: the chosen warehouse,
+// the active ones by distance for nearest, or the active ones by priority otherwise.
+func (p AllocationPolicy) Candidates(warehouses []Warehouse) []Warehouse {
+	var candidates []Warehouse
+	for _, warehouse := range warehouses {
+		if p.WarehouseID != 0 {
+			if warehouse.ID == p.WarehouseID {
+				return []Warehouse{warehouse}
+			}
+			continue
+		}
+		if warehouse.Active && (p.Strategy != AllocationNearest || warehouse.Latitude != nil) {
+			candidates = append(candidates, warehouse)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if p.Strategy == AllocationNearest {
+			di := distanceKm(*p.Latitude, *p.Longitude, *candidates[i].Latitude, *candidates[i].Longitude)
+			dj := distanceKm(*p.Latitude, *p.Longitude, *candidates[j].Latitude, *candidates[j].Longitude)
+			if di != dj {
+				return di < dj
+			}
+		}
+		if candidates[i].Priority != candidates[j].Priority {
+			return candidates[i].Priority < candidates[j].Priority
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	return candidates
+}
+
+// StockAllocation is stock of a product or variant taken from one warehouse for a reservation.
+type StockAllocation struct {
+	WarehouseID int    `json:"warehouse_id"`
+	ProductID   int    `json:"product_id"`
+	SKU         string `json:"sku,omitempty"`
+	Quantity    int    `json:"quantity"`
+}
+
+// StockTransfer moves stock of a product or variant between two warehouses. Totals do not change.
+type StockTransfer struct {
+	FromWarehouseID int    `json:"from_warehouse_id"`
+	ToWarehouseID   int    `json:"to_warehouse_id"`
+	ProductID       int    `json:"product_id"`
+	SKU             string `json:"sku,omitempty"`
+	Quantity        int    `json:"quantity"`
+	Note            string `json:"note,omitempty"`
+}
+
+// Validate checks the transfer moves a positive quantity between two different warehouses.
+func (t StockTransfer) Validate() error {
+	if t.FromWarehouseID == 0 || t.ToWarehouseID == 0 || t.FromWarehouseID == t.ToWarehouseID {
+		return fmt.Errorf("%w: from and to must be two different warehouses", ErrInvalidTransfer)
+	}
+	if t.Quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+	if len(t.Note) > 255 {
+		return fmt.Errorf("%w: note must be at most 255 characters", ErrInvalidTransfer)
+	}
+	return nil
+}
+
+// distanceKm is the great-circle distance between two coordinates.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -2,4 +2,10 @@ module product-service
 
 go 1.23.4
 
-require github.com/gorilla/mux v1.8.1
+require (
+	github.com/gorilla/mux v1.8.1
+	golang.org/x/sync v0.10.0
+	shared v0.0.0
+)
+
+replace shared => ../shared
@@ -3,82 +3,222 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"product-service/config"
 	"product-service/domain"
 	"product-service/internal/usecase"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/segmentio/kafka-go"
 )
 
 type Consumer struct {
-	productUsecase usecase.ProductUsecase
+	productUsecase     usecase.ProductUsecase
+	reservationUsecase usecase.ReservationUsecase
+	dlqWriter          *kafka.Writer
+	cfg                config.KafkaConfig
 }
 
-func NewConsumer(productUsecase usecase.ProductUsecase) *Consumer {
-	return &Consumer{productUsecase: productUsecase}
+func NewConsumer(productUsecase usecase.ProductUsecase, reservationUsecase usecase.ReservationUsecase, dlqWriter *kafka.Writer, cfg config.KafkaConfig) *Consumer {
+	return &Consumer{
+		productUsecase:     productUsecase,
+		reservationUsecase: reservationUsecase,
+		dlqWriter:          dlqWriter,
+		cfg:                cfg,
+	}
 }
 
+const (
+	OrderTopic           = "order-topic"
+	OrderDeadLetterTopic = "order-topic.dlq"
+)
+
+// permanentError marks failures that retrying cannot fix, such as malformed events.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
 // StartKafkaConsumer starts a Kafka consumer to listen for order events
 func (c *Consumer) StartKafkaConsumer() {
 	// Create Kafka reader for order topic
 	orderReader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  []string{"localhost:9092"},
-		Topic:    "order-topic",
+		Brokers:  []string{fmt.Sprintf("%s:%s", c.cfg.Host, c.cfg.Port)},
+		Topic:    OrderTopic,
 		GroupID:  "product-service-group",
 		MinBytes: 10e3, // 10KB
 		MaxBytes: 10e6, // 10MB
 	})
 
 	for {
-		// Read message from order topic
+		// Fetch message from order topic; offsets are committed manually once it is handled
 		ctx := context.Background()
-		msg, err := orderReader.ReadMessage(ctx)
+		msg, err := orderReader.FetchMessage(ctx)
 		if err != nil {
 			log.Error().Msgf("Error reading message: %v", err)
 			continue
 		}
 
-		// Process message
-		c.processMessage(ctx, msg)
+		c.handleMessage(ctx, msg)
+
+		if err := orderReader.CommitMessages(ctx, msg); err != nil {
+			log.Error().Msgf("Error committing message at offset %d: %v", msg.Offset, err)
+		}
 	}
 }
 
-// processMessage processes the message received from the Kafka topic
-func (c *Consumer) processMessage(ctx context.Context, msg kafka.Message) {
-	// Unmarshal the message payload
-	var orderEvent domain.Order
+// handleMessage processes a message with exponential backoff between attempts. Once the retries
+// are exhausted, or the failure is permanent, the message is published to the dead-letter topic.
+func (c *Consumer) handleMessage(ctx context.Context, msg kafka.Message) {
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		err = c.processMessage(ctx, msg)
+		if err == nil {
+			return
+		}
 
-	err := json.Unmarshal(msg.Value, &orderEvent)
-	if err != nil {
-		log.Error().Msgf("Error unmarshalling message: %v", err)
-		return
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempts > c.cfg.MaxRetries {
+			break
+		}
+
+		delay := backoff(c.cfg.InitialBackoff, c.cfg.MaxBackoff, attempts)
+		log.Warn().Err(err).Msgf("Error processing message at offset %d, retrying in %s", msg.Offset, delay)
+		time.Sleep(delay)
 	}
 
-	// key -> "order.created.orderID" or "order.cancelled.orderID"
-	key := string(msg.Key)
-	listKey := strings.Split(key, ".")
-	eventType := listKey[1]
-
-	// Process the order event based on the status
-	switch eventType {
-	case "created":
-		// Process order created event
-		for _, item := range orderEvent.ProductRequests {
-			err := c.productUsecase.ReserveProductStock(ctx, item.ProductID, item.Quantity)
-			if err != nil {
-				log.Error().Msgf("Error updating stock for product %d: %v", item.ProductID, err)
-			}
+	log.Error().Err(err).Msgf("Sending message at offset %d to %s after %d attempts", msg.Offset, OrderDeadLetterTopic, attempts)
+
+	// Keep trying until the dead-letter topic accepts the message so it is never lost
+	for dlqAttempts := 1; ; dlqAttempts++ {
+		dlqErr := c.publishDeadLetter(ctx, msg, err, attempts)
+		if dlqErr == nil {
+			return
 		}
-	case "cancelled":
+
+		delay := backoff(c.cfg.InitialBackoff, c.cfg.MaxBackoff, dlqAttempts)
+		log.Error().Err(dlqErr).Msgf("Error publishing message at offset %d to %s, retrying in %s", msg.Offset, OrderDeadLetterTopic, delay)
+		time.Sleep(delay)
+	}
+}
+
+// publishDeadLetter publishes the original message with its headers plus the failure details.
+func (c *Consumer) publishDeadLetter(ctx context.Context, msg kafka.Message, cause error, attempts int) (err error) {
+	headers := make([]kafka.Header, 0, len(msg.Headers)+6)
+	headers = append(headers, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: domain.DeadLetterHeaderError, Value: []byte(cause.Error())},
+		kafka.Header{Key: domain.DeadLetterHeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: domain.DeadLetterHeaderOriginalTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: domain.DeadLetterHeaderOriginalPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: domain.DeadLetterHeaderOriginalOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: domain.DeadLetterHeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	return c.dlqWriter.WriteMessages(ctx, kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+}
+
+// backoff doubles the initial delay for every attempt, capped at max.
+func backoff(initial, max time.Duration, attempt int) time.Duration {
+	delay := initial
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
+
+// processMessage applies the stock changes of an order event. Stock changes are recorded
+// by event ID, so redelivered events are skipped instead of being applied twice.
+func (c *Consumer) processMessage(ctx context.Context, msg kafka.Message) (err error) {
+	orderEvent, err := decodeOrderEvent(msg)
+	if err != nil {
+		return permanentError{err}
+	}
+
+	order, err := orderEvent.Order()
+	if err != nil {
+		return permanentError{fmt.Errorf("error unmarshalling order payload of event %s: %w", orderEvent.EventID, err)}
+	}
+
+	// Process the order event based on its type
+	switch orderEvent.Type {
+	case domain.OrderEventCreated, domain.OrderEventUpdated:
+		// Stock for new orders is reserved by the order saga before the order is created
+	case domain.OrderEventCancelled:
 		// Process order cancelled event
-		for _, item := range orderEvent.ProductRequests {
-			err := c.productUsecase.ReleaseProductStock(ctx, item.ProductID, item.Quantity)
-			if err != nil {
-				log.Error().Msgf("Error updating stock for product %d: %v", item.ProductID, err)
+		err = c.releaseOrderStock(ctx, orderEvent.EventID, order)
+	}
+
+	if errors.Is(err, domain.ErrInsufficientStock) || errors.Is(err, domain.ErrVariantNotFound) {
+		return permanentError{err}
+	}
+
+	return err
+}
+
+// releaseOrderStock gives the stock of a cancelled order back. Items held by a stock reservation release it, which
+// does nothing for a reservation already released or expired; items of older orders are released exactly once per event.
+func (c *Consumer) releaseOrderStock(ctx context.Context, eventID string, order domain.Order) (err error) {
+	var unreserved []domain.ProductRequest
+	for _, item := range order.ProductRequests {
+		if item.ReservationID == 0 {
+			unreserved = append(unreserved, item)
+			continue
+		}
+
+		_, err = c.reservationUsecase.ReleaseReservation(ctx, item.ReservationID)
+		switch {
+		case errors.Is(err, domain.ErrReservationConflict):
+			log.Info().Msgf("Reservation %d of order %d already expired, skipping", item.ReservationID, order.ID)
+		case errors.Is(err, domain.ErrReservationNotFound):
+			return permanentError{fmt.Errorf("reservation %d of order %d: %w", item.ReservationID, order.ID, err)}
+		case err != nil:
+			return err
+		}
+	}
+
+	if len(unreserved) == 0 {
+		return nil
+	}
+	_, err = c.productUsecase.ReleaseOrderStock(ctx, eventID, order.ID, unreserved)
+	return err
+}
+
+// decodeOrderEvent unmarshals and validates the event envelope, making sure the
+// type and version headers agree with the body when they are present.
+func decodeOrderEvent(msg kafka.Message) (orderEvent domain.OrderEvent, err error) {
+	err = json.Unmarshal(msg.Value, &orderEvent)
+	if err != nil {
+		return orderEvent, fmt.Errorf("error unmarshalling message: %w", err)
+	}
+
+	for _, header := range msg.Headers {
+		value := string(header.Value)
+		switch header.Key {
+		case domain.EventHeaderType:
+			if value != orderEvent.Type {
+				return orderEvent, fmt.Errorf("event type header %q does not match body %q", value, orderEvent.Type)
+			}
+		case domain.EventHeaderSchemaVersion:
+			if value != strconv.Itoa(orderEvent.SchemaVersion) {
+				return orderEvent, fmt.Errorf("schema version header %q does not match body %d", value, orderEvent.SchemaVersion)
 			}
 		}
-	default:
-		log.Error().Msgf("Unknown order status: %s", orderEvent.Status)
 	}
+
+	return orderEvent, orderEvent.Validate()
 }
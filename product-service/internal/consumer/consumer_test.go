@@ -0,0 +1,147 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"product-service/config"
+	"product-service/domain"
+	repo "product-service/internal/repository/mysql"
+	"product-service/internal/testutil"
+	"product-service/internal/usecase"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// noCache loads every product straight from the repository.
+type noCache struct{}
+
+func (noCache) FetchProduct(ctx context.Context, productID int, load func(ctx context.Context) (domain.Product, error)) (domain.Product, error) {
+	return load(ctx)
+}
+
+func (noCache) SetProduct(ctx context.Context, product domain.Product, expiration time.Duration) error {
+	return nil
+}
+
+func (noCache) DeleteProduct(ctx context.Context, productID int) error {
+	return nil
+}
+
+func TestReplayingOrderEventsLeavesStockUnchanged(t *testing.T) {
+	db := testutil.MySQL(t)
+	ctx := context.Background()
+
+	productRepo := repo.NewProductRepository(db)
+	productUsecase := usecase.NewProductUsecase(productRepo, repo.NewVariantRepository(db), repo.NewCategoryRepository(db), repo.NewWarehouseRepository(db), noCache{})
+	reservationUsecase := usecase.NewReservationUsecase(repo.NewReservationRepository(db), noCache{}, nil, config.ReservationConfig{DefaultTTL: time.Hour, MaxTTL: time.Hour})
+	c := NewConsumer(productUsecase, reservationUsecase, nil, config.KafkaConfig{})
+
+	product, err := productRepo.CreateProduct(ctx, domain.Product{Name: "Replayed product", Description: "Released by cancelled orders", Price: 10, Stock: 10}, "test")
+	if err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+
+	// Order 3 holds its stock with a reservation, like the order saga takes it
+	reservation, err := reservationUsecase.CreateReservation(ctx, domain.StockReservation{Reference: "saga:3:reserve", ProductID: product.ID, Quantity: 5}, 0, domain.AllocationPolicy{})
+	if err != nil {
+		t.Fatalf("creating reservation: %v", err)
+	}
+
+	stream := []kafka.Message{
+		orderEventMessage(t, "event-1", domain.OrderEventCreated, 1, domain.ProductRequest{ProductID: product.ID, Quantity: 3}),
+		orderEventMessage(t, "event-2", domain.OrderEventCancelled, 1, domain.ProductRequest{ProductID: product.ID, Quantity: 3}),
+		orderEventMessage(t, "event-3", domain.OrderEventCreated, 2, domain.ProductRequest{ProductID: product.ID, Quantity: 2}),
+		orderEventMessage(t, "event-4", domain.OrderEventUpdated, 2, domain.ProductRequest{ProductID: product.ID, Quantity: 4}),
+		orderEventMessage(t, "event-5", domain.OrderEventCancelled, 2, domain.ProductRequest{ProductID: product.ID, Quantity: 4}),
+		orderEventMessage(t, "event-6", domain.OrderEventCancelled, 3, domain.ProductRequest{ProductID: product.ID, Quantity: 5, ReservationID: reservation.ID}),
+	}
+
+	replay := func() {
+		for _, msg := range stream {
+			err := c.processMessage(ctx, msg)
+			if err != nil {
+				t.Fatalf("processing message at offset %d: %v", msg.Offset, err)
+			}
+		}
+	}
+
+	replay()
+	stock, movements := stockState(t, db, product.ID)
+	if stock != 17 {
+		t.Fatalf("stock is %d after the first pass, want 17", stock)
+	}
+
+	reservation, err = reservationUsecase.GetReservation(ctx, reservation.ID)
+	if err != nil {
+		t.Fatalf("getting reservation: %v", err)
+	}
+	if reservation.State != domain.ReservationStateReleased {
+		t.Errorf("reservation is %s after cancelling its order, want %s", reservation.State, domain.ReservationStateReleased)
+	}
+
+	replay()
+	replayedStock, replayedMovements := stockState(t, db, product.ID)
+	if replayedStock != stock {
+		t.Errorf("stock is %d after replaying the stream, want %d", replayedStock, stock)
+	}
+	if replayedMovements != movements {
+		t.Errorf("ledger has %d movements after replaying the stream, want %d", replayedMovements, movements)
+	}
+}
+
+// orderEventMessage builds an order event for an order of a single item, as order-service publishes it.
+func orderEventMessage(t *testing.T, eventID, eventType string, orderID int, item domain.ProductRequest) kafka.Message {
+	t.Helper()
+
+	payload, err := json.Marshal(domain.Order{
+		ID:              orderID,
+		ProductRequests: []domain.ProductRequest{item},
+		Quantity:        item.Quantity,
+	})
+	if err != nil {
+		t.Fatalf("marshalling order: %v", err)
+	}
+
+	value, err := json.Marshal(domain.OrderEvent{
+		EventID:       eventID,
+		Type:          eventType,
+		SchemaVersion: domain.SupportedOrderEventSchemaVersion,
+		OccurredAt:    time.Now().UTC(),
+		AggregateID:   orderID,
+		Payload:       payload,
+	})
+	if err != nil {
+		t.Fatalf("marshalling order event: %v", err)
+	}
+
+	return kafka.Message{
+		Topic: OrderTopic,
+		Key:   []byte(strconv.Itoa(orderID)),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: domain.EventHeaderID, Value: []byte(eventID)},
+			{Key: domain.EventHeaderType, Value: []byte(eventType)},
+			{Key: domain.EventHeaderSchemaVersion, Value: []byte(strconv.Itoa(domain.SupportedOrderEventSchemaVersion))},
+		},
+	}
+}
+
+// stockState reads the stock of a product and the number of ledger movements recorded for it.
+func stockState(t *testing.T, db *sql.DB, productID int) (stock, movements int) {
+	t.Helper()
+
+	err := db.QueryRow(`SELECT stock FROM products WHERE id = ?`, productID).Scan(&stock)
+	if err != nil {
+		t.Fatalf("reading product stock: %v", err)
+	}
+	err = db.QueryRow(`SELECT COUNT(*) FROM inventory_movements WHERE product_id = ?`, productID).Scan(&movements)
+	if err != nil {
+		t.Fatalf("reading ledger: %v", err)
+	}
+	return stock, movements
+}
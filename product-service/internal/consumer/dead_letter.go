@@ -0,0 +1,101 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"product-service/config"
+	"product-service/domain"
+	"product-service/internal/usecase"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// DeadLetterConsumer stores messages from the dead-letter topic so admins can list and re-drive them.
+type DeadLetterConsumer struct {
+	deadLetterUsecase usecase.DeadLetterUsecase
+	cfg               config.KafkaConfig
+}
+
+func NewDeadLetterConsumer(deadLetterUsecase usecase.DeadLetterUsecase, cfg config.KafkaConfig) *DeadLetterConsumer {
+	return &DeadLetterConsumer{
+		deadLetterUsecase: deadLetterUsecase,
+		cfg:               cfg,
+	}
+}
+
+// StartKafkaConsumer starts a Kafka consumer to listen for dead-lettered order events
+func (c *DeadLetterConsumer) StartKafkaConsumer() {
+	dlqReader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  []string{fmt.Sprintf("%s:%s", c.cfg.Host, c.cfg.Port)},
+		Topic:    OrderDeadLetterTopic,
+		GroupID:  "product-service-dlq-group",
+		MinBytes: 10e3, // 10KB
+		MaxBytes: 10e6, // 10MB
+	})
+
+	for {
+		ctx := context.Background()
+		msg, err := dlqReader.FetchMessage(ctx)
+		if err != nil {
+			log.Error().Msgf("Error reading dead-letter message: %v", err)
+			continue
+		}
+
+		// Storing is idempotent, so retry until it succeeds before committing the offset
+		for attempt := 1; ; attempt++ {
+			err = c.deadLetterUsecase.RecordDeadLetter(ctx, toDeadLetter(msg))
+			if err == nil {
+				break
+			}
+
+			delay := backoff(c.cfg.InitialBackoff, c.cfg.MaxBackoff, attempt)
+			log.Error().Err(err).Msgf("Error storing dead-letter message at offset %d, retrying in %s", msg.Offset, delay)
+			time.Sleep(delay)
+		}
+
+		if err := dlqReader.CommitMessages(ctx, msg); err != nil {
+			log.Error().Msgf("Error committing dead-letter message at offset %d: %v", msg.Offset, err)
+		}
+	}
+}
+
+// toDeadLetter restores the original message details from the dead-letter headers.
+func toDeadLetter(msg kafka.Message) domain.DeadLetter {
+	deadLetter := domain.DeadLetter{
+		// Fall back to the dead-letter coordinates when the original ones are missing
+		OriginalTopic:     msg.Topic,
+		OriginalPartition: msg.Partition,
+		OriginalOffset:    msg.Offset,
+		Key:               string(msg.Key),
+		Value:             string(msg.Value),
+		Headers:           map[string]string{},
+		FailedAt:          msg.Time.UTC(),
+	}
+
+	for _, header := range msg.Headers {
+		value := string(header.Value)
+		switch header.Key {
+		case domain.DeadLetterHeaderError:
+			deadLetter.Error = value
+		case domain.DeadLetterHeaderAttempts:
+			deadLetter.Attempts, _ = strconv.Atoi(value)
+		case domain.DeadLetterHeaderOriginalTopic:
+			deadLetter.OriginalTopic = value
+		case domain.DeadLetterHeaderOriginalPartition:
+			deadLetter.OriginalPartition, _ = strconv.Atoi(value)
+		case domain.DeadLetterHeaderOriginalOffset:
+			deadLetter.OriginalOffset, _ = strconv.ParseInt(value, 10, 64)
+		case domain.DeadLetterHeaderFailedAt:
+			if failedAt, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				deadLetter.FailedAt = failedAt
+			}
+		default:
+			deadLetter.Headers[header.Key] = value
+		}
+	}
+
+	return deadLetter
+}
@@ -0,0 +1,131 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"product-service/domain"
+	"product-service/internal/usecase"
+	"product-service/pkg/utils"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type CategoryHandler struct {
+	categoryUsecase usecase.CategoryUsecase
+}
+
+func NewCategoryHandler(categoryUsecase usecase.CategoryUsecase) *CategoryHandler {
+	return &CategoryHandler{categoryUsecase: categoryUsecase}
+}
+
+// GetCategories gets the category tree --> /categories
+func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.categoryUsecase.GetCategoryTree(r.Context())
+	if err != nil {
+		respondWithCategoryError(w, err)
+		return
+	}
+
+	if tree == nil {
+		tree = []domain.Category{}
+	}
+	utils.RespondWithJSON(w, http.StatusOK, tree)
+}
+
+// GetCategory gets a category with its subcategories --> /categories/:id
+func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := categoryID(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := h.categoryUsecase.GetCategory(r.Context(), id)
+	if err != nil {
+		respondWithCategoryError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, category)
+}
+
+// CreateCategory adds a category, below parent_id when set --> /categories
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	category := domain.Category{}
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	category.ID = 0
+
+	createdCategory, err := h.categoryUsecase.CreateCategory(r.Context(), category)
+	if err != nil {
+		respondWithCategoryError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, createdCategory)
+}
+
+// UpdateCategory renames or moves a category --> /categories/:id
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := categoryID(w, r)
+	if !ok {
+		return
+	}
+
+	category := domain.Category{}
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	category.ID = id
+
+	updatedCategory, err := h.categoryUsecase.UpdateCategory(r.Context(), category)
+	if err != nil {
+		respondWithCategoryError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, updatedCategory)
+}
+
+// DeleteCategory deletes a category without subcategories or products --> /categories/:id
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := categoryID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.categoryUsecase.DeleteCategory(r.Context(), id)
+	if err != nil {
+		respondWithCategoryError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Category deleted"})
+}
+
+func categoryID(w http.ResponseWriter, r *http.Request) (id int, ok bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondWithCategoryError maps category errors to their HTTP status
+func respondWithCategoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrCategoryNotFound):
+		utils.RespondWithJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidCategory):
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrCategoryInUse):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
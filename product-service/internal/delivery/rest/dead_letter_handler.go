@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"product-service/internal/usecase"
+	"product-service/pkg/utils"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+type DeadLetterHandler struct {
+	deadLetterUsecase usecase.DeadLetterUsecase
+}
+
+func NewDeadLetterHandler(deadLetterUsecase usecase.DeadLetterUsecase) *DeadLetterHandler {
+	return &DeadLetterHandler{deadLetterUsecase: deadLetterUsecase}
+}
+
+// GetDeadLetters lists dead-lettered messages --> /admin/dlq?status=pending&limit=20&offset=0
+func (h *DeadLetterHandler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, offset, err := parsePagination(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	deadLetters, err := h.deadLetterUsecase.GetDeadLetters(r.Context(), query.Get("status"), limit, offset)
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, deadLetters)
+}
+
+// RedriveDeadLetter publishes a dead-lettered message back onto the order topic --> /admin/dlq/:id/redrive
+func (h *DeadLetterHandler) RedriveDeadLetter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	deadLetter, err := h.deadLetterUsecase.RedriveDeadLetter(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			utils.RespondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Dead letter not found"})
+		case errors.Is(err, usecase.ErrDeadLetterAlreadyRedriven):
+			utils.RespondWithJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, deadLetter)
+}
+
+// parsePagination reads limit and offset query values, applying defaults and bounds.
+func parsePagination(limitStr, offsetStr string) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return 0, 0, errors.New("Invalid limit")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+	}
+
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("Invalid offset")
+		}
+	}
+
+	return limit, offset, nil
+}
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"product-service/domain"
+	"product-service/internal/usecase"
+	"product-service/pkg/utils"
+	"strconv"
+)
+
+type InventoryHandler struct {
+	inventoryUsecase usecase.InventoryUsecase
+}
+
+func NewInventoryHandler(inventoryUsecase usecase.InventoryUsecase) *InventoryHandler {
+	return &InventoryHandler{inventoryUsecase: inventoryUsecase}
+}
+
+// AdjustStock posts a manual restock, return or correction --> /admin/inventory/adjustments
+// A repeated reference is acknowledged without changing stock again.
+func (h *InventoryHandler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	adjustment := domain.StockAdjustment{}
+	if err := json.NewDecoder(r.Body).Decode(&adjustment); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	applied, err := h.inventoryUsecase.AdjustStock(r.Context(), adjustment)
+	if err != nil {
+		respondWithInventoryError(w, err)
+		return
+	}
+
+	if !applied {
+		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Adjustment already applied"})
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, map[string]string{"message": "Stock adjusted"})
+}
+
+// GetMovements lists the inventory ledger, newest first --> /admin/inventory/movements
+// Filters: product_id, sku, warehouse_id and reason.
+func (h *InventoryHandler) GetMovements(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, offset, err := parsePagination(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	filter := domain.MovementFilter{SKU: query.Get("sku"), Reason: query.Get("reason"), Limit: limit, Offset: offset}
+	if filter.ProductID, err = optionalID(query.Get("product_id")); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid product_id"})
+		return
+	}
+	if filter.WarehouseID, err = optionalID(query.Get("warehouse_id")); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid warehouse_id"})
+		return
+	}
+
+	movements, total, err := h.inventoryUsecase.GetMovements(r.Context(), filter)
+	if err != nil {
+		respondWithInventoryError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"movements": movements,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// Reconcile checks the stock columns against the ledger now --> /admin/inventory/reconciliation
+func (h *InventoryHandler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	discrepancies, err := h.inventoryUsecase.Reconcile(r.Context())
+	if err != nil {
+		respondWithInventoryError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"consistent":    len(discrepancies) == 0,
+		"discrepancies": discrepancies,
+	})
+}
+
+// optionalID parses an optional positive ID query parameter, 0 when it is empty
+func optionalID(value string) (id int, err error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err = strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid ID")
+	}
+	return id, nil
+}
+
+// respondWithInventoryError maps inventory errors to their HTTP status
+func respondWithInventoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAdjustment):
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		respondWithWarehouseError(w, err)
+	}
+}
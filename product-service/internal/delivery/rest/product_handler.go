@@ -2,10 +2,13 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"product-service/domain"
 	"product-service/internal/usecase"
 	"product-service/pkg/utils"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
@@ -18,63 +21,480 @@ func NewProductHandler(productUsecase usecase.ProductUsecase) *ProductHandler {
 	return &ProductHandler{productUsecase: productUsecase}
 }
 
-// GetProductStock gets the stock of a product --> /products/:id/stock
-func (h *ProductHandler) GetProductStock(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-	id, err := strconv.Atoi(idStr)
+// ListProducts lists the catalog --> /products?category_id=3&sort=price&order=desc&limit=20&offset=0
+// A category includes the products of its subcategories.
+func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, offset, err := parsePagination(query.Get("limit"), query.Get("offset"))
 	if err != nil {
-		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	stock, err := h.productUsecase.GetProductStock(r.Context(), id)
+	filter := domain.ProductFilter{Sort: domain.ProductSortID, Limit: limit, Offset: offset}
+	if categoryID := query.Get("category_id"); categoryID != "" {
+		id, err := strconv.Atoi(categoryID)
+		if err != nil {
+			utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid category_id"})
+			return
+		}
+		filter.CategoryIDs = []int{id}
+	}
+	if sort := query.Get("sort"); sort != "" {
+		if !domain.IsProductSort(sort) {
+			utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid sort"})
+			return
+		}
+		filter.Sort = sort
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid order"})
+		return
+	}
+
+	page, err := h.productUsecase.ListProducts(r.Context(), filter)
 	if err != nil {
 		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, map[string]int{"stock": stock})
+	utils.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// SearchProducts searches the catalog --> /products/search?q=phone&min_price=100&max_price=500&in_stock=true&sort=price&order=asc&cursor=&limit=20
+// Keyword searches are sorted by relevance unless a sort is given.
+func (h *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _, err := parsePagination(query.Get("limit"), "")
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	search := domain.ProductSearch{
+		Query: strings.TrimSpace(query.Get("q")),
+		Sort:  query.Get("sort"),
+		Limit: limit,
+	}
+
+	search.MinPrice, err = parsePrice(query.Get("min_price"))
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid min_price"})
+		return
+	}
+	search.MaxPrice, err = parsePrice(query.Get("max_price"))
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid max_price"})
+		return
+	}
+
+	if inStock := query.Get("in_stock"); inStock != "" {
+		search.InStock, err = strconv.ParseBool(inStock)
+		if err != nil {
+			utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid in_stock"})
+			return
+		}
+	}
+
+	if search.Sort == "" && search.Query != "" {
+		search.Sort = domain.ProductSortRelevance
+	}
+
+	// Relevance defaults to the best match first
+	switch query.Get("order") {
+	case "":
+		search.Desc = search.Sort == domain.ProductSortRelevance
+	case "asc":
+	case "desc":
+		search.Desc = true
+	default:
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid order"})
+		return
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := domain.DecodeProductCursor(value)
+		if err != nil {
+			utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		search.Cursor = &cursor
+	}
+
+	page, err := h.productUsecase.SearchProducts(r.Context(), search)
+	if err != nil {
+		respondWithProductError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, page)
+}
+
+func parsePrice(value string) (price *float64, err error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// GetProduct gets a product --> /products/:id
+func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(w, r)
+	if !ok {
+		return
+	}
+
+	product, err := h.productUsecase.GetProduct(r.Context(), id)
+	if err != nil {
+		respondWithProductError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, product)
+}
+
+// CreateProduct adds a product to the catalog --> /products
+func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	product := domain.Product{}
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	createdProduct, err := h.productUsecase.CreateProduct(r.Context(), product)
+	if err != nil {
+		respondWithProductError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, createdProduct)
+}
+
+// UpdateProduct updates the catalog fields of a product --> /products/:id
+// Stock is only changed by reserving and releasing it.
+func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(w, r)
+	if !ok {
+		return
+	}
+
+	product := domain.Product{}
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	product.ID = id
+
+	updatedProduct, err := h.productUsecase.UpdateProduct(r.Context(), product)
+	if err != nil {
+		respondWithProductError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, updatedProduct)
+}
+
+// DeleteProduct removes a product from the catalog --> /products/:id
+func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.productUsecase.DeleteProduct(r.Context(), id)
+	if err != nil {
+		respondWithProductError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Product deleted"})
 }
 
-// ReserveProductStock reserves stock for a product --> /products/reserve
+// CreateVariant adds a variant to a product --> /products/:id/variants
+func (h *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(w, r)
+	if !ok {
+		return
+	}
+
+	variant := domain.Variant{}
+	if err := json.NewDecoder(r.Body).Decode(&variant); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	variant.ProductID = id
+
+	createdVariant, err := h.productUsecase.CreateVariant(r.Context(), variant)
+	if err != nil {
+		respondWithProductError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, createdVariant)
+}
+
+// UpdateVariant updates the sku, attributes and price of a variant --> /products/:id/variants/:variantID
+func (h *ProductHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(w, r)
+	if !ok {
+		return
+	}
+	variantID, err := strconv.Atoi(mux.Vars(r)["variantID"])
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid variant ID"})
+		return
+	}
+
+	variant := domain.Variant{}
+	if err := json.NewDecoder(r.Body).Decode(&variant); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	variant.ID = variantID
+	variant.ProductID = id
+
+	updatedVariant, err := h.productUsecase.UpdateVariant(r.Context(), variant)
+	if err != nil {
+		respondWithProductError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, updatedVariant)
+}
+
+// DeleteVariant removes a variant --> /products/:id/variants/:variantID
+func (h *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(w, r)
+	if !ok {
+		return
+	}
+	variantID, err := strconv.Atoi(mux.Vars(r)["variantID"])
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid variant ID"})
+		return
+	}
+
+	err = h.productUsecase.DeleteVariant(r.Context(), id, variantID)
+	if err != nil {
+		respondWithProductError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Variant deleted"})
+}
+
+// GetProductStock gets the stock of a product, or of one variant with ?sku= --> /products/:id/stock
+func (h *ProductHandler) GetProductStock(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(w, r)
+	if !ok {
+		return
+	}
+
+	stock, err := h.productUsecase.GetProductStock(r.Context(), id, r.URL.Query().Get("sku"))
+	if err != nil {
+		respondWithProductError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, stock)
+}
+
+// ReserveProductStock reserves stock for a product, or for one of its variants by sku, for services only --> /products/reserve
+// A reference makes the call idempotent: repeating it with the same reference reserves only once.
+// An allocation picks the warehouses the stock is taken from, split across them by priority by default.
 func (h *ProductHandler) ReserveProductStock(w http.ResponseWriter, r *http.Request) {
 	reservation := struct {
-		ProductID int `json:"product_id"`
-		Quantity  int `json:"quantity"`
+		ProductID  int                     `json:"product_id"`
+		SKU        string                  `json:"sku"`
+		Quantity   int                     `json:"quantity"`
+		Reference  string                  `json:"reference"`
+		OrderID    int                     `json:"order_id"`
+		Allocation domain.AllocationPolicy `json:"allocation"`
 	}{}
 	if err := json.NewDecoder(r.Body).Decode(&reservation); err != nil {
 		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
 		return
 	}
-	err := h.productUsecase.ReserveProductStock(r.Context(), reservation.ProductID, reservation.Quantity)
+
+	if reservation.Quantity <= 0 {
+		respondWithStockError(w, domain.ErrInvalidQuantity)
+		return
+	}
+
+	var err error
+	if reservation.Reference != "" {
+		items := []domain.ProductRequest{{ProductID: reservation.ProductID, SKU: reservation.SKU, Quantity: reservation.Quantity}}
+		_, err = h.productUsecase.ReserveOrderStock(r.Context(), reservation.Reference, reservation.OrderID, items, reservation.Allocation)
+	} else {
+		err = h.productUsecase.ReserveProductStock(r.Context(), reservation.ProductID, reservation.SKU, reservation.Quantity, reservation.Allocation)
+	}
 	if err != nil {
-		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondWithStockError(w, err)
 		return
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Stock reserved"})
 }
 
-// ReleaseProductStock releases stock for a product --> /products/release
+// ReleaseProductStock releases stock for a product, for services only --> /products/release
+// With a reserve_reference only stock actually held by that reservation is released, once per reference.
 func (h *ProductHandler) ReleaseProductStock(w http.ResponseWriter, r *http.Request) {
 	release := struct {
-		ProductID int `json:"product_id"`
-		Quantity  int `json:"quantity"`
+		ProductID        int    `json:"product_id"`
+		SKU              string `json:"sku"`
+		Quantity         int    `json:"quantity"`
+		Reference        string `json:"reference"`
+		ReserveReference string `json:"reserve_reference"`
+		OrderID          int    `json:"order_id"`
 	}{}
 	if err := json.NewDecoder(r.Body).Decode(&release); err != nil {
 		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
 		return
 	}
-	err := h.productUsecase.ReleaseProductStock(r.Context(), release.ProductID, release.Quantity)
+
+	if release.ReserveReference != "" && release.Reference == "" {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "reference is required with reserve_reference"})
+		return
+	}
+
+	if release.Quantity <= 0 {
+		respondWithStockError(w, domain.ErrInvalidQuantity)
+		return
+	}
+
+	var err error
+	items := []domain.ProductRequest{{ProductID: release.ProductID, SKU: release.SKU, Quantity: release.Quantity}}
+	switch {
+	case release.ReserveReference != "":
+		_, err = h.productUsecase.ReleaseReservedStock(r.Context(), release.ReserveReference, release.Reference, items)
+	case release.Reference != "":
+		_, err = h.productUsecase.ReleaseOrderStock(r.Context(), release.Reference, release.OrderID, items)
+	default:
+		err = h.productUsecase.ReleaseProductStock(r.Context(), release.ProductID, release.SKU, release.Quantity)
+	}
 	if err != nil {
-		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondWithStockError(w, err)
 		return
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Stock released"})
 }
 
+// ReserveStockBatch reserves stock for all items or none of them --> /products/reserve/batch
+// Each item may carry a reference, with the same meaning as on /products/reserve. The allocation applies to every item.
+func (h *ProductHandler) ReserveStockBatch(w http.ResponseWriter, r *http.Request) {
+	batch := struct {
+		Items      []domain.StockItem      `json:"items"`
+		Allocation domain.AllocationPolicy `json:"allocation"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	err := h.productUsecase.ReserveStockBatch(r.Context(), batch.Items, batch.Allocation)
+	if err != nil {
+		respondWithStockError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Stock reserved"})
+}
+
+// GetStockBatch gets the total stock of many products at once; unknown products are left out --> /products/stock/batch
+func (h *ProductHandler) GetStockBatch(w http.ResponseWriter, r *http.Request) {
+	batch := struct {
+		ProductIDs []int `json:"product_ids"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	levels, err := h.productUsecase.GetStockLevels(r.Context(), batch.ProductIDs)
+	if err != nil {
+		respondWithStockError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"products": levels})
+}
+
+// ReleaseStockBatch releases stock for all items in one transaction --> /products/release/batch
+// Each item may carry a reference and reserve_reference, with the same meaning as on /products/release.
+func (h *ProductHandler) ReleaseStockBatch(w http.ResponseWriter, r *http.Request) {
+	batch := struct {
+		Items []domain.StockItem `json:"items"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	for _, item := range batch.Items {
+		if item.ReserveReference != "" && item.Reference == "" {
+			utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "reference is required with reserve_reference"})
+			return
+		}
+	}
+
+	err := h.productUsecase.ReleaseStockBatch(r.Context(), batch.Items)
+	if err != nil {
+		respondWithStockError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Stock released"})
+}
+
+func productID(w http.ResponseWriter, r *http.Request) (id int, ok bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondWithProductError maps catalog errors to their HTTP status
+func respondWithProductError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrProductNotFound), errors.Is(err, domain.ErrVariantNotFound):
+		utils.RespondWithJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidProduct), errors.Is(err, domain.ErrInvalidVariant), errors.Is(err, domain.ErrInvalidProductSearch), errors.Is(err, domain.ErrInvalidCursor):
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+// respondWithStockError maps stock errors to their HTTP status
+func respondWithStockError(w http.ResponseWriter, err error) {
+	var shortage *domain.InsufficientStockError
+	switch {
+	case errors.As(err, &shortage):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]interface{}{"error": err.Error(), "shortfalls": shortage.Shortfalls})
+	case errors.Is(err, domain.ErrVariantNotFound), errors.Is(err, domain.ErrWarehouseNotFound):
+		utils.RespondWithJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidQuantity), errors.Is(err, domain.ErrInvalidStockBatch), errors.Is(err, domain.ErrInvalidAllocation):
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInsufficientStock):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
 // PreWarmupCache pre-warms the cache with product data --> /products/warmup-cache
 func (h *ProductHandler) PreWarmupCache(w http.ResponseWriter, r *http.Request) {
 	//// call synchronously
@@ -91,5 +511,5 @@ func (h *ProductHandler) PreWarmupCache(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Cache pre-warmed"})
+	utils.RespondWithJSON(w, http.StatusAccepted, map[string]string{"message": "Cache warmup started"})
 }
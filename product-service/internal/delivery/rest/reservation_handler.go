@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"product-service/domain"
+	"product-service/internal/usecase"
+	"product-service/pkg/utils"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type ReservationHandler struct {
+	reservationUsecase usecase.ReservationUsecase
+}
+
+func NewReservationHandler(reservationUsecase usecase.ReservationUsecase) *ReservationHandler {
+	return &ReservationHandler{reservationUsecase: reservationUsecase}
+}
+
+// CreateReservation holds stock until the reservation is confirmed, released or expires --> /products/reservations
+// A reference makes the call idempotent: repeating it returns the existing reservation.
+// An allocation picks the warehouses the stock is taken from, as on /products/reserve.
+func (h *ReservationHandler) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		OrderID    int                     `json:"order_id"`
+		Reference  string                  `json:"reference"`
+		ProductID  int                     `json:"product_id"`
+		SKU        string                  `json:"sku"`
+		Quantity   int                     `json:"quantity"`
+		TTLSeconds int                     `json:"ttl_seconds"`
+		Allocation domain.AllocationPolicy `json:"allocation"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	reservation, err := h.reservationUsecase.CreateReservation(r.Context(), domain.StockReservation{
+		OrderID:   req.OrderID,
+		Reference: req.Reference,
+		ProductID: req.ProductID,
+		SKU:       req.SKU,
+		Quantity:  req.Quantity,
+	}, time.Duration(req.TTLSeconds)*time.Second, req.Allocation)
+	if err != nil {
+		respondWithReservationError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, reservation)
+}
+
+// GetReservation gets a reservation --> /products/reservations/:id
+func (h *ReservationHandler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	id, ok := reservationID(w, r)
+	if !ok {
+		return
+	}
+
+	reservation, err := h.reservationUsecase.GetReservation(r.Context(), id)
+	if err != nil {
+		respondWithReservationError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, reservation)
+}
+
+// ConfirmReservation keeps the reserved stock for good --> /products/reservations/:id/confirm
+func (h *ReservationHandler) ConfirmReservation(w http.ResponseWriter, r *http.Request) {
+	id, ok := reservationID(w, r)
+	if !ok {
+		return
+	}
+
+	reservation, err := h.reservationUsecase.ConfirmReservation(r.Context(), id)
+	if err != nil {
+		respondWithReservationError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, reservation)
+}
+
+// ReleaseReservation gives the reserved stock back --> /products/reservations/:id/release
+func (h *ReservationHandler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	id, ok := reservationID(w, r)
+	if !ok {
+		return
+	}
+
+	reservation, err := h.reservationUsecase.ReleaseReservation(r.Context(), id)
+	if err != nil {
+		respondWithReservationError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, reservation)
+}
+
+func reservationID(w http.ResponseWriter, r *http.Request) (id int64, ok bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondWithReservationError maps reservation errors to their HTTP status
+func respondWithReservationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrReservationNotFound):
+		utils.RespondWithJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrReservationConflict):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecase.ErrInvalidReservationTTL):
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		respondWithStockError(w, err)
+	}
+}
@@ -8,7 +8,7 @@ import (
 )
 
 // RegisterRoutes registers all API routes
-func RegisterRoutes(router *mux.Router, productHandler *ProductHandler) {
+func RegisterRoutes(router *mux.Router, productHandler *ProductHandler, categoryHandler *CategoryHandler, reservationHandler *ReservationHandler, warehouseHandler *WarehouseHandler, inventoryHandler *InventoryHandler, deadLetterHandler *DeadLetterHandler) {
 	// Logger Middleware
 	router.Use(middleware.LoggingMiddleware)
 
@@ -23,21 +23,92 @@ func RegisterRoutes(router *mux.Router, productHandler *ProductHandler) {
 
 	// Register product routes
 	registerProductRoutes(apiRouter, productHandler, jwtMiddleware)
+
+	// Register category routes
+	registerCategoryRoutes(apiRouter, categoryHandler, jwtMiddleware)
+
+	// Register reservation routes
+	registerReservationRoutes(apiRouter, reservationHandler, jwtMiddleware)
+
+	// Register admin routes
+	registerAdminRoutes(apiRouter, warehouseHandler, inventoryHandler, deadLetterHandler, jwtMiddleware)
 }
 
 // registerUserRoutes registers user related routes
 func registerProductRoutes(router *mux.Router, handler *ProductHandler, jwtMiddleware *middleware.JWTMiddleware) {
 	// Public routes
 	productRouter := router.PathPrefix("/products").Subrouter()
+	productRouter.HandleFunc("", handler.ListProducts).Methods("GET")
+	productRouter.HandleFunc("/search", handler.SearchProducts).Methods("GET")
+	productRouter.HandleFunc("/{id:[0-9]+}", handler.GetProduct).Methods("GET")
+
+	// Admin routes
+	admin := productRouter.PathPrefix("").Subrouter()
+	admin.Use(jwtMiddleware.RequireAdmin)
+	admin.HandleFunc("", handler.CreateProduct).Methods("POST")
+	admin.HandleFunc("/{id:[0-9]+}", handler.UpdateProduct).Methods("PUT")
+	admin.HandleFunc("/{id:[0-9]+}", handler.DeleteProduct).Methods("DELETE")
+	admin.HandleFunc("/{id:[0-9]+}/variants", handler.CreateVariant).Methods("POST")
+	admin.HandleFunc("/{id:[0-9]+}/variants/{variantID:[0-9]+}", handler.UpdateVariant).Methods("PUT")
+	admin.HandleFunc("/{id:[0-9]+}/variants/{variantID:[0-9]+}", handler.DeleteVariant).Methods("DELETE")
 
 	// Protected routes
 	protected := productRouter.PathPrefix("").Subrouter()
 	protected.Use(jwtMiddleware.RequireAuth)
 	protected.HandleFunc("/{id:[0-9]+}/stock", handler.GetProductStock).Methods("GET")
-	protected.HandleFunc("/reserve", handler.ReserveProductStock).Methods("POST")
-	protected.HandleFunc("/release", handler.ReleaseProductStock).Methods("POST")
+	protected.HandleFunc("/stock/batch", handler.GetStockBatch).Methods("POST")
 	protected.HandleFunc("/warmup-cache", handler.PreWarmupCache).Methods("GET")
 
+	// Service routes, used by order-service to hold stock for orders
+	service := productRouter.PathPrefix("").Subrouter()
+	service.Use(jwtMiddleware.RequireService)
+	service.HandleFunc("/reserve", handler.ReserveProductStock).Methods("POST")
+	service.HandleFunc("/release", handler.ReleaseProductStock).Methods("POST")
+	service.HandleFunc("/reserve/batch", handler.ReserveStockBatch).Methods("POST")
+	service.HandleFunc("/release/batch", handler.ReleaseStockBatch).Methods("POST")
+}
+
+// registerCategoryRoutes registers category routes; reads are public, writes admin only
+func registerCategoryRoutes(router *mux.Router, handler *CategoryHandler, jwtMiddleware *middleware.JWTMiddleware) {
+	categoryRouter := router.PathPrefix("/categories").Subrouter()
+	categoryRouter.HandleFunc("", handler.GetCategories).Methods("GET")
+	categoryRouter.HandleFunc("/{id:[0-9]+}", handler.GetCategory).Methods("GET")
+
+	admin := categoryRouter.PathPrefix("").Subrouter()
+	admin.Use(jwtMiddleware.RequireAdmin)
+	admin.HandleFunc("", handler.CreateCategory).Methods("POST")
+	admin.HandleFunc("/{id:[0-9]+}", handler.UpdateCategory).Methods("PUT")
+	admin.HandleFunc("/{id:[0-9]+}", handler.DeleteCategory).Methods("DELETE")
+}
+
+// registerReservationRoutes registers stock reservation routes; only services, i.e. the order saga, change reservations
+func registerReservationRoutes(router *mux.Router, handler *ReservationHandler, jwtMiddleware *middleware.JWTMiddleware) {
+	reservationRouter := router.PathPrefix("/products/reservations").Subrouter()
+
+	protected := reservationRouter.PathPrefix("").Subrouter()
+	protected.Use(jwtMiddleware.RequireAuth)
+	protected.HandleFunc("/{id:[0-9]+}", handler.GetReservation).Methods("GET")
+
+	service := reservationRouter.PathPrefix("").Subrouter()
+	service.Use(jwtMiddleware.RequireService)
+	service.HandleFunc("", handler.CreateReservation).Methods("POST")
+	service.HandleFunc("/{id:[0-9]+}/confirm", handler.ConfirmReservation).Methods("POST")
+	service.HandleFunc("/{id:[0-9]+}/release", handler.ReleaseReservation).Methods("POST")
+}
+
+// registerAdminRoutes registers admin only routes
+func registerAdminRoutes(router *mux.Router, warehouseHandler *WarehouseHandler, inventoryHandler *InventoryHandler, deadLetterHandler *DeadLetterHandler, jwtMiddleware *middleware.JWTMiddleware) {
+	adminRouter := router.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(jwtMiddleware.RequireAdmin)
+	adminRouter.HandleFunc("/dlq", deadLetterHandler.GetDeadLetters).Methods("GET")
+	adminRouter.HandleFunc("/dlq/{id:[0-9]+}/redrive", deadLetterHandler.RedriveDeadLetter).Methods("POST")
+	adminRouter.HandleFunc("/warehouses", warehouseHandler.GetWarehouses).Methods("GET")
+	adminRouter.HandleFunc("/warehouses", warehouseHandler.CreateWarehouse).Methods("POST")
+	adminRouter.HandleFunc("/warehouses/{id:[0-9]+}", warehouseHandler.UpdateWarehouse).Methods("PUT")
+	adminRouter.HandleFunc("/warehouses/transfers", warehouseHandler.TransferStock).Methods("POST")
+	adminRouter.HandleFunc("/inventory/adjustments", inventoryHandler.AdjustStock).Methods("POST")
+	adminRouter.HandleFunc("/inventory/movements", inventoryHandler.GetMovements).Methods("GET")
+	adminRouter.HandleFunc("/inventory/reconciliation", inventoryHandler.Reconcile).Methods("GET")
 }
 
 // HealthCheck handler for the health endpoint
@@ -0,0 +1,134 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"product-service/config"
+	"product-service/domain"
+	"product-service/internal/usecase"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+)
+
+const testSecret = "test-secret"
+
+// stubReservations finds no reservation, so a request that gets past the middleware answers 404.
+type stubReservations struct {
+	usecase.ReservationUsecase
+}
+
+func (stubReservations) GetReservation(ctx context.Context, id int64) (domain.StockReservation, error) {
+	return domain.StockReservation{}, domain.ErrReservationNotFound
+}
+
+func (stubReservations) ConfirmReservation(ctx context.Context, id int64) (domain.StockReservation, error) {
+	return domain.StockReservation{}, domain.ErrReservationNotFound
+}
+
+func (stubReservations) ReleaseReservation(ctx context.Context, id int64) (domain.StockReservation, error) {
+	return domain.StockReservation{}, domain.ErrReservationNotFound
+}
+
+func newTestRouter(t *testing.T) *mux.Router {
+	t.Helper()
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{Jwt: config.JwtConfig{Secret: testSecret}}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	router := mux.NewRouter()
+	RegisterRoutes(router, NewProductHandler(nil), nil, NewReservationHandler(stubReservations{}), nil, nil, nil)
+	return router
+}
+
+// testToken signs a token for a caller with role, like user-service and GenerateServiceToken do.
+func testToken(t *testing.T, role string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":  1,
+		"username": "caller",
+		"email":    "caller@example.com",
+		"role":     role,
+		"exp":      time.Now().Add(time.Minute).Unix(),
+	})
+	signed, err := token.SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return signed
+}
+
+func TestServiceRoutesRequireServiceRole(t *testing.T) {
+	router := newTestRouter(t)
+
+	// reached is the status of a request that gets to the handler: a malformed body or an unknown reservation
+	routes := []struct {
+		path    string
+		reached int
+	}{
+		{"/api/products/reserve", http.StatusBadRequest},
+		{"/api/products/release", http.StatusBadRequest},
+		{"/api/products/reserve/batch", http.StatusBadRequest},
+		{"/api/products/release/batch", http.StatusBadRequest},
+		{"/api/products/reservations", http.StatusBadRequest},
+		{"/api/products/reservations/1/confirm", http.StatusNotFound},
+		{"/api/products/reservations/1/release", http.StatusNotFound},
+	}
+	callers := []struct {
+		name string
+		role string // empty for no token
+		want int    // 0 for reaching the handler
+	}{
+		{"anonymous", "", http.StatusUnauthorized},
+		{"customer", "customer", http.StatusForbidden},
+		{"admin", domain.RoleAdmin, http.StatusForbidden},
+		{"service", domain.RoleService, 0},
+	}
+	for _, route := range routes {
+		for _, caller := range callers {
+			t.Run(route.path+" as "+caller.name, func(t *testing.T) {
+				want := caller.want
+				if want == 0 {
+					want = route.reached
+				}
+
+				rec := serve(t, router, "POST", route.path, caller.role)
+
+				if rec.Code != want {
+					t.Errorf("got status %d, want %d", rec.Code, want)
+				}
+			})
+		}
+	}
+}
+
+func TestGetReservationRequiresAuth(t *testing.T) {
+	router := newTestRouter(t)
+
+	if rec := serve(t, router, "GET", "/api/products/reservations/1", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := serve(t, router, "GET", "/api/products/reservations/1", "customer"); rec.Code != http.StatusNotFound {
+		t.Errorf("customer got status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+// serve sends a request with a malformed body as a caller with role, or without a token when role is empty.
+func serve(t *testing.T, router *mux.Router, method, path, role string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader("{"))
+	if role != "" {
+		req.Header.Set("Authorization", "Bearer "+testToken(t, role))
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"product-service/domain"
+	"product-service/internal/usecase"
+	"product-service/pkg/utils"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type WarehouseHandler struct {
+	warehouseUsecase usecase.WarehouseUsecase
+}
+
+func NewWarehouseHandler(warehouseUsecase usecase.WarehouseUsecase) *WarehouseHandler {
+	return &WarehouseHandler{warehouseUsecase: warehouseUsecase}
+}
+
+// GetWarehouses lists every warehouse --> /admin/warehouses
+func (h *WarehouseHandler) GetWarehouses(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := h.warehouseUsecase.GetWarehouses(r.Context())
+	if err != nil {
+		respondWithWarehouseError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, warehouses)
+}
+
+// CreateWarehouse adds a warehouse --> /admin/warehouses
+func (h *WarehouseHandler) CreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	warehouse := domain.Warehouse{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&warehouse); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	warehouse.ID = 0
+
+	createdWarehouse, err := h.warehouseUsecase.CreateWarehouse(r.Context(), warehouse)
+	if err != nil {
+		respondWithWarehouseError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, createdWarehouse)
+}
+
+// UpdateWarehouse replaces the settings of a warehouse --> /admin/warehouses/:id
+func (h *WarehouseHandler) UpdateWarehouse(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	warehouse := domain.Warehouse{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&warehouse); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+	warehouse.ID = id
+
+	updatedWarehouse, err := h.warehouseUsecase.UpdateWarehouse(r.Context(), warehouse)
+	if err != nil {
+		respondWithWarehouseError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, updatedWarehouse)
+}
+
+// TransferStock moves stock of a product or variant between warehouses --> /admin/warehouses/transfers
+func (h *WarehouseHandler) TransferStock(w http.ResponseWriter, r *http.Request) {
+	transfer := domain.StockTransfer{}
+	if err := json.NewDecoder(r.Body).Decode(&transfer); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	err := h.warehouseUsecase.TransferStock(r.Context(), transfer)
+	if err != nil {
+		respondWithWarehouseError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Stock transferred"})
+}
+
+// respondWithWarehouseError maps warehouse errors to their HTTP status
+func respondWithWarehouseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrWarehouseNotFound), errors.Is(err, domain.ErrProductNotFound):
+		utils.RespondWithJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidWarehouse), errors.Is(err, domain.ErrInvalidTransfer):
+		utils.RespondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		respondWithStockError(w, err)
+	}
+}
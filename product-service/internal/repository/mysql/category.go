@@ -0,0 +1,138 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"product-service/domain"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// mysqlErrRowIsReferenced is the MySQL error number for deleting a row a foreign key still points at
+	mysqlErrRowIsReferenced = 1451
+	// mysqlErrNoReferencedRow is the MySQL error number for a foreign key pointing at a missing row
+	mysqlErrNoReferencedRow = 1452
+)
+
+type CategoryRepository interface {
+	GetCategories(ctx context.Context) (categories []domain.Category, err error)
+	GetCategoryByID(ctx context.Context, id int) (category domain.Category, err error)
+	CreateCategory(ctx context.Context, req domain.Category) (category domain.Category, err error)
+	UpdateCategory(ctx context.Context, req domain.Category) (err error)
+	DeleteCategory(ctx context.Context, id int) (err error)
+}
+
+type categoryRepository struct {
+	db *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) CategoryRepository {
+	return &categoryRepository{db}
+}
+
+func (r *categoryRepository) GetCategories(ctx context.Context) (categories []domain.Category, err error) {
+	query := `SELECT id, parent_id, name, slug FROM categories ORDER BY name, id`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+func (r *categoryRepository) GetCategoryByID(ctx context.Context, id int) (category domain.Category, err error) {
+	query := `SELECT id, parent_id, name, slug FROM categories WHERE id = ?`
+	return scanCategory(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *categoryRepository) CreateCategory(ctx context.Context, req domain.Category) (category domain.Category, err error) {
+	query := `INSERT INTO categories (parent_id, name, slug) VALUES (?, ?, ?)`
+	res, err := r.db.ExecContext(ctx, query, nullIntPtr(req.ParentID), req.Name, req.Slug)
+	if err != nil {
+		return category, categoryWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return category, err
+	}
+
+	category = req
+	category.ID = int(id)
+	return category, nil
+}
+
+func (r *categoryRepository) UpdateCategory(ctx context.Context, req domain.Category) (err error) {
+	query := `UPDATE categories SET parent_id = ?, name = ?, slug = ? WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, nullIntPtr(req.ParentID), req.Name, req.Slug, req.ID)
+	return categoryWriteError(err)
+}
+
+// DeleteCategory deletes a category, returning sql.ErrNoRows if it does not exist and ErrCategoryInUse
+// while subcategories or products still reference it.
+func (r *categoryRepository) DeleteCategory(ctx context.Context, id int) (err error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM categories WHERE id = ?`, id)
+	if err != nil {
+		return categoryWriteError(err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// categoryWriteError maps constraint violations of the categories table to domain errors.
+func categoryWriteError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+
+	switch mysqlErr.Number {
+	case mysqlErrDuplicateEntry:
+		return fmt.Errorf("%w: slug is already used by another category", domain.ErrInvalidCategory)
+	case mysqlErrNoReferencedRow:
+		return fmt.Errorf("%w: parent category does not exist", domain.ErrInvalidCategory)
+	case mysqlErrRowIsReferenced:
+		return domain.ErrCategoryInUse
+	}
+	return err
+}
+
+func scanCategory(row interface{ Scan(dest ...any) error }) (category domain.Category, err error) {
+	var parentID sql.NullInt64
+	err = row.Scan(&category.ID, &parentID, &category.Name, &category.Slug)
+	if err != nil {
+		return category, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		category.ParentID = &id
+	}
+	return category, nil
+}
+
+func nullIntPtr(value *int) sql.NullInt64 {
+	if value == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*value), Valid: true}
+}
@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"product-service/domain"
+	"time"
+)
+
+type DeadLetterRepository interface {
+	CreateDeadLetter(ctx context.Context, req domain.DeadLetter) (err error)
+	GetDeadLetterByID(ctx context.Context, id int) (deadLetter domain.DeadLetter, err error)
+	GetDeadLetters(ctx context.Context, status string, limit, offset int) (deadLetters []domain.DeadLetter, err error)
+	MarkDeadLetterRedriven(ctx context.Context, id int) (err error)
+}
+
+type deadLetterRepository struct {
+	db *sql.DB
+}
+
+func NewDeadLetterRepository(db *sql.DB) DeadLetterRepository {
+	return &deadLetterRepository{db}
+}
+
+// CreateDeadLetter stores a dead-lettered message. Messages already stored are ignored, so ingestion can be replayed.
+func (r *deadLetterRepository) CreateDeadLetter(ctx context.Context, req domain.DeadLetter) (err error) {
+	headers, err := json.Marshal(req.Headers)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT IGNORE INTO dead_letters (original_topic, original_partition, original_offset, message_key, message_value, headers, error, attempts, status, failed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = r.db.ExecContext(ctx, query, req.OriginalTopic, req.OriginalPartition, req.OriginalOffset, req.Key, req.Value, headers, req.Error, req.Attempts, domain.DeadLetterStatusPending, req.FailedAt)
+	return err
+}
+
+func (r *deadLetterRepository) GetDeadLetterByID(ctx context.Context, id int) (deadLetter domain.DeadLetter, err error) {
+	query := `SELECT id, original_topic, original_partition, original_offset, message_key, message_value, headers, error, attempts, status, failed_at, redriven_at
+		FROM dead_letters WHERE id = ?`
+	return scanDeadLetter(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *deadLetterRepository) GetDeadLetters(ctx context.Context, status string, limit, offset int) (deadLetters []domain.DeadLetter, err error) {
+	query := `SELECT id, original_topic, original_partition, original_offset, message_key, message_value, headers, error, attempts, status, failed_at, redriven_at
+		FROM dead_letters WHERE (? = '' OR status = ?) ORDER BY id DESC LIMIT ? OFFSET ?`
+	rows, err := r.db.QueryContext(ctx, query, status, status, limit, offset)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		deadLetter, err := scanDeadLetter(rows)
+		if err != nil {
+			return deadLetters, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, rows.Err()
+}
+
+func (r *deadLetterRepository) MarkDeadLetterRedriven(ctx context.Context, id int) (err error) {
+	query := `UPDATE dead_letters SET status = ?, redriven_at = ? WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, domain.DeadLetterStatusRedriven, time.Now().UTC(), id)
+	return err
+}
+
+func scanDeadLetter(row interface{ Scan(dest ...any) error }) (deadLetter domain.DeadLetter, err error) {
+	var key, value, headers []byte
+	var redrivenAt sql.NullTime
+	err = row.Scan(&deadLetter.ID, &deadLetter.OriginalTopic, &deadLetter.OriginalPartition, &deadLetter.OriginalOffset, &key, &value, &headers,
+		&deadLetter.Error, &deadLetter.Attempts, &deadLetter.Status, &deadLetter.FailedAt, &redrivenAt)
+	if err != nil {
+		return
+	}
+
+	deadLetter.Key = string(key)
+	deadLetter.Value = string(value)
+	if redrivenAt.Valid {
+		deadLetter.RedrivenAt = &redrivenAt.Time
+	}
+
+	err = json.Unmarshal(headers, &deadLetter.Headers)
+	return
+}
@@ -0,0 +1,224 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"product-service/domain"
+	"strings"
+	"time"
+)
+
+type InventoryRepository interface {
+	AdjustStock(ctx context.Context, adjustment domain.StockAdjustment, actor string) (applied bool, err error)
+	GetMovements(ctx context.Context, filter domain.MovementFilter) (movements []domain.InventoryMovement, total int, err error)
+	GetDiscrepancies(ctx context.Context) (discrepancies []domain.StockDiscrepancy, err error)
+	GetUnpublishedInventoryEvents(ctx context.Context, limit int) (events []domain.InventoryEvent, err error)
+	MarkInventoryEventPublished(ctx context.Context, id int64, now time.Time) (err error)
+}
+
+type inventoryRepository struct {
+	db *sql.DB
+}
+
+func NewInventoryRepository(db *sql.DB) InventoryRepository {
+	return &inventoryRepository{db}
+}
+
+// AdjustStock applies a manual adjustment like any other stock change, so it is locked, checked against
+// going below zero and recorded in the ledger. An adjustment whose reference was applied before is skipped.
+func (r *inventoryRepository) AdjustStock(ctx context.Context, adjustment domain.StockAdjustment, actor string) (applied bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if adjustment.Reference != "" {
+		applied, err = recordStockEvent(ctx, tx, adjustment.Reference, domain.StockOperationAdjustment)
+		if err != nil || !applied {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	change := domain.StockChange{ProductID: adjustment.ProductID, SKU: adjustment.SKU, Delta: adjustment.Quantity, WarehouseID: adjustment.WarehouseID}
+	source := domain.MovementSource{
+		Reason:    adjustment.Reason,
+		Reference: adjustment.Reference,
+		OrderID:   adjustment.OrderID,
+		Actor:     actor,
+		Note:      adjustment.Note,
+	}
+	_, err = applyStockChanges(ctx, tx, []domain.StockChange{change}, domain.AllocationPolicy{}, source)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetMovements returns a page of the ledger, newest first, with the number of movements matching the filter.
+func (r *inventoryRepository) GetMovements(ctx context.Context, filter domain.MovementFilter) (movements []domain.InventoryMovement, total int, err error) {
+	var conditions []string
+	var args []interface{}
+	if filter.ProductID != 0 {
+		conditions = append(conditions, "product_id = ?")
+		args = append(args, filter.ProductID)
+	}
+	if filter.SKU != "" {
+		conditions = append(conditions, "sku = ?")
+		args = append(args, filter.SKU)
+	}
+	if filter.WarehouseID != 0 {
+		conditions = append(conditions, "warehouse_id = ?")
+		args = append(args, filter.WarehouseID)
+	}
+	if filter.Reason != "" {
+		conditions = append(conditions, "reason = ?")
+		args = append(args, filter.Reason)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM inventory_movements`+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT id, product_id, sku, warehouse_id, quantity, reason, COALESCE(reference, ''), COALESCE(order_id, 0), actor, COALESCE(note, ''), created_at
+		FROM inventory_movements` + where + ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movement domain.InventoryMovement
+		err = rows.Scan(&movement.ID, &movement.ProductID, &movement.SKU, &movement.WarehouseID, &movement.Quantity, &movement.Reason,
+			&movement.Reference, &movement.OrderID, &movement.Actor, &movement.Note, &movement.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		movements = append(movements, movement)
+	}
+
+	return movements, total, rows.Err()
+}
+
+// GetDiscrepancies compares the stock of every product, variant and warehouse with the sum of its ledger
+// entries and returns the ones that differ. Each comparison is a single consistent read.
+func (r *inventoryRepository) GetDiscrepancies(ctx context.Context) (discrepancies []domain.StockDiscrepancy, err error) {
+	queries := []string{
+		`SELECT p.id, '', 0, p.stock, COALESCE(SUM(m.quantity), 0)
+			FROM products p
+			LEFT JOIN inventory_movements m ON m.product_id = p.id AND m.variant_id = 0
+			GROUP BY p.id, p.stock
+			HAVING p.stock <> COALESCE(SUM(m.quantity), 0)`,
+		`SELECT v.product_id, v.sku, 0, v.stock, COALESCE(SUM(m.quantity), 0)
+			FROM product_variants v
+			LEFT JOIN inventory_movements m ON m.product_id = v.product_id AND m.variant_id = v.id
+			GROUP BY v.id, v.product_id, v.sku, v.stock
+			HAVING v.stock <> COALESCE(SUM(m.quantity), 0)`,
+		`SELECT ws.product_id, COALESCE(v.sku, ''), ws.warehouse_id, ws.stock, COALESCE(SUM(m.quantity), 0)
+			FROM warehouse_stock ws
+			LEFT JOIN product_variants v ON v.id = ws.variant_id
+			LEFT JOIN inventory_movements m ON m.product_id = ws.product_id AND m.variant_id = ws.variant_id AND m.warehouse_id = ws.warehouse_id
+			GROUP BY ws.product_id, ws.variant_id, ws.warehouse_id, v.sku, ws.stock
+			HAVING ws.stock <> COALESCE(SUM(m.quantity), 0)`,
+	}
+
+	for _, query := range queries {
+		found, err := queryDiscrepancies(ctx, r.db, query)
+		if err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, found...)
+	}
+
+	return discrepancies, nil
+}
+
+func queryDiscrepancies(ctx context.Context, db *sql.DB, query string) (discrepancies []domain.StockDiscrepancy, err error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var discrepancy domain.StockDiscrepancy
+		err = rows.Scan(&discrepancy.ProductID, &discrepancy.SKU, &discrepancy.WarehouseID, &discrepancy.Stock, &discrepancy.LedgerStock)
+		if err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	return discrepancies, rows.Err()
+}
+
+// ledgerEntry is a movement with the variant it belongs to, which the ledger sums stock by.
+type ledgerEntry struct {
+	variantID int
+	domain.InventoryMovement
+}
+
+// newLedgerEntry describes quantity of change moving in or out of a warehouse on behalf of source.
+func newLedgerEntry(change domain.StockChange, key stockKey, warehouseID, quantity int, source domain.MovementSource) ledgerEntry {
+	reason := source.Reason
+	if reason == "" {
+		reason = domain.MovementRelease
+		if quantity < 0 {
+			reason = domain.MovementReservation
+		}
+	}
+
+	reference := change.Reference
+	if reference == "" {
+		reference = source.Reference
+	}
+
+	actor := source.Actor
+	if actor == "" {
+		actor = domain.SystemActor
+	}
+
+	return ledgerEntry{
+		variantID: key.variantID,
+		InventoryMovement: domain.InventoryMovement{
+			ProductID:   change.ProductID,
+			SKU:         change.SKU,
+			WarehouseID: warehouseID,
+			Quantity:    quantity,
+			Reason:      reason,
+			Reference:   reference,
+			OrderID:     source.OrderID,
+			Actor:       actor,
+			Note:        source.Note,
+		},
+	}
+}
+
+// recordMovements appends the entries to the ledger within the transaction that moved the stock.
+func recordMovements(ctx context.Context, tx *sql.Tx, entries []ledgerEntry) (err error) {
+	query := `INSERT INTO inventory_movements (product_id, variant_id, sku, warehouse_id, quantity, reason, reference, order_id, actor, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	now := time.Now().UTC()
+	for _, entry := range entries {
+		_, err = tx.ExecContext(ctx, query, entry.ProductID, entry.variantID, entry.SKU, entry.WarehouseID, entry.Quantity, entry.Reason,
+			nullString(entry.Reference), nullInt(entry.OrderID), entry.Actor, nullString(entry.Note), now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
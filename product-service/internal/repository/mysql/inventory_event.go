@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"product-service/domain"
+	"time"
+)
+
+// stockLevelEvents describes the inventory events a product, or one of its variants when sku is set,
+// triggers when its stock goes from before to after.
+func stockLevelEvents(productID int, sku string, before, after, reorderPoint int) (events []domain.InventoryEvent) {
+	for _, eventType := range domain.StockLevelEvents(before, after, reorderPoint) {
+		events = append(events, domain.InventoryEvent{
+			Type:         eventType,
+			ProductID:    productID,
+			SKU:          sku,
+			Stock:        after,
+			ReorderPoint: reorderPoint,
+		})
+	}
+	return events
+}
+
+// recordInventoryEvents queues the events within the transaction that changed the stock, so they are
+// published if and only if the change is committed.
+func recordInventoryEvents(ctx context.Context, tx *sql.Tx, events []domain.InventoryEvent) (err error) {
+	query := `INSERT INTO inventory_events (type, product_id, sku, stock, reorder_point, occurred_at) VALUES (?, ?, ?, ?, ?, ?)`
+	now := time.Now().UTC()
+	for _, event := range events {
+		_, err = tx.ExecContext(ctx, query, event.Type, event.ProductID, event.SKU, event.Stock, event.ReorderPoint, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUnpublishedInventoryEvents returns queued inventory events in the order they occurred.
+func (r *inventoryRepository) GetUnpublishedInventoryEvents(ctx context.Context, limit int) (events []domain.InventoryEvent, err error) {
+	query := `SELECT id, type, product_id, sku, stock, reorder_point, occurred_at FROM inventory_events
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event domain.InventoryEvent
+		err = rows.Scan(&event.ID, &event.Type, &event.ProductID, &event.SKU, &event.Stock, &event.ReorderPoint, &event.OccurredAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *inventoryRepository) MarkInventoryEventPublished(ctx context.Context, id int64, now time.Time) (err error) {
+	query := `UPDATE inventory_events SET published_at = ? WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, now.UTC(), id)
+	return err
+}
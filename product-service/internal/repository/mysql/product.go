@@ -3,15 +3,29 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"product-service/domain"
+	"sort"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 type ProductRepository interface {
 	GetProductByID(ctx context.Context, id int) (product domain.Product, err error)
-	CreateProduct(ctx context.Context, req domain.Product) (product domain.Product, err error)
+	CreateProduct(ctx context.Context, req domain.Product, actor string) (product domain.Product, err error)
 	UpdateProduct(ctx context.Context, req domain.Product) (err error)
 	DeleteProduct(ctx context.Context, id int) (err error)
 	GetProducts(ctx context.Context) (products []domain.Product, err error)
+	ListProducts(ctx context.Context, filter domain.ProductFilter) (products []domain.Product, total int, err error)
+	SearchProducts(ctx context.Context, search domain.ProductSearch) (page domain.ProductSearchPage, err error)
+	UpdateStock(ctx context.Context, changes []domain.StockChange, policy domain.AllocationPolicy, source domain.MovementSource) (err error)
+	ApplyStockChanges(ctx context.Context, eventID, eventType string, changes []domain.StockChange, policy domain.AllocationPolicy, source domain.MovementSource) (applied bool, err error)
+	ReleaseReservedStock(ctx context.Context, reserveRef, releaseRef string, changes []domain.StockChange, source domain.MovementSource) (applied bool, err error)
+	ReserveStockBatch(ctx context.Context, items []domain.StockItem, policy domain.AllocationPolicy, source domain.MovementSource) (err error)
+	ReleaseStockBatch(ctx context.Context, items []domain.StockItem, source domain.MovementSource) (err error)
+	GetStockLevels(ctx context.Context, productIDs []int) (levels []domain.StockLevel, err error)
 }
 
 type productRepository struct {
@@ -22,59 +36,99 @@ func NewProductRepository(db *sql.DB) ProductRepository {
 	return &productRepository{db}
 }
 
+const productColumns = `id, name, description, price, stock, reorder_point, category_id, attributes`
+
 func (r *productRepository) GetProductByID(ctx context.Context, id int) (product domain.Product, err error) {
-	query := `SELECT id, name, description, price, stock FROM products WHERE id = ?`
-	err = r.db.QueryRowContext(ctx, query, id).Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Stock)
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = ?`
+	return scanProduct(r.db.QueryRowContext(ctx, query, id))
+}
+
+// CreateProduct adds a product; its initial stock is recorded in the ledger as a restock by actor.
+func (r *productRepository) CreateProduct(ctx context.Context, req domain.Product, actor string) (product domain.Product, err error) {
+	attributes, err := marshalAttributes(req.Attributes)
 	if err != nil {
 		return
 	}
 
-	return
-}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return product, err
+	}
 
-func (r *productRepository) CreateProduct(ctx context.Context, req domain.Product) (product domain.Product, err error) {
-	query := `INSERT INTO products (name, description, price, stock) VALUES (?, ?, ?, ?)`
-	res, err := r.db.ExecContext(ctx, query, req.Name, req.Description, req.Price, req.Stock)
+	query := `INSERT INTO products (name, description, price, stock, reorder_point, category_id, attributes) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	res, err := tx.ExecContext(ctx, query, req.Name, req.Description, req.Price, req.Stock, req.ReorderPoint, nullIntPtr(req.CategoryID), attributes)
 	if err != nil {
-		return
+		tx.Rollback()
+		return product, productWriteError(err)
 	}
 
 	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = seedWarehouseStock(ctx, tx, int(id), 0, "", req.Stock, actor)
+	if err != nil {
+		tx.Rollback()
+		return product, err
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return
 	}
 
 	product = domain.Product{
-		ID:          int(id),
-		Name:        req.Name,
-		Description: req.Description,
-		Price:       req.Price,
-		Stock:       req.Stock,
+		ID:           int(id),
+		Name:         req.Name,
+		Description:  req.Description,
+		Price:        req.Price,
+		Stock:        req.Stock,
+		ReorderPoint: req.ReorderPoint,
+		CategoryID:   req.CategoryID,
+		Attributes:   req.Attributes,
 	}
 
 	return
 }
 
+// UpdateProduct updates the catalog fields of a product. Stock is left alone, it only changes
+// through reservations and releases so concurrent orders are never overwritten.
 func (r *productRepository) UpdateProduct(ctx context.Context, req domain.Product) (err error) {
-	query := `UPDATE products SET name = ?, description = ?, price = ?, stock = ? WHERE id = ?`
-	_, err = r.db.ExecContext(ctx, query, req.Name, req.Description, req.Price, req.Stock, req.ID)
+	attributes, err := marshalAttributes(req.Attributes)
 	if err != nil {
 		return
 	}
+
+	query := `UPDATE products SET name = ?, description = ?, price = ?, reorder_point = ?, category_id = ?, attributes = ? WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, req.Name, req.Description, req.Price, req.ReorderPoint, nullIntPtr(req.CategoryID), attributes, req.ID)
+	if err != nil {
+		return productWriteError(err)
+	}
 	return
 }
 
+// DeleteProduct deletes a product, returning sql.ErrNoRows if it does not exist.
 func (r *productRepository) DeleteProduct(ctx context.Context, id int) (err error) {
 	query := `DELETE FROM products WHERE id = ?`
-	_, err = r.db.ExecContext(ctx, query, id)
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
 func (r *productRepository) GetProducts(ctx context.Context) (products []domain.Product, err error) {
-	query := `SELECT id, name, description, price, stock FROM products`
+	query := `SELECT ` + productColumns + ` FROM products`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return
@@ -82,13 +136,534 @@ func (r *productRepository) GetProducts(ctx context.Context) (products []domain.
 	defer rows.Close()
 
 	for rows.Next() {
-		var product domain.Product
-		err = rows.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Stock)
+		product, err := scanProduct(rows)
 		if err != nil {
-			return
+			return products, err
 		}
 		products = append(products, product)
 	}
 
-	return
+	return products, rows.Err()
+}
+
+// GetStockLevels returns the total stock of the products in one query, ordered by ID. Products that do not exist are left out.
+func (r *productRepository) GetStockLevels(ctx context.Context, productIDs []int) (levels []domain.StockLevel, err error) {
+	args := make([]interface{}, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+
+	query := `SELECT id, COALESCE(category_id, 0), stock FROM products WHERE id IN (?` + strings.Repeat(", ?", len(productIDs)-1) + `) ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var level domain.StockLevel
+		if err = rows.Scan(&level.ProductID, &level.CategoryID, &level.Stock); err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, rows.Err()
+}
+
+// ListProducts returns a page of products in the order of the filter, with the total number of products
+// matching it.
+func (r *productRepository) ListProducts(ctx context.Context, filter domain.ProductFilter) (products []domain.Product, total int, err error) {
+	where := ``
+	var args []interface{}
+	if len(filter.CategoryIDs) > 0 {
+		where = ` WHERE category_id IN (?` + strings.Repeat(", ?", len(filter.CategoryIDs)-1) + `)`
+		for _, id := range filter.CategoryIDs {
+			args = append(args, id)
+		}
+	}
+
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM products`+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The sort field is checked against domain.IsProductSort, so it is safe to put in the query;
+	// id breaks ties so pages stay stable
+	sortField := domain.ProductSortID
+	if domain.IsProductSort(filter.Sort) {
+		sortField = filter.Sort
+	}
+	direction := "ASC"
+	if filter.Desc {
+		direction = "DESC"
+	}
+	query := fmt.Sprintf(`SELECT `+productColumns+` FROM products%s ORDER BY %s %s, id %s LIMIT ? OFFSET ?`, where, sortField, direction, direction)
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		products = append(products, product)
+	}
+
+	return products, total, rows.Err()
+}
+
+// productWriteError maps constraint violations of the products table to domain errors.
+func productWriteError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrNoReferencedRow {
+		return fmt.Errorf("%w: category does not exist", domain.ErrInvalidProduct)
+	}
+	return err
+}
+
+// scanProduct scans the productColumns of a row, followed by any extra columns.
+func scanProduct(row interface{ Scan(dest ...any) error }, extra ...interface{}) (product domain.Product, err error) {
+	var categoryID sql.NullInt64
+	var attributes []byte
+	dest := append([]interface{}{&product.ID, &product.Name, &product.Description, &product.Price, &product.Stock, &product.ReorderPoint, &categoryID, &attributes}, extra...)
+	err = row.Scan(dest...)
+	if err != nil {
+		return product, err
+	}
+
+	if categoryID.Valid {
+		id := int(categoryID.Int64)
+		product.CategoryID = &id
+	}
+
+	product.Attributes, err = unmarshalAttributes(attributes)
+	return product, err
+}
+
+// UpdateStock applies the stock changes atomically in a single transaction, failing with
+// ErrInsufficientStock without changing anything if any product would go below zero.
+func (r *productRepository) UpdateStock(ctx context.Context, changes []domain.StockChange, policy domain.AllocationPolicy, source domain.MovementSource) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = applyStockChanges(ctx, tx, changes, policy, source)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ApplyStockChanges applies the stock changes of an event and records the event in processed_events
+// in a single transaction. Events that were already processed are skipped and reported as not applied.
+// The warehouses a reservation took its stock from are recorded under eventID for its release.
+func (r *productRepository) ApplyStockChanges(ctx context.Context, eventID, eventType string, changes []domain.StockChange, policy domain.AllocationPolicy, source domain.MovementSource) (applied bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// Record the event first; a duplicate key means it has already been applied
+	applied, err = recordStockEvent(ctx, tx, eventID, eventType)
+	if err != nil || !applied {
+		tx.Rollback()
+		return false, err
+	}
+
+	source.Reference = eventID
+	allocations, err := applyStockChanges(ctx, tx, changes, policy, source)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if eventType == domain.StockOperationReserve {
+		for _, allocation := range allocations {
+			err = recordAllocations(ctx, tx, eventID, allocation)
+			if err != nil {
+				tx.Rollback()
+				return false, err
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReleaseReservedStock releases stock held by the reservation recorded under reserveRef, once per releaseRef.
+// When the reservation was never applied nothing is released and reserveRef is marked cancelled, so a
+// reservation arriving late is skipped instead of holding stock nobody will release. The stock goes back
+// to the warehouses it was reserved from.
+func (r *productRepository) ReleaseReservedStock(ctx context.Context, reserveRef, releaseRef string, changes []domain.StockChange, source domain.MovementSource) (applied bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	applied, err = releaseReservation(ctx, tx, reserveRef, releaseRef)
+	if err != nil || !applied {
+		tx.Rollback()
+		return false, err
+	}
+
+	changes, err = allocatedChanges(ctx, tx, reserveRef, changes)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	source.Reference = releaseRef
+	_, err = applyStockChanges(ctx, tx, changes, domain.AllocationPolicy{}, source)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReserveStockBatch reserves every item in one transaction, or nothing if any product is short.
+// Items with a reference that was already applied are skipped, so a retried batch reserves only once.
+// The warehouses the stock of an item with a reference was taken from are recorded under its reference.
+func (r *productRepository) ReserveStockBatch(ctx context.Context, items []domain.StockItem, policy domain.AllocationPolicy, source domain.MovementSource) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var changes []domain.StockChange
+	var references []string
+	for _, item := range sortStockItems(items) {
+		if item.Reference != "" {
+			inserted, err := recordStockEvent(ctx, tx, item.Reference, domain.StockOperationReserve)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			if !inserted {
+				continue
+			}
+		}
+
+		changes = append(changes, domain.StockChange{ProductID: item.ProductID, SKU: item.SKU, Delta: -item.Quantity, Reference: item.Reference})
+		references = append(references, item.Reference)
+	}
+
+	allocations, err := applyStockChanges(ctx, tx, changes, policy, source)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for i, reference := range references {
+		if reference == "" {
+			continue
+		}
+
+		err = recordAllocations(ctx, tx, reference, allocations[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ReleaseStockBatch releases every item in one transaction, following the reference rules of a single release.
+func (r *productRepository) ReleaseStockBatch(ctx context.Context, items []domain.StockItem, source domain.MovementSource) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var changes []domain.StockChange
+	for _, item := range sortStockItems(items) {
+		change := []domain.StockChange{{ProductID: item.ProductID, SKU: item.SKU, Delta: item.Quantity, Reference: item.Reference}}
+
+		apply := true
+		switch {
+		case item.ReserveReference != "":
+			apply, err = releaseReservation(ctx, tx, item.ReserveReference, item.Reference)
+			if err == nil && apply {
+				change, err = allocatedChanges(ctx, tx, item.ReserveReference, change)
+			}
+		case item.Reference != "":
+			apply, err = recordStockEvent(ctx, tx, item.Reference, domain.StockOperationRelease)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if apply {
+			changes = append(changes, change...)
+		}
+	}
+
+	_, err = applyStockChanges(ctx, tx, changes, domain.AllocationPolicy{}, source)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// sortStockItems copies the items of a batch in a fixed order, so concurrent batches record their
+// references in the same order.
+func sortStockItems(items []domain.StockItem) (sorted []domain.StockItem) {
+	sorted = make([]domain.StockItem, len(items))
+	copy(sorted, items)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ProductID != sorted[j].ProductID {
+			return sorted[i].ProductID < sorted[j].ProductID
+		}
+		if sorted[i].SKU != sorted[j].SKU {
+			return sorted[i].SKU < sorted[j].SKU
+		}
+		return sorted[i].Reference < sorted[j].Reference
+	})
+	return sorted
+}
+
+// recordStockEvent records a stock operation in processed_events. It reports false if the ID was recorded before.
+func recordStockEvent(ctx context.Context, tx *sql.Tx, eventID, eventType string) (inserted bool, err error) {
+	ledgerQuery := `INSERT IGNORE INTO processed_events (event_id, event_type) VALUES (?, ?)`
+	res, err := tx.ExecContext(ctx, ledgerQuery, eventID, eventType)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// releaseReservation records the release releaseRef of the reservation reserveRef and reports whether stock
+// must be given back: only the first release of a reservation that was actually applied gives stock back.
+func releaseReservation(ctx context.Context, tx *sql.Tx, reserveRef, releaseRef string) (release bool, err error) {
+	inserted, err := recordStockEvent(ctx, tx, releaseRef, domain.StockOperationRelease)
+	if err != nil || !inserted {
+		return false, err
+	}
+
+	cancelled, err := recordStockEvent(ctx, tx, reserveRef, domain.StockOperationReserveCancelled)
+	if err != nil || cancelled {
+		return false, err
+	}
+
+	var reserveType string
+	typeQuery := `SELECT event_type FROM processed_events WHERE event_id = ? FOR UPDATE`
+	err = tx.QueryRowContext(ctx, typeQuery, reserveRef).Scan(&reserveType)
+	if err != nil {
+		return false, err
+	}
+
+	return reserveType == domain.StockOperationReserve, nil
+}
+
+// applyStockChanges locks the products in ID order, then the variants in SKU order and then their warehouse
+// stock, to avoid deadlocks between concurrent transactions, checks that no stock goes below zero and then
+// applies the changes. Reservations are taken from warehouses following policy; allocations[i] lists where
+// the stock of changes[i] was taken from. Every movement is recorded in the ledger on behalf of source, and
+// stock crossing a reorder point or zero queues an inventory event. When products are short nothing is
+// changed and an InsufficientStockError lists every shortfall.
+func applyStockChanges(ctx context.Context, tx *sql.Tx, changes []domain.StockChange, policy domain.AllocationPolicy, source domain.MovementSource) (allocations [][]domain.StockAllocation, err error) {
+	productDeltas := map[int]int{}
+	variantDeltas := map[string]int{}
+	variantProducts := map[string]int{}
+	var productIDs []int
+	var skus []string
+	for _, change := range changes {
+		if change.SKU != "" {
+			if _, ok := variantDeltas[change.SKU]; !ok {
+				skus = append(skus, change.SKU)
+			}
+			variantDeltas[change.SKU] += change.Delta
+			variantProducts[change.SKU] = change.ProductID
+			continue
+		}
+
+		if _, ok := productDeltas[change.ProductID]; !ok {
+			productIDs = append(productIDs, change.ProductID)
+		}
+		productDeltas[change.ProductID] += change.Delta
+	}
+	sort.Ints(productIDs)
+	sort.Strings(skus)
+
+	var shortfalls []domain.StockShortfall
+
+	products := map[int]lockedProduct{}
+	if len(productIDs) > 0 {
+		products, err = lockProductStock(ctx, tx, productIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range productIDs {
+			product, ok := products[id]
+			if !ok || product.stock+productDeltas[id] < 0 {
+				shortfalls = append(shortfalls, domain.StockShortfall{ProductID: id, Requested: -productDeltas[id], Available: product.stock})
+			}
+		}
+	}
+
+	variants := map[string]lockedVariant{}
+	variantIDs := map[string]int{}
+	if len(skus) > 0 {
+		variants, err = lockVariantStock(ctx, tx, skus)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, sku := range skus {
+			variant, ok := variants[sku]
+			if !ok || variant.productID != variantProducts[sku] {
+				return nil, fmt.Errorf("sku %s of product %d: %w", sku, variantProducts[sku], domain.ErrVariantNotFound)
+			}
+			if variant.stock+variantDeltas[sku] < 0 {
+				shortfalls = append(shortfalls, domain.StockShortfall{ProductID: variantProducts[sku], SKU: sku, Requested: -variantDeltas[sku], Available: variant.stock})
+			}
+			variantIDs[sku] = variant.id
+		}
+	}
+
+	if len(shortfalls) > 0 {
+		return nil, &domain.InsufficientStockError{Shortfalls: shortfalls}
+	}
+
+	allocations, err = applyWarehouseStock(ctx, tx, changes, variantIDs, policy, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []domain.InventoryEvent
+	productQuery := `UPDATE products SET stock = stock + ? WHERE id = ?`
+	for _, id := range productIDs {
+		if productDeltas[id] == 0 {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, productQuery, productDeltas[id], id)
+		if err != nil {
+			return nil, err
+		}
+		product := products[id]
+		events = append(events, stockLevelEvents(id, "", product.stock, product.stock+productDeltas[id], product.reorderPoint)...)
+	}
+
+	variantQuery := `UPDATE product_variants SET stock = stock + ? WHERE sku = ?`
+	for _, sku := range skus {
+		if variantDeltas[sku] == 0 {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, variantQuery, variantDeltas[sku], sku)
+		if err != nil {
+			return nil, err
+		}
+		variant := variants[sku]
+		events = append(events, stockLevelEvents(variant.productID, sku, variant.stock, variant.stock+variantDeltas[sku], variant.reorderPoint)...)
+	}
+
+	err = recordInventoryEvents(ctx, tx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	return allocations, nil
+}
+
+type lockedProduct struct {
+	stock        int
+	reorderPoint int
+}
+
+// lockProductStock locks the products and returns their stock by ID. Missing products are left out.
+func lockProductStock(ctx context.Context, tx *sql.Tx, ids []int) (products map[int]lockedProduct, err error) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	query := `SELECT id, stock, reorder_point FROM products WHERE id IN (?` + strings.Repeat(", ?", len(ids)-1) + `) ORDER BY id FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products = make(map[int]lockedProduct, len(ids))
+	for rows.Next() {
+		var id int
+		var product lockedProduct
+		if err = rows.Scan(&id, &product.stock, &product.reorderPoint); err != nil {
+			return nil, err
+		}
+		products[id] = product
+	}
+
+	return products, rows.Err()
+}
+
+type lockedVariant struct {
+	id           int
+	productID    int
+	stock        int
+	reorderPoint int // of the product, which its variants share
+}
+
+// lockVariantStock locks the variants and returns them by SKU. Missing SKUs are left out. Only the
+// variant rows are locked; their products keep the lock order of lockProductStock.
+func lockVariantStock(ctx context.Context, tx *sql.Tx, skus []string) (variants map[string]lockedVariant, err error) {
+	args := make([]interface{}, len(skus))
+	for i, sku := range skus {
+		args[i] = sku
+	}
+
+	query := `SELECT v.sku, v.id, v.product_id, v.stock, p.reorder_point
+		FROM product_variants v
+		JOIN products p ON p.id = v.product_id
+		WHERE v.sku IN (?` + strings.Repeat(", ?", len(skus)-1) + `)
+		ORDER BY v.sku FOR UPDATE OF v`
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants = make(map[string]lockedVariant, len(skus))
+	for rows.Next() {
+		var sku string
+		var variant lockedVariant
+		if err = rows.Scan(&sku, &variant.id, &variant.productID, &variant.stock, &variant.reorderPoint); err != nil {
+			return nil, err
+		}
+		variants[sku] = variant
+	}
+
+	return variants, rows.Err()
 }
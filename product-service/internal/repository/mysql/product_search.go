@@ -0,0 +1,112 @@
+package mysql
+
+import (
+	"context"
+	"product-service/domain"
+	"strings"
+)
+
+const productMatch = `MATCH(name, description) AGAINST (? IN NATURAL LANGUAGE MODE)`
+
+// SearchProducts returns a page of products matching the search. Keywords use the FULLTEXT index on
+// name and description; pages continue after the cursor on (sort value, id), so results stay stable
+// while products are added.
+func (r *productRepository) SearchProducts(ctx context.Context, search domain.ProductSearch) (page domain.ProductSearchPage, err error) {
+	var conditions []string
+	var args []interface{}
+
+	columns := productColumns
+	sortExpr := search.Sort
+	var sortArgs []interface{}
+	if search.Query != "" {
+		columns += `, ` + productMatch
+		args = append(args, search.Query)
+		conditions = append(conditions, productMatch)
+		args = append(args, search.Query)
+		if search.Sort == domain.ProductSortRelevance {
+			sortExpr = productMatch
+			sortArgs = []interface{}{search.Query}
+		}
+	}
+
+	if search.MinPrice != nil {
+		conditions = append(conditions, "price >= ?")
+		args = append(args, *search.MinPrice)
+	}
+	if search.MaxPrice != nil {
+		conditions = append(conditions, "price <= ?")
+		args = append(args, *search.MaxPrice)
+	}
+	if search.InStock {
+		conditions = append(conditions, "stock > 0")
+	}
+
+	comparison, direction := ">", "ASC"
+	if search.Desc {
+		comparison, direction = "<", "DESC"
+	}
+
+	if search.Cursor != nil {
+		conditions = append(conditions, "("+sortExpr+" "+comparison+" ? OR ("+sortExpr+" = ? AND id "+comparison+" ?))")
+		args = append(args, sortArgs...)
+		args = append(args, search.Cursor.Value)
+		args = append(args, sortArgs...)
+		args = append(args, search.Cursor.Value, search.Cursor.ID)
+	}
+
+	query := `SELECT ` + columns + ` FROM products`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY ` + sortExpr + ` ` + direction + `, id ` + direction + ` LIMIT ?`
+	args = append(args, sortArgs...)
+	args = append(args, search.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	page.Products = []domain.Product{}
+	var scores []float64
+	for rows.Next() {
+		var score float64
+		var extra []interface{}
+		if search.Query != "" {
+			extra = append(extra, &score)
+		}
+
+		product, err := scanProduct(rows, extra...)
+		if err != nil {
+			return page, err
+		}
+		page.Products = append(page.Products, product)
+		scores = append(scores, score)
+	}
+	if err = rows.Err(); err != nil {
+		return page, err
+	}
+
+	// The extra row only tells whether there is a next page
+	if len(page.Products) > search.Limit {
+		page.Products = page.Products[:search.Limit]
+		last := page.Products[search.Limit-1]
+		cursor := domain.ProductCursor{Sort: search.Sort, Desc: search.Desc, ID: last.ID}
+		switch search.Sort {
+		case domain.ProductSortRelevance:
+			cursor.Value = scores[search.Limit-1]
+		case domain.ProductSortName:
+			cursor.Value = last.Name
+		case domain.ProductSortPrice:
+			cursor.Value = last.Price
+		case domain.ProductSortStock:
+			cursor.Value = float64(last.Stock)
+		default:
+			cursor.Value = float64(last.ID)
+		}
+		page.NextCursor = cursor.Encode()
+	}
+
+	return page, nil
+}
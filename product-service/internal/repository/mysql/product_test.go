@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"product-service/domain"
+	"product-service/internal/testutil"
+)
+
+const (
+	contendedStock    = 50
+	concurrentReserve = 300
+)
+
+func TestUpdateStockConcurrentReservesNeverOversell(t *testing.T) {
+	db := testutil.MySQL(t)
+	repo := NewProductRepository(db)
+	product := createTestProduct(t, repo, contendedStock)
+
+	reserveConcurrently(t, db, product.ID, func(ctx context.Context, i int) error {
+		change := domain.StockChange{ProductID: product.ID, Delta: -1}
+		return repo.UpdateStock(ctx, []domain.StockChange{change}, domain.AllocationPolicy{}, domain.MovementSource{Actor: "test"})
+	})
+}
+
+func TestReserveStockBatchConcurrentReservesNeverOversell(t *testing.T) {
+	db := testutil.MySQL(t)
+	repo := NewProductRepository(db)
+	product := createTestProduct(t, repo, contendedStock)
+
+	reserveConcurrently(t, db, product.ID, func(ctx context.Context, i int) error {
+		item := domain.StockItem{ProductID: product.ID, Quantity: 1, Reference: fmt.Sprintf("test:reserve:%d", i)}
+		return repo.ReserveStockBatch(ctx, []domain.StockItem{item}, domain.AllocationPolicy{}, domain.MovementSource{Actor: "test"})
+	})
+}
+
+// reserveConcurrently fires concurrentReserve reservations of one unit at once and checks that exactly the stock
+// was reserved, the rest failed as out of stock, and that the product, its warehouses and the ledger end at zero.
+func reserveConcurrently(t *testing.T, db *sql.DB, productID int, reserve func(ctx context.Context, i int) error) {
+	t.Helper()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved, short := 0, 0
+	start := make(chan struct{})
+	for i := 0; i < concurrentReserve; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			err := reserve(ctx, i)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				reserved++
+			case errors.Is(err, domain.ErrInsufficientStock):
+				short++
+			default:
+				t.Errorf("reserve %d: %v", i, err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if reserved != contendedStock || short != concurrentReserve-contendedStock {
+		t.Errorf("got %d reserved and %d out of stock, want %d and %d", reserved, short, contendedStock, concurrentReserve-contendedStock)
+	}
+
+	checks := map[string]string{
+		"product stock":   `SELECT stock FROM products WHERE id = ?`,
+		"warehouse stock": `SELECT COALESCE(SUM(stock), 0) FROM warehouse_stock WHERE product_id = ?`,
+		"ledger stock":    `SELECT COALESCE(SUM(quantity), 0) FROM inventory_movements WHERE product_id = ?`,
+	}
+	for name, query := range checks {
+		var stock int
+		err := db.QueryRow(query, productID).Scan(&stock)
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		if stock != 0 {
+			t.Errorf("%s is %d after reserving everything, want 0", name, stock)
+		}
+	}
+}
+
+func createTestProduct(t *testing.T, repo ProductRepository, stock int) domain.Product {
+	t.Helper()
+
+	product, err := repo.CreateProduct(context.Background(), domain.Product{Name: "Contended product", Description: "Reserved by many orders at once", Price: 10, Stock: stock}, "test")
+	if err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+	return product
+}
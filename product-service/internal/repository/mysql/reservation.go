@@ -0,0 +1,317 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"product-service/domain"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlErrDuplicateEntry is the MySQL error number for a duplicate unique key
+const mysqlErrDuplicateEntry = 1062
+
+type ReservationRepository interface {
+	CreateReservation(ctx context.Context, req domain.StockReservation, policy domain.AllocationPolicy, actor string) (reservation domain.StockReservation, err error)
+	GetReservationByID(ctx context.Context, id int64) (reservation domain.StockReservation, err error)
+	ConfirmReservation(ctx context.Context, id int64, now time.Time, actor string) (reservation domain.StockReservation, err error)
+	ReleaseReservation(ctx context.Context, id int64, now time.Time, actor string) (reservation domain.StockReservation, err error)
+	ExpireReservations(ctx context.Context, now time.Time, limit int) (expired []domain.StockReservation, err error)
+	GetUnnotifiedExpiredReservations(ctx context.Context, limit int) (reservations []domain.StockReservation, err error)
+	MarkReservationNotified(ctx context.Context, id int64, now time.Time) (err error)
+}
+
+type reservationRepository struct {
+	db *sql.DB
+}
+
+func NewReservationRepository(db *sql.DB) ReservationRepository {
+	return &reservationRepository{db}
+}
+
+const reservationColumns = `id, COALESCE(order_id, 0), COALESCE(reference, ''), product_id, COALESCE(sku, ''), quantity, state, expires_at, created_at, updated_at`
+
+// CreateReservation takes the stock and records the held reservation in one transaction. A reservation
+// with the same reference is returned as is instead of reserving twice. The stock is taken from the
+// warehouses chosen by policy and recorded in the ledger on behalf of actor.
+func (r *reservationRepository) CreateReservation(ctx context.Context, req domain.StockReservation, policy domain.AllocationPolicy, actor string) (reservation domain.StockReservation, err error) {
+	if req.Reference != "" {
+		reservation, err = r.getReservationByReference(ctx, req.Reference)
+		if err != sql.ErrNoRows {
+			return reservation, err
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return reservation, err
+	}
+
+	// The reservation row comes first, like on release, and gives the ledger entries their reference
+	query := `INSERT INTO stock_reservations (order_id, reference, product_id, sku, quantity, state, expires_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := tx.ExecContext(ctx, query, nullInt(req.OrderID), nullString(req.Reference), req.ProductID, nullString(req.SKU), req.Quantity,
+		domain.ReservationStateHeld, req.ExpiresAt.UTC(), req.CreatedAt.UTC(), req.CreatedAt.UTC())
+	if err != nil {
+		tx.Rollback()
+
+		// A concurrent call with the same reference won the race
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry && req.Reference != "" {
+			return r.getReservationByReference(ctx, req.Reference)
+		}
+		return reservation, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return reservation, err
+	}
+
+	source := domain.MovementSource{Reason: domain.MovementReservation, Reference: reservationAllocationRef(id), OrderID: req.OrderID, Actor: actor}
+	allocations, err := applyStockChanges(ctx, tx, []domain.StockChange{{ProductID: req.ProductID, SKU: req.SKU, Delta: -req.Quantity}}, policy, source)
+	if err != nil {
+		tx.Rollback()
+		return reservation, err
+	}
+
+	err = recordAllocations(ctx, tx, reservationAllocationRef(id), allocations[0])
+	if err != nil {
+		tx.Rollback()
+		return reservation, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return reservation, err
+	}
+
+	reservation = req
+	reservation.ID = id
+	reservation.State = domain.ReservationStateHeld
+	reservation.UpdatedAt = req.CreatedAt
+	return reservation, nil
+}
+
+func (r *reservationRepository) GetReservationByID(ctx context.Context, id int64) (reservation domain.StockReservation, err error) {
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations WHERE id = ?`
+	return scanReservation(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *reservationRepository) getReservationByReference(ctx context.Context, reference string) (reservation domain.StockReservation, err error) {
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations WHERE reference = ?`
+	return scanReservation(r.db.QueryRowContext(ctx, query, reference))
+}
+
+// ConfirmReservation makes a held reservation permanent; the stock stays taken. Confirming twice is a no-op.
+// A reservation past its expiry is expired on the spot, on behalf of actor, and reported as a conflict.
+func (r *reservationRepository) ConfirmReservation(ctx context.Context, id int64, now time.Time, actor string) (reservation domain.StockReservation, err error) {
+	return r.changeReservation(ctx, id, now, func(tx *sql.Tx, reservation *domain.StockReservation) (err error) {
+		switch {
+		case reservation.State == domain.ReservationStateConfirmed:
+			return nil
+		case reservation.State != domain.ReservationStateHeld:
+			return domain.ErrReservationConflict
+		case !reservation.ExpiresAt.After(now):
+			err = expireReservation(ctx, tx, reservation, now, actor)
+			if err != nil {
+				return err
+			}
+			return errReservationExpiredOnConfirm
+		}
+
+		return updateReservationState(ctx, tx, reservation, domain.ReservationStateConfirmed, now)
+	})
+}
+
+// errReservationExpiredOnConfirm commits the expiry found by a confirm before reporting the conflict.
+var errReservationExpiredOnConfirm = errors.New("reservation expired")
+
+// ReleaseReservation gives the stock of a held or confirmed reservation back. Releasing twice is a no-op.
+func (r *reservationRepository) ReleaseReservation(ctx context.Context, id int64, now time.Time, actor string) (reservation domain.StockReservation, err error) {
+	return r.changeReservation(ctx, id, now, func(tx *sql.Tx, reservation *domain.StockReservation) (err error) {
+		switch reservation.State {
+		case domain.ReservationStateReleased:
+			return nil
+		case domain.ReservationStateHeld, domain.ReservationStateConfirmed:
+		default:
+			return domain.ErrReservationConflict
+		}
+
+		err = releaseReservationStock(ctx, tx, reservation, actor)
+		if err != nil {
+			return err
+		}
+
+		return updateReservationState(ctx, tx, reservation, domain.ReservationStateReleased, now)
+	})
+}
+
+// changeReservation locks the reservation and applies change to it in one transaction.
+func (r *reservationRepository) changeReservation(ctx context.Context, id int64, now time.Time, change func(tx *sql.Tx, reservation *domain.StockReservation) error) (reservation domain.StockReservation, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return reservation, err
+	}
+
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations WHERE id = ? FOR UPDATE`
+	reservation, err = scanReservation(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return reservation, domain.ErrReservationNotFound
+		}
+		return reservation, err
+	}
+
+	err = change(tx, &reservation)
+	if errors.Is(err, errReservationExpiredOnConfirm) {
+		if err = tx.Commit(); err != nil {
+			return reservation, err
+		}
+		return reservation, domain.ErrReservationConflict
+	}
+	if err != nil {
+		tx.Rollback()
+		return reservation, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return reservation, err
+	}
+
+	return reservation, nil
+}
+
+// ExpireReservations gives the stock of up to limit held reservations past their expiry back and marks them expired.
+// Rows locked by a concurrent confirm or release are skipped and picked up by the next sweep.
+func (r *reservationRepository) ExpireReservations(ctx context.Context, now time.Time, limit int) (expired []domain.StockReservation, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations
+		WHERE state = ? AND expires_at <= ?
+		ORDER BY expires_at
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, domain.ReservationStateHeld, now.UTC(), limit)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, err
+		}
+		expired = append(expired, reservation)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for i := range expired {
+		err = expireReservation(ctx, tx, &expired[i], now, domain.SystemActor)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
+
+// GetUnnotifiedExpiredReservations returns expired reservations whose expiry event has not been published yet.
+func (r *reservationRepository) GetUnnotifiedExpiredReservations(ctx context.Context, limit int) (reservations []domain.StockReservation, err error) {
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations
+		WHERE state = ? AND notified_at IS NULL
+		ORDER BY id
+		LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, domain.ReservationStateExpired, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, rows.Err()
+}
+
+func (r *reservationRepository) MarkReservationNotified(ctx context.Context, id int64, now time.Time) (err error) {
+	query := `UPDATE stock_reservations SET notified_at = ? WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, now.UTC(), id)
+	return err
+}
+
+// expireReservation gives the stock back and marks the reservation expired within the caller's transaction.
+func expireReservation(ctx context.Context, tx *sql.Tx, reservation *domain.StockReservation, now time.Time, actor string) (err error) {
+	err = releaseReservationStock(ctx, tx, reservation, actor)
+	if err != nil {
+		return err
+	}
+
+	return updateReservationState(ctx, tx, reservation, domain.ReservationStateExpired, now)
+}
+
+// releaseReservationStock gives the stock of a reservation back to the warehouses it was taken from.
+func releaseReservationStock(ctx context.Context, tx *sql.Tx, reservation *domain.StockReservation, actor string) (err error) {
+	changes, err := allocatedChanges(ctx, tx, reservationAllocationRef(reservation.ID), []domain.StockChange{
+		{ProductID: reservation.ProductID, SKU: reservation.SKU, Delta: reservation.Quantity},
+	})
+	if err != nil {
+		return err
+	}
+
+	source := domain.MovementSource{Reason: domain.MovementRelease, Reference: reservationAllocationRef(reservation.ID), OrderID: reservation.OrderID, Actor: actor}
+	_, err = applyStockChanges(ctx, tx, changes, domain.AllocationPolicy{}, source)
+	return err
+}
+
+func updateReservationState(ctx context.Context, tx *sql.Tx, reservation *domain.StockReservation, state string, now time.Time) (err error) {
+	query := `UPDATE stock_reservations SET state = ?, updated_at = ? WHERE id = ?`
+	_, err = tx.ExecContext(ctx, query, state, now.UTC(), reservation.ID)
+	if err != nil {
+		return err
+	}
+
+	reservation.State = state
+	reservation.UpdatedAt = now
+	return nil
+}
+
+func scanReservation(row interface{ Scan(dest ...any) error }) (reservation domain.StockReservation, err error) {
+	err = row.Scan(&reservation.ID, &reservation.OrderID, &reservation.Reference, &reservation.ProductID, &reservation.SKU, &reservation.Quantity,
+		&reservation.State, &reservation.ExpiresAt, &reservation.CreatedAt, &reservation.UpdatedAt)
+	return reservation, err
+}
+
+func nullInt(value int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(value), Valid: value != 0}
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,205 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"product-service/domain"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type VariantRepository interface {
+	GetVariantsByProductIDs(ctx context.Context, productIDs []int) (variants []domain.Variant, err error)
+	GetVariantByID(ctx context.Context, id int) (variant domain.Variant, err error)
+	CreateVariant(ctx context.Context, req domain.Variant, actor string) (variant domain.Variant, err error)
+	UpdateVariant(ctx context.Context, req domain.Variant) (err error)
+	DeleteVariant(ctx context.Context, id int) (err error)
+}
+
+type variantRepository struct {
+	db *sql.DB
+}
+
+func NewVariantRepository(db *sql.DB) VariantRepository {
+	return &variantRepository{db}
+}
+
+const variantColumns = `id, product_id, sku, attributes, price, stock`
+
+// GetVariantsByProductIDs returns the variants of the products, ordered by product and ID.
+func (r *variantRepository) GetVariantsByProductIDs(ctx context.Context, productIDs []int) (variants []domain.Variant, err error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+
+	query := `SELECT ` + variantColumns + ` FROM product_variants WHERE product_id IN (?` + strings.Repeat(", ?", len(productIDs)-1) + `) ORDER BY product_id, id`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, rows.Err()
+}
+
+func (r *variantRepository) GetVariantByID(ctx context.Context, id int) (variant domain.Variant, err error) {
+	query := `SELECT ` + variantColumns + ` FROM product_variants WHERE id = ?`
+	return scanVariant(r.db.QueryRowContext(ctx, query, id))
+}
+
+// CreateVariant adds a variant to a product. A SKU that is already taken is reported as ErrInvalidVariant.
+// Its initial stock is recorded in the ledger as a restock by actor.
+func (r *variantRepository) CreateVariant(ctx context.Context, req domain.Variant, actor string) (variant domain.Variant, err error) {
+	attributes, err := marshalAttributes(req.Attributes)
+	if err != nil {
+		return variant, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return variant, err
+	}
+
+	query := `INSERT INTO product_variants (product_id, sku, attributes, price, stock) VALUES (?, ?, ?, ?, ?)`
+	res, err := tx.ExecContext(ctx, query, req.ProductID, req.SKU, attributes, nullFloat(req.Price), req.Stock)
+	if err != nil {
+		tx.Rollback()
+		return variant, variantWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return variant, err
+	}
+
+	err = seedWarehouseStock(ctx, tx, req.ProductID, int(id), req.SKU, req.Stock, actor)
+	if err != nil {
+		tx.Rollback()
+		return variant, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return variant, err
+	}
+
+	variant = req
+	variant.ID = int(id)
+	return variant, nil
+}
+
+// UpdateVariant updates the SKU, attributes and price of a variant. Like products, its stock only
+// changes through reservations and releases.
+func (r *variantRepository) UpdateVariant(ctx context.Context, req domain.Variant) (err error) {
+	attributes, err := marshalAttributes(req.Attributes)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE product_variants SET sku = ?, attributes = ?, price = ? WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, req.SKU, attributes, nullFloat(req.Price), req.ID)
+	return variantWriteError(err)
+}
+
+// DeleteVariant deletes a variant and its warehouse stock, returning sql.ErrNoRows if it does not exist.
+func (r *variantRepository) DeleteVariant(ctx context.Context, id int) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM product_variants WHERE id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rows == 0 {
+		tx.Rollback()
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM warehouse_stock WHERE variant_id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func variantWriteError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+		return fmt.Errorf("%w: sku is already used by another variant", domain.ErrInvalidVariant)
+	}
+	return err
+}
+
+func scanVariant(row interface{ Scan(dest ...any) error }) (variant domain.Variant, err error) {
+	var attributes []byte
+	var price sql.NullFloat64
+	err = row.Scan(&variant.ID, &variant.ProductID, &variant.SKU, &attributes, &price, &variant.Stock)
+	if err != nil {
+		return variant, err
+	}
+
+	if price.Valid {
+		variant.Price = &price.Float64
+	}
+
+	variant.Attributes, err = unmarshalAttributes(attributes)
+	return variant, err
+}
+
+// marshalAttributes encodes free-form attributes for a JSON column, NULL when there are none.
+func marshalAttributes(attributes map[string]string) (value interface{}, err error) {
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(attributes)
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func unmarshalAttributes(raw []byte) (attributes map[string]string, err error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	err = json.Unmarshal(raw, &attributes)
+	return attributes, err
+}
+
+func nullFloat(value *float64) sql.NullFloat64 {
+	if value == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *value, Valid: true}
+}
@@ -0,0 +1,216 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"product-service/domain"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type WarehouseRepository interface {
+	GetWarehouses(ctx context.Context) (warehouses []domain.Warehouse, err error)
+	GetWarehouseByID(ctx context.Context, id int) (warehouse domain.Warehouse, err error)
+	CreateWarehouse(ctx context.Context, req domain.Warehouse) (warehouse domain.Warehouse, err error)
+	UpdateWarehouse(ctx context.Context, req domain.Warehouse) (err error)
+	GetProductWarehouseStock(ctx context.Context, productID int) (stock []domain.WarehouseStock, err error)
+	TransferStock(ctx context.Context, transfer domain.StockTransfer, actor string) (err error)
+}
+
+type warehouseRepository struct {
+	db *sql.DB
+}
+
+func NewWarehouseRepository(db *sql.DB) WarehouseRepository {
+	return &warehouseRepository{db}
+}
+
+const warehouseColumns = `id, code, name, priority, latitude, longitude, active`
+
+// GetWarehouses returns every warehouse, active or not, ordered by ID.
+func (r *warehouseRepository) GetWarehouses(ctx context.Context) (warehouses []domain.Warehouse, err error) {
+	return getWarehouses(ctx, r.db)
+}
+
+func (r *warehouseRepository) GetWarehouseByID(ctx context.Context, id int) (warehouse domain.Warehouse, err error) {
+	query := `SELECT ` + warehouseColumns + ` FROM warehouses WHERE id = ?`
+	return scanWarehouse(r.db.QueryRowContext(ctx, query, id))
+}
+
+// CreateWarehouse adds a warehouse. A code that is already taken is reported as ErrInvalidWarehouse.
+func (r *warehouseRepository) CreateWarehouse(ctx context.Context, req domain.Warehouse) (warehouse domain.Warehouse, err error) {
+	query := `INSERT INTO warehouses (code, name, priority, latitude, longitude, active) VALUES (?, ?, ?, ?, ?, ?)`
+	res, err := r.db.ExecContext(ctx, query, req.Code, req.Name, req.Priority, nullFloat(req.Latitude), nullFloat(req.Longitude), req.Active)
+	if err != nil {
+		return warehouse, warehouseWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return warehouse, err
+	}
+
+	warehouse = req
+	warehouse.ID = int(id)
+	return warehouse, nil
+}
+
+func (r *warehouseRepository) UpdateWarehouse(ctx context.Context, req domain.Warehouse) (err error) {
+	query := `UPDATE warehouses SET code = ?, name = ?, priority = ?, latitude = ?, longitude = ?, active = ? WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, req.Code, req.Name, req.Priority, nullFloat(req.Latitude), nullFloat(req.Longitude), req.Active, req.ID)
+	return warehouseWriteError(err)
+}
+
+// GetProductWarehouseStock returns the stock a product and its variants hold in each warehouse.
+func (r *warehouseRepository) GetProductWarehouseStock(ctx context.Context, productID int) (stock []domain.WarehouseStock, err error) {
+	query := `SELECT ws.warehouse_id, w.code, COALESCE(v.sku, ''), ws.stock
+		FROM warehouse_stock ws
+		JOIN warehouses w ON w.id = ws.warehouse_id
+		LEFT JOIN product_variants v ON v.id = ws.variant_id
+		WHERE ws.product_id = ?
+		ORDER BY ws.variant_id, w.priority, w.id`
+	rows, err := r.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var level domain.WarehouseStock
+		if err = rows.Scan(&level.WarehouseID, &level.WarehouseCode, &level.SKU, &level.Stock); err != nil {
+			return nil, err
+		}
+		stock = append(stock, level)
+	}
+
+	return stock, rows.Err()
+}
+
+// TransferStock moves stock between two warehouses in one transaction, failing with ErrInsufficientStock
+// when the source warehouse does not hold enough. Locks follow the order of applyStockChanges; both sides
+// are recorded in the ledger on behalf of actor.
+func (r *warehouseRepository) TransferStock(ctx context.Context, transfer domain.StockTransfer, actor string) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = transferStock(ctx, tx, transfer, actor)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func transferStock(ctx context.Context, tx *sql.Tx, transfer domain.StockTransfer, actor string) (err error) {
+	for _, id := range []int{transfer.FromWarehouseID, transfer.ToWarehouseID} {
+		var exists int
+		err = tx.QueryRowContext(ctx, `SELECT 1 FROM warehouses WHERE id = ?`, id).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("warehouse %d: %w", id, domain.ErrWarehouseNotFound)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	key := stockKey{productID: transfer.ProductID}
+	if transfer.SKU != "" {
+		variants, err := lockVariantStock(ctx, tx, []string{transfer.SKU})
+		if err != nil {
+			return err
+		}
+
+		variant, ok := variants[transfer.SKU]
+		if !ok || variant.productID != transfer.ProductID {
+			return fmt.Errorf("sku %s of product %d: %w", transfer.SKU, transfer.ProductID, domain.ErrVariantNotFound)
+		}
+		key.variantID = variant.id
+	} else {
+		products, err := lockProductStock(ctx, tx, []int{transfer.ProductID})
+		if err != nil {
+			return err
+		}
+		if _, ok := products[transfer.ProductID]; !ok {
+			return domain.ErrProductNotFound
+		}
+	}
+
+	levels, err := lockWarehouseStock(ctx, tx, []stockKey{key})
+	if err != nil {
+		return err
+	}
+
+	available := levels[key][transfer.FromWarehouseID]
+	if available < transfer.Quantity {
+		return &domain.InsufficientStockError{Shortfalls: []domain.StockShortfall{{
+			ProductID: transfer.ProductID, SKU: transfer.SKU, Requested: transfer.Quantity, Available: available,
+		}}}
+	}
+
+	query := `INSERT INTO warehouse_stock (warehouse_id, product_id, variant_id, stock) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE stock = stock + VALUES(stock)`
+	for _, move := range []struct{ warehouseID, delta int }{
+		{transfer.FromWarehouseID, -transfer.Quantity},
+		{transfer.ToWarehouseID, transfer.Quantity},
+	} {
+		_, err = tx.ExecContext(ctx, query, move.warehouseID, key.productID, key.variantID, move.delta)
+		if err != nil {
+			return err
+		}
+	}
+
+	change := domain.StockChange{ProductID: transfer.ProductID, SKU: transfer.SKU}
+	source := domain.MovementSource{Reason: domain.MovementTransfer, Actor: actor, Note: transfer.Note}
+	return recordMovements(ctx, tx, []ledgerEntry{
+		newLedgerEntry(change, key, transfer.FromWarehouseID, -transfer.Quantity, source),
+		newLedgerEntry(change, key, transfer.ToWarehouseID, transfer.Quantity, source),
+	})
+}
+
+// getWarehouses reads every warehouse, within a transaction or not.
+func getWarehouses(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}) (warehouses []domain.Warehouse, err error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+warehouseColumns+` FROM warehouses ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		warehouse, err := scanWarehouse(rows)
+		if err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+
+	return warehouses, rows.Err()
+}
+
+func warehouseWriteError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+		return fmt.Errorf("%w: code is already used by another warehouse", domain.ErrInvalidWarehouse)
+	}
+	return err
+}
+
+func scanWarehouse(row interface{ Scan(dest ...any) error }) (warehouse domain.Warehouse, err error) {
+	var latitude, longitude sql.NullFloat64
+	err = row.Scan(&warehouse.ID, &warehouse.Code, &warehouse.Name, &warehouse.Priority, &latitude, &longitude, &warehouse.Active)
+	if err != nil {
+		return warehouse, err
+	}
+
+	if latitude.Valid && longitude.Valid {
+		warehouse.Latitude = &latitude.Float64
+		warehouse.Longitude = &longitude.Float64
+	}
+	return warehouse, nil
+}
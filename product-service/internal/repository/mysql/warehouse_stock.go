@@ -0,0 +1,335 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"product-service/domain"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stockKey identifies the stock of a product, or of one of its variants when variantID is set.
+type stockKey struct {
+	productID int
+	variantID int
+}
+
+type warehouseStockKey struct {
+	stockKey
+	warehouseID int
+}
+
+// applyWarehouseStock spreads the changes over the warehouses: reservations are taken following policy,
+// releases go to the warehouse of the change or, without one, to the first warehouse by priority holding
+// the product. The warehouse rows are locked in product, variant and warehouse order and every movement
+// is recorded in the inventory ledger.
+func applyWarehouseStock(ctx context.Context, tx *sql.Tx, changes []domain.StockChange, variantIDs map[string]int, policy domain.AllocationPolicy, source domain.MovementSource) (allocations [][]domain.StockAllocation, err error) {
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	warehouses, err := getWarehouses(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]stockKey, len(changes))
+	for i, change := range changes {
+		keys[i] = stockKey{productID: change.ProductID, variantID: variantIDs[change.SKU]}
+	}
+
+	levels, err := lockWarehouseStock(ctx, tx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work through the changes in lock order so the allocation does not depend on the order of a request
+	order := make([]int, len(changes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := keys[order[i]], keys[order[j]]
+		if a.productID != b.productID {
+			return a.productID < b.productID
+		}
+		return a.variantID < b.variantID
+	})
+
+	allocations = make([][]domain.StockAllocation, len(changes))
+	deltas := map[warehouseStockKey]int{}
+	var entries []ledgerEntry
+	var shortfalls []domain.StockShortfall
+	for _, i := range order {
+		change, key := changes[i], keys[i]
+		if levels[key] == nil {
+			levels[key] = map[int]int{}
+		}
+
+		if change.Delta >= 0 {
+			warehouseID := change.WarehouseID
+			if warehouseID == 0 {
+				warehouseID, err = releaseWarehouse(warehouses, levels[key])
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			levels[key][warehouseID] += change.Delta
+			deltas[warehouseStockKey{key, warehouseID}] += change.Delta
+			entries = append(entries, newLedgerEntry(change, key, warehouseID, change.Delta, source))
+			continue
+		}
+
+		changePolicy := policy
+		if change.WarehouseID != 0 {
+			changePolicy.WarehouseID = change.WarehouseID
+		}
+
+		taken, available := allocateStock(changePolicy, changePolicy.Candidates(warehouses), levels[key], -change.Delta)
+		if taken == nil {
+			shortfalls = append(shortfalls, domain.StockShortfall{ProductID: change.ProductID, SKU: change.SKU, Requested: -change.Delta, Available: available})
+			continue
+		}
+
+		for _, warehouse := range warehouses {
+			quantity, ok := taken[warehouse.ID]
+			if !ok {
+				continue
+			}
+
+			levels[key][warehouse.ID] -= quantity
+			deltas[warehouseStockKey{key, warehouse.ID}] -= quantity
+			entries = append(entries, newLedgerEntry(change, key, warehouse.ID, -quantity, source))
+			allocations[i] = append(allocations[i], domain.StockAllocation{WarehouseID: warehouse.ID, ProductID: change.ProductID, SKU: change.SKU, Quantity: quantity})
+		}
+	}
+
+	if len(shortfalls) > 0 {
+		return nil, &domain.InsufficientStockError{Shortfalls: shortfalls}
+	}
+
+	writes := make([]warehouseStockKey, 0, len(deltas))
+	for key := range deltas {
+		writes = append(writes, key)
+	}
+	sort.Slice(writes, func(i, j int) bool {
+		a, b := writes[i], writes[j]
+		if a.productID != b.productID {
+			return a.productID < b.productID
+		}
+		if a.variantID != b.variantID {
+			return a.variantID < b.variantID
+		}
+		return a.warehouseID < b.warehouseID
+	})
+
+	query := `INSERT INTO warehouse_stock (warehouse_id, product_id, variant_id, stock) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE stock = stock + VALUES(stock)`
+	for _, key := range writes {
+		if deltas[key] == 0 {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, query, key.warehouseID, key.productID, key.variantID, deltas[key])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = recordMovements(ctx, tx, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return allocations, nil
+}
+
+// allocateStock picks the stock to take from the candidate warehouses, in their order. It returns nil and
+// the most that could have been taken when the quantity cannot be met: the stock of the best stocked
+// warehouse for single-warehouse policies, the stock of all candidates for split.
+func allocateStock(policy domain.AllocationPolicy, candidates []domain.Warehouse, levels map[int]int, quantity int) (taken map[int]int, available int) {
+	if policy.Single() {
+		for _, warehouse := range candidates {
+			if levels[warehouse.ID] >= quantity {
+				return map[int]int{warehouse.ID: quantity}, levels[warehouse.ID]
+			}
+			if levels[warehouse.ID] > available {
+				available = levels[warehouse.ID]
+			}
+		}
+		return nil, available
+	}
+
+	taken = map[int]int{}
+	remaining := quantity
+	for _, warehouse := range candidates {
+		stock := levels[warehouse.ID]
+		if stock <= 0 {
+			continue
+		}
+		available += stock
+
+		if remaining > 0 {
+			take := stock
+			if take > remaining {
+				take = remaining
+			}
+			taken[warehouse.ID] = take
+			remaining -= take
+		}
+	}
+
+	if remaining > 0 {
+		return nil, available
+	}
+	return taken, available
+}
+
+// releaseWarehouse picks the warehouse unattributed stock goes back to: the first active warehouse by
+// priority already holding the product, or else the first active warehouse.
+func releaseWarehouse(warehouses []domain.Warehouse, levels map[int]int) (warehouseID int, err error) {
+	candidates := domain.AllocationPolicy{}.Candidates(warehouses)
+	if len(candidates) == 0 {
+		return 0, domain.ErrNoWarehouse
+	}
+
+	for _, warehouse := range candidates {
+		if _, ok := levels[warehouse.ID]; ok {
+			return warehouse.ID, nil
+		}
+	}
+	return candidates[0].ID, nil
+}
+
+// lockWarehouseStock locks the warehouse rows of the products and variants and returns their stock by warehouse.
+func lockWarehouseStock(ctx context.Context, tx *sql.Tx, keys []stockKey) (levels map[stockKey]map[int]int, err error) {
+	seen := map[stockKey]bool{}
+	var args []interface{}
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		args = append(args, key.productID, key.variantID)
+	}
+
+	query := `SELECT warehouse_id, product_id, variant_id, stock FROM warehouse_stock
+		WHERE (product_id, variant_id) IN ((?, ?)` + strings.Repeat(", (?, ?)", len(seen)-1) + `)
+		ORDER BY product_id, variant_id, warehouse_id FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels = map[stockKey]map[int]int{}
+	for rows.Next() {
+		var warehouseID, stock int
+		var key stockKey
+		if err = rows.Scan(&warehouseID, &key.productID, &key.variantID, &stock); err != nil {
+			return nil, err
+		}
+
+		if levels[key] == nil {
+			levels[key] = map[int]int{}
+		}
+		levels[key][warehouseID] = stock
+	}
+
+	return levels, rows.Err()
+}
+
+// seedWarehouseStock puts the initial stock of a new product or variant in the first active warehouse by
+// priority and records it in the ledger as a restock.
+func seedWarehouseStock(ctx context.Context, tx *sql.Tx, productID, variantID int, sku string, stock int, actor string) (err error) {
+	warehouses, err := getWarehouses(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	candidates := domain.AllocationPolicy{}.Candidates(warehouses)
+	if len(candidates) == 0 {
+		if stock > 0 {
+			return domain.ErrNoWarehouse
+		}
+		return nil
+	}
+
+	query := `INSERT INTO warehouse_stock (warehouse_id, product_id, variant_id, stock) VALUES (?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, candidates[0].ID, productID, variantID, stock)
+	if err != nil || stock == 0 {
+		return err
+	}
+
+	change := domain.StockChange{ProductID: productID, SKU: sku, Delta: stock}
+	source := domain.MovementSource{Reason: domain.MovementRestock, Actor: actor}
+	return recordMovements(ctx, tx, []ledgerEntry{newLedgerEntry(change, stockKey{productID, variantID}, candidates[0].ID, stock, source)})
+}
+
+// recordAllocations remembers where the stock reserved under reference was taken from.
+func recordAllocations(ctx context.Context, tx *sql.Tx, reference string, allocations []domain.StockAllocation) (err error) {
+	query := `INSERT INTO stock_allocations (reference, warehouse_id, product_id, sku, quantity, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	now := time.Now().UTC()
+	for _, allocation := range allocations {
+		_, err = tx.ExecContext(ctx, query, reference, allocation.WarehouseID, allocation.ProductID, allocation.SKU, allocation.Quantity, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allocatedChanges splits releases of the stock reserved under reference over the warehouses it was taken
+// from. Stock beyond the recorded allocations, e.g. reserved before warehouses existed, keeps no warehouse.
+func allocatedChanges(ctx context.Context, tx *sql.Tx, reference string, changes []domain.StockChange) (split []domain.StockChange, err error) {
+	query := `SELECT warehouse_id, product_id, sku, quantity FROM stock_allocations WHERE reference = ? ORDER BY id`
+	rows, err := tx.QueryContext(ctx, query, reference)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocations []domain.StockAllocation
+	for rows.Next() {
+		var allocation domain.StockAllocation
+		if err = rows.Scan(&allocation.WarehouseID, &allocation.ProductID, &allocation.SKU, &allocation.Quantity); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, allocation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		remaining := change.Delta
+		for i := range allocations {
+			allocation := &allocations[i]
+			if remaining <= 0 || allocation.ProductID != change.ProductID || allocation.SKU != change.SKU || allocation.Quantity == 0 {
+				continue
+			}
+
+			quantity := allocation.Quantity
+			if quantity > remaining {
+				quantity = remaining
+			}
+			allocation.Quantity -= quantity
+			remaining -= quantity
+			split = append(split, domain.StockChange{ProductID: change.ProductID, SKU: change.SKU, Delta: quantity, WarehouseID: allocation.WarehouseID, Reference: change.Reference})
+		}
+
+		if remaining > 0 {
+			split = append(split, domain.StockChange{ProductID: change.ProductID, SKU: change.SKU, Delta: remaining, Reference: change.Reference})
+		}
+	}
+
+	return split, nil
+}
+
+// reservationAllocationRef is the reference allocations of a stock reservation are recorded under.
+func reservationAllocationRef(id int64) string {
+	return fmt.Sprintf("reservation:%d", id)
+}
@@ -2,57 +2,50 @@ package redis
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
+	"product-service/config"
 	"product-service/domain"
+	"shared/rediscache"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+const productKeyPrefix = "product"
+
 type ProductCache interface {
-	GetProductByID(ctx context.Context, productID int) (product domain.Product, err error)
+	FetchProduct(ctx context.Context, productID int, load func(ctx context.Context) (domain.Product, error)) (product domain.Product, err error)
 	SetProduct(ctx context.Context, product domain.Product, expiration time.Duration) (err error)
+	DeleteProduct(ctx context.Context, productID int) (err error)
 }
 
 type productCache struct {
-	rdb *redis.Client
-}
-
-func NewProductCache(rdb *redis.Client) ProductCache {
-	return &productCache{rdb}
+	cache *rediscache.Cache
+	ttl   time.Duration
 }
 
-func (r *productCache) GetProductByID(ctx context.Context, productID int) (product domain.Product, err error) {
-	key := fmt.Sprintf("product:%d", productID)
-	productCache, err := r.rdb.Get(ctx, key).Result()
-	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return product, nil
-		} else {
-			return product, err
-		}
-	}
-
-	err = json.Unmarshal([]byte(productCache), &product)
-	if err != nil {
-		return product, err
+func NewProductCache(rdb *redis.Client, cfg config.CacheConfig) ProductCache {
+	return &productCache{
+		cache: rediscache.NewCache(rdb, cfg.Jitter),
+		ttl:   cfg.ProductTTL,
 	}
+}
 
-	return product, nil
+// FetchProduct reads the product through the cache, loading it on a miss.
+func (r *productCache) FetchProduct(ctx context.Context, productID int, load func(ctx context.Context) (domain.Product, error)) (product domain.Product, err error) {
+	err = r.cache.Fetch(ctx, rediscache.Key(productKeyPrefix, productID), r.ttl, &product, func(ctx context.Context) (interface{}, error) {
+		return load(ctx)
+	})
+	return product, err
 }
 
+// SetProduct caches the product for expiration, or for the configured TTL when expiration is zero.
 func (r *productCache) SetProduct(ctx context.Context, product domain.Product, expiration time.Duration) (err error) {
-	productByte, err := json.Marshal(product)
-	if err != nil {
-		return err
+	if expiration == 0 {
+		expiration = r.ttl
 	}
+	return r.cache.Set(ctx, rediscache.Key(productKeyPrefix, product.ID), product, expiration)
+}
 
-	key := fmt.Sprintf("product:%d", product.ID)
-	err = r.rdb.Set(ctx, key, productByte, 0).Err()
-	if err != nil {
-		return err
-	}
-	return nil
+func (r *productCache) DeleteProduct(ctx context.Context, productID int) (err error) {
+	return r.cache.Delete(ctx, rediscache.Key(productKeyPrefix, productID))
 }
@@ -0,0 +1,48 @@
+package sweeper
+
+import (
+	"context"
+	"time"
+
+	"product-service/internal/usecase"
+
+	"github.com/rs/zerolog/log"
+)
+
+// InventoryTopic receives stock level events such as inventory.low_stock
+const InventoryTopic = "inventory-topic"
+
+// InventoryEventPublisher periodically publishes the inventory events queued by stock changes.
+type InventoryEventPublisher struct {
+	inventoryUsecase usecase.InventoryUsecase
+	interval         time.Duration
+}
+
+func NewInventoryEventPublisher(inventoryUsecase usecase.InventoryUsecase, interval time.Duration) *InventoryEventPublisher {
+	return &InventoryEventPublisher{
+		inventoryUsecase: inventoryUsecase,
+		interval:         interval,
+	}
+}
+
+// Start publishes right away and then on every interval until the context is cancelled.
+func (s *InventoryEventPublisher) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		published, err := s.inventoryUsecase.PublishInventoryEvents(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Error publishing inventory events")
+		} else if published > 0 {
+			log.Info().Msgf("Published %d inventory events", published)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Stopping inventory event publisher")
+			return
+		case <-ticker.C:
+		}
+	}
+}
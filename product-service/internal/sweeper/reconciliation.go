@@ -0,0 +1,45 @@
+package sweeper
+
+import (
+	"context"
+	"time"
+
+	"product-service/internal/usecase"
+
+	"github.com/rs/zerolog/log"
+)
+
+// InventoryReconciler periodically checks the stock columns against the inventory ledger.
+type InventoryReconciler struct {
+	inventoryUsecase usecase.InventoryUsecase
+	interval         time.Duration
+}
+
+func NewInventoryReconciler(inventoryUsecase usecase.InventoryUsecase, interval time.Duration) *InventoryReconciler {
+	return &InventoryReconciler{
+		inventoryUsecase: inventoryUsecase,
+		interval:         interval,
+	}
+}
+
+// Start reconciles right away and then on every interval until the context is cancelled.
+func (s *InventoryReconciler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		discrepancies, err := s.inventoryUsecase.Reconcile(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Error reconciling inventory")
+		} else if len(discrepancies) > 0 {
+			log.Warn().Msgf("Found %d stock discrepancies with the inventory ledger", len(discrepancies))
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Stopping inventory reconciler")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package sweeper
+
+import (
+	"context"
+	"time"
+
+	"product-service/internal/usecase"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ReservationTopic receives reservation events such as reservation.expired
+const ReservationTopic = "reservation-topic"
+
+// ReservationSweeper periodically releases expired stock reservations and announces them.
+type ReservationSweeper struct {
+	reservationUsecase usecase.ReservationUsecase
+	interval           time.Duration
+}
+
+func NewReservationSweeper(reservationUsecase usecase.ReservationUsecase, interval time.Duration) *ReservationSweeper {
+	return &ReservationSweeper{
+		reservationUsecase: reservationUsecase,
+		interval:           interval,
+	}
+}
+
+// Start sweeps right away and then on every interval until the context is cancelled.
+func (s *ReservationSweeper) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := s.reservationUsecase.ExpireReservations(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Error sweeping expired reservations")
+		} else if expired > 0 {
+			log.Info().Msgf("Released %d expired reservations", expired)
+		}
+
+		// Events not published by an earlier sweep are retried here as well
+		_, err = s.reservationUsecase.PublishExpiredReservations(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Error publishing expired reservations")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Stopping reservation sweeper")
+			return
+		case <-ticker.C:
+		}
+	}
+}
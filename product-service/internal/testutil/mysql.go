@@ -0,0 +1,82 @@
+// Package testutil sets up the databases integration tests run against.
+package testutil
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL returns a connection to a new database with every migration applied, dropped when the test ends.
+// The server comes from TEST_MYSQL_DSN, e.g. root:root@tcp(localhost:3306)/, and the test is skipped without it.
+func MySQL(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set")
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("invalid TEST_MYSQL_DSN: %v", err)
+	}
+	cfg.ParseTime = true
+	cfg.MultiStatements = true // migrations hold several statements
+	cfg.DBName = ""
+
+	server, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("connecting to MySQL: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	name := fmt.Sprintf("product_test_%d", time.Now().UnixNano())
+	_, err = server.Exec("CREATE DATABASE `" + name + "`")
+	if err != nil {
+		t.Fatalf("creating database %s: %v", name, err)
+	}
+	t.Cleanup(func() { server.Exec("DROP DATABASE `" + name + "`") })
+
+	cfg.DBName = name
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("connecting to database %s: %v", name, err)
+	}
+	db.SetMaxOpenConns(50)
+	t.Cleanup(func() { db.Close() })
+
+	migrate(t, db)
+	return db
+}
+
+// migrate applies the migrations of product-service in order.
+func migrate(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	_, file, _, _ := runtime.Caller(0)
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(file), "..", "..", "migration", "0*"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("finding migrations: %v", err)
+	}
+	sort.Strings(files)
+
+	for _, path := range files {
+		query, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading migration %s: %v", path, err)
+		}
+
+		_, err = db.Exec(string(query))
+		if err != nil {
+			t.Fatalf("applying migration %s: %v", filepath.Base(path), err)
+		}
+	}
+}
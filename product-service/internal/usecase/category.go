@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"product-service/domain"
+	repo "product-service/internal/repository/mysql"
+
+	"github.com/rs/zerolog/log"
+)
+
+type CategoryUsecase interface {
+	GetCategoryTree(ctx context.Context) (tree []domain.Category, err error)
+	GetCategory(ctx context.Context, id int) (category domain.Category, err error)
+	CreateCategory(ctx context.Context, req domain.Category) (category domain.Category, err error)
+	UpdateCategory(ctx context.Context, req domain.Category) (category domain.Category, err error)
+	DeleteCategory(ctx context.Context, id int) (err error)
+}
+
+type categoryUsecase struct {
+	repo repo.CategoryRepository
+}
+
+func NewCategoryUsecase(repo repo.CategoryRepository) CategoryUsecase {
+	return &categoryUsecase{repo: repo}
+}
+
+// GetCategoryTree returns every category nested under its parent.
+func (u *categoryUsecase) GetCategoryTree(ctx context.Context) (tree []domain.Category, err error) {
+	categories, err := u.repo.GetCategories(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting categories")
+		return nil, err
+	}
+
+	return domain.BuildCategoryTree(categories), nil
+}
+
+// GetCategory gets a category with its subcategories.
+func (u *categoryUsecase) GetCategory(ctx context.Context, id int) (category domain.Category, err error) {
+	categories, err := u.repo.GetCategories(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting categories")
+		return category, err
+	}
+
+	inSubtree := map[int]bool{}
+	for _, descendantID := range descendantCategoryIDs(categories, []int{id}) {
+		inSubtree[descendantID] = true
+	}
+
+	var subtree []domain.Category
+	for _, candidate := range categories {
+		if inSubtree[candidate.ID] {
+			subtree = append(subtree, candidate)
+		}
+	}
+
+	for _, root := range domain.BuildCategoryTree(subtree) {
+		if root.ID == id {
+			return root, nil
+		}
+	}
+	return category, domain.ErrCategoryNotFound
+}
+
+func (u *categoryUsecase) CreateCategory(ctx context.Context, req domain.Category) (category domain.Category, err error) {
+	req.Name = strings.TrimSpace(req.Name)
+	err = req.Validate()
+	if err != nil {
+		return category, err
+	}
+
+	category, err = u.repo.CreateCategory(ctx, req)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidCategory) {
+			log.Error().Err(err).Msg("Error creating category")
+		}
+		return category, err
+	}
+
+	return category, nil
+}
+
+// UpdateCategory renames or moves a category. A category cannot be moved below itself.
+func (u *categoryUsecase) UpdateCategory(ctx context.Context, req domain.Category) (category domain.Category, err error) {
+	req.Name = strings.TrimSpace(req.Name)
+	err = req.Validate()
+	if err != nil {
+		return category, err
+	}
+
+	categories, err := u.repo.GetCategories(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting categories")
+		return category, err
+	}
+
+	found := false
+	for _, existing := range categories {
+		found = found || existing.ID == req.ID
+	}
+	if !found {
+		return category, domain.ErrCategoryNotFound
+	}
+
+	if req.ParentID != nil {
+		for _, id := range descendantCategoryIDs(categories, []int{req.ID}) {
+			if id == *req.ParentID {
+				return category, fmt.Errorf("%w: a category cannot be moved below itself", domain.ErrInvalidCategory)
+			}
+		}
+	}
+
+	err = u.repo.UpdateCategory(ctx, req)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidCategory) {
+			log.Error().Err(err).Msgf("Error updating category %d", req.ID)
+		}
+		return category, err
+	}
+
+	return req, nil
+}
+
+// DeleteCategory deletes a category without subcategories or products.
+func (u *categoryUsecase) DeleteCategory(ctx context.Context, id int) (err error) {
+	err = u.repo.DeleteCategory(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrCategoryNotFound
+		}
+		if !errors.Is(err, domain.ErrCategoryInUse) {
+			log.Error().Err(err).Msgf("Error deleting category %d", id)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// categoryDescendants expands the categories with all of their subcategories.
+func (u *productUsecase) categoryDescendants(ctx context.Context, ids []int) (expanded []int, err error) {
+	categories, err := u.categoryRepo.GetCategories(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting categories")
+		return nil, err
+	}
+
+	return descendantCategoryIDs(categories, ids), nil
+}
+
+// descendantCategoryIDs returns the IDs of the roots and every category below them.
+func descendantCategoryIDs(categories []domain.Category, roots []int) []int {
+	children := map[int][]int{}
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	seen := map[int]bool{}
+	var ids []int
+	queue := append([]int(nil), roots...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		queue = append(queue, children[id]...)
+	}
+	return ids
+}
@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"product-service/domain"
+	repo "product-service/internal/repository/mysql"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+var ErrDeadLetterAlreadyRedriven = errors.New("dead letter already redriven")
+
+type DeadLetterUsecase interface {
+	RecordDeadLetter(ctx context.Context, deadLetter domain.DeadLetter) (err error)
+	GetDeadLetters(ctx context.Context, status string, limit, offset int) (deadLetters []domain.DeadLetter, err error)
+	RedriveDeadLetter(ctx context.Context, id int) (deadLetter domain.DeadLetter, err error)
+}
+
+type deadLetterUsecase struct {
+	repo        repo.DeadLetterRepository
+	orderWriter *kafka.Writer
+}
+
+func NewDeadLetterUsecase(repo repo.DeadLetterRepository, orderWriter *kafka.Writer) DeadLetterUsecase {
+	return &deadLetterUsecase{
+		repo:        repo,
+		orderWriter: orderWriter,
+	}
+}
+
+// RecordDeadLetter stores a message consumed from the dead-letter topic.
+func (u *deadLetterUsecase) RecordDeadLetter(ctx context.Context, deadLetter domain.DeadLetter) (err error) {
+	err = u.repo.CreateDeadLetter(ctx, deadLetter)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error storing dead letter from %s offset %d", deadLetter.OriginalTopic, deadLetter.OriginalOffset)
+		return err
+	}
+
+	return nil
+}
+
+// GetDeadLetters lists stored dead letters, newest first, optionally filtered by status.
+func (u *deadLetterUsecase) GetDeadLetters(ctx context.Context, status string, limit, offset int) (deadLetters []domain.DeadLetter, err error) {
+	deadLetters, err = u.repo.GetDeadLetters(ctx, status, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting dead letters")
+		return nil, err
+	}
+
+	return deadLetters, nil
+}
+
+// RedriveDeadLetter publishes the original message back onto the main topic and marks it redriven.
+func (u *deadLetterUsecase) RedriveDeadLetter(ctx context.Context, id int) (deadLetter domain.DeadLetter, err error) {
+	deadLetter, err = u.repo.GetDeadLetterByID(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error getting dead letter %d", id)
+		return deadLetter, err
+	}
+
+	if deadLetter.Status == domain.DeadLetterStatusRedriven {
+		return deadLetter, ErrDeadLetterAlreadyRedriven
+	}
+
+	headers := make([]kafka.Header, 0, len(deadLetter.Headers))
+	for key, value := range deadLetter.Headers {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+
+	err = u.orderWriter.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(deadLetter.Key),
+		Value:   []byte(deadLetter.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		log.Error().Err(err).Msgf("Error redriving dead letter %d", id)
+		return deadLetter, err
+	}
+
+	err = u.repo.MarkDeadLetterRedriven(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error marking dead letter %d as redriven", id)
+		return deadLetter, err
+	}
+
+	deadLetter.Status = domain.DeadLetterStatusRedriven
+	return deadLetter, nil
+}
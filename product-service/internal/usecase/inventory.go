@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"product-service/domain"
+	repo "product-service/internal/repository/mysql"
+	cache "product-service/internal/repository/redis"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+const inventoryEventBatch = 100
+
+type InventoryUsecase interface {
+	AdjustStock(ctx context.Context, adjustment domain.StockAdjustment) (applied bool, err error)
+	GetMovements(ctx context.Context, filter domain.MovementFilter) (movements []domain.InventoryMovement, total int, err error)
+	Reconcile(ctx context.Context) (discrepancies []domain.StockDiscrepancy, err error)
+	PublishInventoryEvents(ctx context.Context) (published int, err error)
+}
+
+type inventoryUsecase struct {
+	repo            repo.InventoryRepository
+	cache           cache.ProductCache
+	inventoryWriter *kafka.Writer
+}
+
+func NewInventoryUsecase(repo repo.InventoryRepository, cache cache.ProductCache, inventoryWriter *kafka.Writer) InventoryUsecase {
+	return &inventoryUsecase{repo: repo, cache: cache, inventoryWriter: inventoryWriter}
+}
+
+// AdjustStock posts a manual stock adjustment, e.g. a restock or a correction after a count.
+// Repeating an adjustment with the same reference applies it only once.
+func (u *inventoryUsecase) AdjustStock(ctx context.Context, adjustment domain.StockAdjustment) (applied bool, err error) {
+	err = adjustment.Validate()
+	if err != nil {
+		return false, err
+	}
+
+	applied, err = u.repo.AdjustStock(ctx, adjustment, stockActor(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInsufficientStock):
+			log.Warn().Msg(err.Error())
+		case errors.Is(err, domain.ErrVariantNotFound), errors.Is(err, domain.ErrWarehouseNotFound):
+		default:
+			log.Error().Err(err).Msgf("Error adjusting stock of product %d", adjustment.ProductID)
+		}
+		return false, err
+	}
+
+	if applied {
+		err = u.cache.DeleteProduct(ctx, adjustment.ProductID)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error invalidating product %d in cache", adjustment.ProductID)
+		}
+	}
+	return applied, nil
+}
+
+func (u *inventoryUsecase) GetMovements(ctx context.Context, filter domain.MovementFilter) (movements []domain.InventoryMovement, total int, err error) {
+	movements, total, err = u.repo.GetMovements(ctx, filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting inventory movements")
+		return nil, 0, err
+	}
+
+	if movements == nil {
+		movements = []domain.InventoryMovement{}
+	}
+	return movements, total, nil
+}
+
+// Reconcile checks the stock columns against the ledger and logs every discrepancy found.
+func (u *inventoryUsecase) Reconcile(ctx context.Context) (discrepancies []domain.StockDiscrepancy, err error) {
+	discrepancies, err = u.repo.GetDiscrepancies(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error reconciling stock with the inventory ledger")
+		return nil, err
+	}
+
+	for _, discrepancy := range discrepancies {
+		log.Warn().Msgf("Stock of product %d sku %q warehouse %d is %d but the ledger sums to %d",
+			discrepancy.ProductID, discrepancy.SKU, discrepancy.WarehouseID, discrepancy.Stock, discrepancy.LedgerStock)
+	}
+
+	if discrepancies == nil {
+		discrepancies = []domain.StockDiscrepancy{}
+	}
+	return discrepancies, nil
+}
+
+// PublishInventoryEvents publishes the queued low-stock, out-of-stock and back-in-stock events in order.
+// An event is marked published only after Kafka accepted it, so it may be delivered more than once.
+func (u *inventoryUsecase) PublishInventoryEvents(ctx context.Context) (published int, err error) {
+	events, err := u.repo.GetUnpublishedInventoryEvents(ctx, inventoryEventBatch)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting inventory events")
+		return 0, err
+	}
+
+	for _, event := range events {
+		// Derived from the queued row so a republished event keeps its ID
+		event.EventID = fmt.Sprintf("%s:%d", event.Type, event.ID)
+		event.OccurredAt = event.OccurredAt.UTC()
+
+		value, err := json.Marshal(event)
+		if err != nil {
+			return published, err
+		}
+
+		// Events of one product share a key so they stay in order
+		err = u.inventoryWriter.WriteMessages(ctx, kafka.Message{
+			Key:   []byte(strconv.Itoa(event.ProductID)),
+			Value: value,
+			Headers: []kafka.Header{
+				{Key: domain.EventHeaderID, Value: []byte(event.EventID)},
+				{Key: domain.EventHeaderType, Value: []byte(event.Type)},
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msgf("Error publishing inventory event %d", event.ID)
+			return published, err
+		}
+
+		err = u.repo.MarkInventoryEventPublished(ctx, event.ID, time.Now())
+		if err != nil {
+			log.Error().Err(err).Msgf("Error marking inventory event %d as published", event.ID)
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// stockActor names who caused a stock movement: the signed-in user, or the system for background work.
+func stockActor(ctx context.Context) string {
+	if username, ok := ctx.Value(domain.UserNameKey).(string); ok && username != "" {
+		return username
+	}
+	return domain.SystemActor
+}
@@ -2,8 +2,10 @@ package usecase
 
 import (
 	"context"
-	"fmt"
-	"time"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
 
 	"product-service/domain"
 	repo "product-service/internal/repository/mysql"
@@ -12,160 +14,466 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// preWarmConcurrency bounds how many products are loaded at once while warming the cache
+const preWarmConcurrency = 10
+
 type ProductUsecase interface {
-	GetProductStock(ctx context.Context, productID int) (stock int, err error)
-	ReserveProductStock(ctx context.Context, productID int, quantity int) (err error)
-	ReleaseProductStock(ctx context.Context, productID int, quantity int) (err error)
+	GetProduct(ctx context.Context, productID int) (product domain.Product, err error)
+	ListProducts(ctx context.Context, filter domain.ProductFilter) (page domain.ProductPage, err error)
+	SearchProducts(ctx context.Context, search domain.ProductSearch) (page domain.ProductSearchPage, err error)
+	CreateProduct(ctx context.Context, req domain.Product) (product domain.Product, err error)
+	UpdateProduct(ctx context.Context, req domain.Product) (product domain.Product, err error)
+	DeleteProduct(ctx context.Context, productID int) (err error)
+	CreateVariant(ctx context.Context, req domain.Variant) (variant domain.Variant, err error)
+	UpdateVariant(ctx context.Context, req domain.Variant) (variant domain.Variant, err error)
+	DeleteVariant(ctx context.Context, productID, variantID int) (err error)
+	GetProductStock(ctx context.Context, productID int, sku string) (stock domain.ProductStock, err error)
+	ReserveProductStock(ctx context.Context, productID int, sku string, quantity int, policy domain.AllocationPolicy) (err error)
+	ReleaseProductStock(ctx context.Context, productID int, sku string, quantity int) (err error)
+	ReserveOrderStock(ctx context.Context, eventID string, orderID int, items []domain.ProductRequest, policy domain.AllocationPolicy) (applied bool, err error)
+	ReleaseOrderStock(ctx context.Context, eventID string, orderID int, items []domain.ProductRequest) (applied bool, err error)
+	ReleaseReservedStock(ctx context.Context, reserveRef, releaseRef string, items []domain.ProductRequest) (applied bool, err error)
+	ReserveStockBatch(ctx context.Context, items []domain.StockItem, policy domain.AllocationPolicy) (err error)
+	ReleaseStockBatch(ctx context.Context, items []domain.StockItem) (err error)
+	GetStockLevels(ctx context.Context, productIDs []int) (levels []domain.StockLevel, err error)
 	PreWarmCache(ctx context.Context) (err error)
 	PreWarmCacheAsync(ctx context.Context) (err error)
 }
 
 type productUsecase struct {
-	repo  repo.ProductRepository
-	cache cache.ProductCache
+	repo          repo.ProductRepository
+	variantRepo   repo.VariantRepository
+	categoryRepo  repo.CategoryRepository
+	warehouseRepo repo.WarehouseRepository
+	cache         cache.ProductCache
 }
 
-func NewProductUsecase(repo repo.ProductRepository, cache cache.ProductCache) ProductUsecase {
+func NewProductUsecase(repo repo.ProductRepository, variantRepo repo.VariantRepository, categoryRepo repo.CategoryRepository, warehouseRepo repo.WarehouseRepository, cache cache.ProductCache) ProductUsecase {
 	return &productUsecase{
-		repo:  repo,
-		cache: cache,
+		repo:          repo,
+		variantRepo:   variantRepo,
+		categoryRepo:  categoryRepo,
+		warehouseRepo: warehouseRepo,
+		cache:         cache,
 	}
 }
 
-// GetProductStock retrieves the stock for a product.
-func (u *productUsecase) GetProductStock(ctx context.Context, productID int) (stock int, err error) {
-	// Read from cache
-	product, err := u.cache.GetProductByID(ctx, productID)
+// GetProduct gets a product with its variants and warehouse stock, reading through the cache.
+func (u *productUsecase) GetProduct(ctx context.Context, productID int) (product domain.Product, err error) {
+	return u.cache.FetchProduct(ctx, productID, func(ctx context.Context) (domain.Product, error) {
+		return u.loadProduct(ctx, productID)
+	})
+}
+
+// loadProduct loads a product with its variants and warehouse stock, as it is cached.
+func (u *productUsecase) loadProduct(ctx context.Context, productID int) (product domain.Product, err error) {
+	product, err = u.repo.GetProductByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return product, domain.ErrProductNotFound
+		}
+		log.Error().Err(err).Msgf("Error getting product by ID %d", productID)
+		return product, err
+	}
+
+	err = u.loadVariants(ctx, []*domain.Product{&product})
+	if err != nil {
+		return product, err
+	}
+
+	product.Warehouses, err = u.warehouseRepo.GetProductWarehouseStock(ctx, productID)
 	if err != nil {
-		log.Error().Err(err).Msgf("Error getting stock for product %d from cache", productID)
-		return 0, err
+		log.Error().Err(err).Msgf("Error getting warehouse stock of product %d", productID)
+		return product, err
 	}
 
-	if product.ID == 0 {
-		product, err = u.repo.GetProductByID(ctx, productID)
+	return product, nil
+}
+
+// ListProducts lists a page of the catalog. Filtering by a category includes its subcategories.
+func (u *productUsecase) ListProducts(ctx context.Context, filter domain.ProductFilter) (page domain.ProductPage, err error) {
+	if len(filter.CategoryIDs) > 0 {
+		filter.CategoryIDs, err = u.categoryDescendants(ctx, filter.CategoryIDs)
 		if err != nil {
-			log.Error().Err(err).Msgf("Error getting product by ID %d", productID)
-			return 0, err
+			return page, err
 		}
+	}
 
-		// Write to cache
-		err = u.cache.SetProduct(ctx, product, 0)
-		if err != nil {
-			log.Error().Err(err).Msgf("Error setting product %d in cache", productID)
-			return 0, err
+	products, total, err := u.repo.ListProducts(ctx, filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Error listing products")
+		return page, err
+	}
+
+	if products == nil {
+		products = []domain.Product{}
+	}
+
+	err = u.loadVariants(ctx, productPointers(products))
+	if err != nil {
+		return page, err
+	}
+
+	return domain.ProductPage{Products: products, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+// SearchProducts finds products by keywords and filters. Without an explicit sort, keyword searches
+// are sorted by relevance and other searches by ID.
+func (u *productUsecase) SearchProducts(ctx context.Context, search domain.ProductSearch) (page domain.ProductSearchPage, err error) {
+	if search.Sort == "" {
+		search.Sort = domain.ProductSortID
+		if search.Query != "" {
+			search.Sort = domain.ProductSortRelevance
 		}
+	}
 
+	err = search.Validate()
+	if err != nil {
+		return page, err
 	}
 
-	return product.Stock, nil
+	page, err = u.repo.SearchProducts(ctx, search)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error searching products for %q", search.Query)
+		return page, err
+	}
+
+	err = u.loadVariants(ctx, productPointers(page.Products))
+	if err != nil {
+		return page, err
+	}
+
+	return page, nil
 }
 
-// ReserveProductStock reserves stock for an order.
-func (u *productUsecase) ReserveProductStock(ctx context.Context, productID int, quantity int) (err error) {
-	// Get product from cache
-	product, err := u.cache.GetProductByID(ctx, productID)
+// CreateProduct adds a product to the catalog.
+func (u *productUsecase) CreateProduct(ctx context.Context, req domain.Product) (product domain.Product, err error) {
+	req.Name = strings.TrimSpace(req.Name)
+	err = req.Validate()
 	if err != nil {
-		log.Error().Err(err).Msgf("Error getting stock for product %d from cache", productID)
-		return err
+		return product, err
 	}
 
-	if product.ID == 0 {
-		product, err = u.repo.GetProductByID(ctx, productID)
-		if err != nil {
-			log.Error().Err(err).Msgf("Error getting product by ID %d", product.ID)
-			return err
+	product, err = u.repo.CreateProduct(ctx, req, stockActor(ctx))
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidProduct) {
+			log.Error().Err(err).Msg("Error creating product")
 		}
+		return product, err
 	}
 
-	if product.Stock < quantity {
-		log.Warn().Msgf("Product %d out of stock", productID)
-		return fmt.Errorf("product out of stock")
+	u.invalidateProduct(ctx, product.ID)
+	return product, nil
+}
+
+// UpdateProduct updates the catalog fields of a product. Stock is not changed.
+func (u *productUsecase) UpdateProduct(ctx context.Context, req domain.Product) (product domain.Product, err error) {
+	product, err = u.repo.GetProductByID(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return product, domain.ErrProductNotFound
+		}
+		log.Error().Err(err).Msgf("Error getting product by ID %d", req.ID)
+		return product, err
 	}
 
-	product.Stock -= quantity
-	err = u.repo.UpdateProduct(ctx, product)
+	req.Name = strings.TrimSpace(req.Name)
+	req.Stock = product.Stock
+	err = req.Validate()
 	if err != nil {
-		log.Error().Err(err).Msgf("Error updating product %d", productID)
-		return err
+		return product, err
+	}
+
+	err = u.repo.UpdateProduct(ctx, req)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidProduct) {
+			log.Error().Err(err).Msgf("Error updating product %d", req.ID)
+		}
+		return product, err
 	}
 
-	// Write to cache
-	err = u.cache.SetProduct(ctx, product, 0)
+	u.invalidateProduct(ctx, req.ID)
+	req.Variants = nil
+	return req, nil
+}
+
+// DeleteProduct removes a product from the catalog.
+func (u *productUsecase) DeleteProduct(ctx context.Context, productID int) (err error) {
+	err = u.repo.DeleteProduct(ctx, productID)
 	if err != nil {
-		log.Error().Err(err).Msgf("Error setting product %d in cache", productID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrProductNotFound
+		}
+		log.Error().Err(err).Msgf("Error deleting product %d", productID)
 		return err
 	}
 
+	u.invalidateProduct(ctx, productID)
 	return nil
 }
 
-// ReleaseProductStock releases reserved stock when an order is canceled.
-func (u *productUsecase) ReleaseProductStock(ctx context.Context, productID int, quantity int) (err error) {
-	// Get product from cache
-	product, err := u.cache.GetProductByID(ctx, productID)
+// GetProductStock retrieves the total stock for a product, or for one of its variants when sku is set,
+// with the stock held in each warehouse.
+func (u *productUsecase) GetProductStock(ctx context.Context, productID int, sku string) (stock domain.ProductStock, err error) {
+	product, err := u.GetProduct(ctx, productID)
 	if err != nil {
-		log.Error().Err(err).Msgf("Error getting stock for product %d from cache", productID)
+		return stock, err
+	}
+
+	stock = domain.ProductStock{ProductID: productID, SKU: sku, Stock: product.Stock, Warehouses: []domain.WarehouseStock{}}
+	if sku != "" {
+		found := false
+		for _, variant := range product.Variants {
+			if variant.SKU == sku {
+				stock.Stock = variant.Stock
+				found = true
+			}
+		}
+		if !found {
+			return stock, domain.ErrVariantNotFound
+		}
+	}
+
+	for _, level := range product.Warehouses {
+		if level.SKU == sku {
+			stock.Warehouses = append(stock.Warehouses, level)
+		}
+	}
+	return stock, nil
+}
+
+// ReserveProductStock reserves stock of a product or of one of its variants for an order. The stock is
+// checked and decremented under a row lock, so concurrent reservations can never oversell. The stock is
+// taken from the warehouses chosen by policy.
+func (u *productUsecase) ReserveProductStock(ctx context.Context, productID int, sku string, quantity int, policy domain.AllocationPolicy) (err error) {
+	return u.updateProductStock(ctx, domain.StockChange{ProductID: productID, SKU: sku, Delta: -quantity}, quantity, policy)
+}
+
+// ReleaseProductStock releases reserved stock when an order is canceled.
+func (u *productUsecase) ReleaseProductStock(ctx context.Context, productID int, sku string, quantity int) (err error) {
+	return u.updateProductStock(ctx, domain.StockChange{ProductID: productID, SKU: sku, Delta: quantity}, quantity, domain.AllocationPolicy{})
+}
+
+func (u *productUsecase) updateProductStock(ctx context.Context, change domain.StockChange, quantity int, policy domain.AllocationPolicy) (err error) {
+	if quantity <= 0 {
+		return domain.ErrInvalidQuantity
+	}
+	if err = policy.Validate(); err != nil {
 		return err
 	}
 
-	if product.ID == 0 {
-		product, err := u.repo.GetProductByID(ctx, productID)
-		if err != nil {
-			log.Error().Err(err).Msgf("Error getting product by ID %d", product.ID)
+	changes := []domain.StockChange{change}
+	err = u.repo.UpdateStock(ctx, changes, policy, domain.MovementSource{Actor: stockActor(ctx)})
+	if err != nil {
+		if errors.Is(err, domain.ErrInsufficientStock) {
+			log.Warn().Msg(err.Error())
+		} else if !errors.Is(err, domain.ErrVariantNotFound) && !errors.Is(err, domain.ErrNoWarehouse) {
+			log.Error().Err(err).Msgf("Error updating stock of product %d", change.ProductID)
 		}
+		return err
 	}
 
-	product.Stock += quantity
-	err = u.repo.UpdateProduct(ctx, product)
+	// The cached product is dropped only after the commit, the next read loads the new stock
+	u.invalidateProducts(ctx, changes)
+	return nil
+}
+
+// ReserveOrderStock reserves stock for every item of an order event exactly once. orderID, when known,
+// is recorded in the ledger.
+func (u *productUsecase) ReserveOrderStock(ctx context.Context, eventID string, orderID int, items []domain.ProductRequest, policy domain.AllocationPolicy) (applied bool, err error) {
+	if err = policy.Validate(); err != nil {
+		return false, err
+	}
+	return u.applyOrderStock(ctx, eventID, orderID, domain.StockOperationReserve, items, -1, policy)
+}
+
+// ReleaseOrderStock releases stock for every item of an order event exactly once.
+func (u *productUsecase) ReleaseOrderStock(ctx context.Context, eventID string, orderID int, items []domain.ProductRequest) (applied bool, err error) {
+	return u.applyOrderStock(ctx, eventID, orderID, domain.StockOperationRelease, items, 1, domain.AllocationPolicy{})
+}
+
+func (u *productUsecase) applyOrderStock(ctx context.Context, eventID string, orderID int, eventType string, items []domain.ProductRequest, sign int, policy domain.AllocationPolicy) (applied bool, err error) {
+	changes := make([]domain.StockChange, 0, len(items))
+	for _, item := range items {
+		changes = append(changes, domain.StockChange{ProductID: item.ProductID, SKU: item.SKU, Delta: sign * item.Quantity})
+	}
+
+	source := domain.MovementSource{OrderID: orderID, Actor: stockActor(ctx)}
+	applied, err = u.repo.ApplyStockChanges(ctx, eventID, eventType, changes, policy, source)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error applying stock changes for event %s", eventID)
+		return false, err
+	}
+
+	if !applied {
+		log.Info().Msgf("Event %s already processed, skipping", eventID)
+		return false, nil
+	}
+
+	u.invalidateProducts(ctx, changes)
+	return true, nil
+}
+
+// ReleaseReservedStock releases the stock held by the reservation reserveRef exactly once per releaseRef.
+// Releasing a reservation that was never applied does not change stock.
+func (u *productUsecase) ReleaseReservedStock(ctx context.Context, reserveRef, releaseRef string, items []domain.ProductRequest) (applied bool, err error) {
+	changes := make([]domain.StockChange, 0, len(items))
+	for _, item := range items {
+		changes = append(changes, domain.StockChange{ProductID: item.ProductID, SKU: item.SKU, Delta: item.Quantity})
+	}
+
+	applied, err = u.repo.ReleaseReservedStock(ctx, reserveRef, releaseRef, changes, domain.MovementSource{Actor: stockActor(ctx)})
 	if err != nil {
-		log.Error().Err(err).Msgf("Error updating product %d", productID)
+		log.Error().Err(err).Msgf("Error releasing reservation %s", reserveRef)
+		return false, err
+	}
+
+	if applied {
+		u.invalidateProducts(ctx, changes)
+	}
+
+	return applied, nil
+}
+
+// ReserveStockBatch reserves stock for every item or, when any product is short, for none of them.
+func (u *productUsecase) ReserveStockBatch(ctx context.Context, items []domain.StockItem, policy domain.AllocationPolicy) (err error) {
+	err = validateStockBatch(items)
+	if err != nil {
+		return err
+	}
+	if err = policy.Validate(); err != nil {
 		return err
 	}
 
-	// Write to cache
-	err = u.cache.SetProduct(ctx, product, 0)
+	err = u.repo.ReserveStockBatch(ctx, items, policy, domain.MovementSource{Actor: stockActor(ctx)})
 	if err != nil {
-		log.Error().Err(err).Msgf("Error setting product %d in cache", productID)
+		if errors.Is(err, domain.ErrInsufficientStock) {
+			log.Warn().Msg(err.Error())
+		} else {
+			log.Error().Err(err).Msgf("Error reserving a batch of %d items", len(items))
+		}
 		return err
 	}
+
+	u.invalidateProducts(ctx, stockItemChanges(items))
 	return nil
 }
 
-// PreWarmCache pre-warms the cache with product data.
-func (u *productUsecase) PreWarmCache(ctx context.Context) (err error) {
-	products, err := u.repo.GetProducts(ctx)
+// ReleaseStockBatch releases stock for every item in one transaction.
+func (u *productUsecase) ReleaseStockBatch(ctx context.Context, items []domain.StockItem) (err error) {
+	err = validateStockBatch(items)
 	if err != nil {
-		log.Error().Err(err).Msg("Error getting products")
 		return err
 	}
 
-	for _, product := range products {
-		err = u.cache.SetProduct(ctx, product, 1*time.Minute)
-		if err != nil {
-			log.Error().Err(err).Msgf("Error setting product %d in cache", product.ID)
-			return err
+	err = u.repo.ReleaseStockBatch(ctx, items, domain.MovementSource{Actor: stockActor(ctx)})
+	if err != nil {
+		log.Error().Err(err).Msgf("Error releasing a batch of %d items", len(items))
+		return err
+	}
+
+	u.invalidateProducts(ctx, stockItemChanges(items))
+	return nil
+}
+
+// GetStockLevels reads the total stock of up to MaxStockBatchItems products straight from the database,
+// so callers pricing a whole cart make one request. Products that do not exist are left out.
+func (u *productUsecase) GetStockLevels(ctx context.Context, productIDs []int) (levels []domain.StockLevel, err error) {
+	if len(productIDs) == 0 || len(productIDs) > domain.MaxStockBatchItems {
+		return nil, domain.ErrInvalidStockBatch
+	}
+
+	levels, err = u.repo.GetStockLevels(ctx, productIDs)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error getting stock of %d products", len(productIDs))
+		return nil, err
+	}
+
+	if levels == nil {
+		levels = []domain.StockLevel{}
+	}
+	return levels, nil
+}
+
+func validateStockBatch(items []domain.StockItem) error {
+	if len(items) == 0 || len(items) > domain.MaxStockBatchItems {
+		return domain.ErrInvalidStockBatch
+	}
+
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return domain.ErrInvalidQuantity
 		}
 	}
 
 	return nil
 }
 
-// PreWarmCacheAsync pre-warms the cache with product data asynchronously.
-func (u *productUsecase) PreWarmCacheAsync(ctx context.Context) (err error) {
+// stockItemChanges lists the products touched by a batch, for cache invalidation
+func stockItemChanges(items []domain.StockItem) []domain.StockChange {
+	changes := make([]domain.StockChange, len(items))
+	for i, item := range items {
+		changes[i] = domain.StockChange{ProductID: item.ProductID, SKU: item.SKU, Delta: item.Quantity}
+	}
+	return changes
+}
+
+// invalidateProducts drops cached products after a commit so readers never see uncommitted stock.
+func (u *productUsecase) invalidateProducts(ctx context.Context, changes []domain.StockChange) {
+	for _, change := range changes {
+		u.invalidateProduct(ctx, change.ProductID)
+	}
+}
+
+func (u *productUsecase) invalidateProduct(ctx context.Context, productID int) {
+	err := u.cache.DeleteProduct(ctx, productID)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error invalidating product %d in cache", productID)
+	}
+}
+
+// PreWarmCache loads every product into the cache the way GetProduct caches it, a few at a time.
+// Products that fail to load are skipped and reported together once the others are cached.
+func (u *productUsecase) PreWarmCache(ctx context.Context) (err error) {
 	products, err := u.repo.GetProducts(ctx)
 	if err != nil {
 		log.Error().Err(err).Msg("Error getting products")
 		return err
 	}
 
-	for _, product := range products {
-		go func(product domain.Product) {
-			err = u.cache.SetProduct(ctx, product, 1*time.Minute)
+	errs := make([]error, len(products))
+	sem := make(chan struct{}, preWarmConcurrency)
+	var wg sync.WaitGroup
+	for i, product := range products {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i, productID int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			product, err := u.loadProduct(ctx, productID)
+			if err == nil {
+				err = u.cache.SetProduct(ctx, product, 0)
+			}
 			if err != nil {
-				log.Error().Err(err).Msgf("Error setting product %d in cache", product.ID)
+				log.Error().Err(err).Msgf("Error warming product %d in cache", productID)
+				errs[i] = err
 			}
-		}(product)
+		}(i, product.ID)
 	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// PreWarmCacheAsync warms the cache in the background, outliving the request that started it.
+func (u *productUsecase) PreWarmCacheAsync(ctx context.Context) (err error) {
+	go func() {
+		err := u.PreWarmCache(context.WithoutCancel(ctx))
+		if err != nil {
+			log.Error().Err(err).Msg("Error pre-warming product cache")
+		}
+	}()
 
 	return nil
 }
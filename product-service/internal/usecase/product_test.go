@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"product-service/domain"
+	repo "product-service/internal/repository/mysql"
+	cache "product-service/internal/repository/redis"
+)
+
+// stubProducts serves products 1 to 3 with one variant and one warehouse each; product 2 fails to load.
+type stubProducts struct {
+	repo.ProductRepository
+}
+
+var errStubLoad = errors.New("load failed")
+
+func (stubProducts) GetProducts(ctx context.Context) ([]domain.Product, error) {
+	return []domain.Product{{ID: 1}, {ID: 2}, {ID: 3}}, nil
+}
+
+func (stubProducts) GetProductByID(ctx context.Context, id int) (domain.Product, error) {
+	if id == 2 {
+		return domain.Product{}, errStubLoad
+	}
+	return domain.Product{ID: id, Stock: 5}, nil
+}
+
+type stubVariants struct {
+	repo.VariantRepository
+}
+
+func (stubVariants) GetVariantsByProductIDs(ctx context.Context, ids []int) ([]domain.Variant, error) {
+	var variants []domain.Variant
+	for _, id := range ids {
+		variants = append(variants, domain.Variant{ProductID: id, SKU: "SKU", Stock: 5})
+	}
+	return variants, nil
+}
+
+type stubWarehouses struct {
+	repo.WarehouseRepository
+}
+
+func (stubWarehouses) GetProductWarehouseStock(ctx context.Context, productID int) ([]domain.WarehouseStock, error) {
+	return []domain.WarehouseStock{{WarehouseID: 1, SKU: "SKU", Stock: 5}}, nil
+}
+
+// recordingCache records the products set and the expiration they were set with.
+type recordingCache struct {
+	cache.ProductCache
+	mu          sync.Mutex
+	products    map[int]domain.Product
+	expirations map[int]time.Duration
+}
+
+func (c *recordingCache) SetProduct(ctx context.Context, product domain.Product, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.products[product.ID] = product
+	c.expirations[product.ID] = expiration
+	return nil
+}
+
+func TestPreWarmCacheCachesFullProducts(t *testing.T) {
+	products := &recordingCache{products: map[int]domain.Product{}, expirations: map[int]time.Duration{}}
+	u := NewProductUsecase(stubProducts{}, stubVariants{}, nil, stubWarehouses{}, products)
+
+	err := u.PreWarmCache(context.Background())
+	if !errors.Is(err, errStubLoad) {
+		t.Errorf("got %v, want the error of product 2", err)
+	}
+
+	if len(products.products) != 2 {
+		t.Fatalf("cached %d products, want 1 and 3", len(products.products))
+	}
+	for _, id := range []int{1, 3} {
+		product := products.products[id]
+		if len(product.Variants) != 1 || len(product.Warehouses) != 1 {
+			t.Errorf("product %d cached with %d variants and %d warehouses, want 1 of each", id, len(product.Variants), len(product.Warehouses))
+		}
+		// Zero makes the cache use the configured product TTL
+		if products.expirations[id] != 0 {
+			t.Errorf("product %d cached for %s, want the configured TTL", id, products.expirations[id])
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"product-service/config"
+	"product-service/domain"
+	repo "product-service/internal/repository/mysql"
+	cache "product-service/internal/repository/redis"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+const reservationSweepBatch = 100
+
+var ErrInvalidReservationTTL = errors.New("invalid reservation ttl")
+
+type ReservationUsecase interface {
+	CreateReservation(ctx context.Context, req domain.StockReservation, ttl time.Duration, policy domain.AllocationPolicy) (reservation domain.StockReservation, err error)
+	GetReservation(ctx context.Context, id int64) (reservation domain.StockReservation, err error)
+	ConfirmReservation(ctx context.Context, id int64) (reservation domain.StockReservation, err error)
+	ReleaseReservation(ctx context.Context, id int64) (reservation domain.StockReservation, err error)
+	ExpireReservations(ctx context.Context) (expired int, err error)
+	PublishExpiredReservations(ctx context.Context) (published int, err error)
+}
+
+type reservationUsecase struct {
+	repo              repo.ReservationRepository
+	cache             cache.ProductCache
+	reservationWriter *kafka.Writer
+	cfg               config.ReservationConfig
+}
+
+func NewReservationUsecase(repo repo.ReservationRepository, cache cache.ProductCache, reservationWriter *kafka.Writer, cfg config.ReservationConfig) ReservationUsecase {
+	return &reservationUsecase{
+		repo:              repo,
+		cache:             cache,
+		reservationWriter: reservationWriter,
+		cfg:               cfg,
+	}
+}
+
+// CreateReservation holds stock for ttl, or the default TTL when ttl is zero, taken from the warehouses chosen by policy.
+func (u *reservationUsecase) CreateReservation(ctx context.Context, req domain.StockReservation, ttl time.Duration, policy domain.AllocationPolicy) (reservation domain.StockReservation, err error) {
+	if req.Quantity <= 0 {
+		return reservation, domain.ErrInvalidQuantity
+	}
+	if err = policy.Validate(); err != nil {
+		return reservation, err
+	}
+	if ttl == 0 {
+		ttl = u.cfg.DefaultTTL
+	}
+	if ttl < 0 || ttl > u.cfg.MaxTTL {
+		return reservation, fmt.Errorf("%w: must be between 0 and %s", ErrInvalidReservationTTL, u.cfg.MaxTTL)
+	}
+
+	req.CreatedAt = time.Now()
+	req.ExpiresAt = req.CreatedAt.Add(ttl)
+	reservation, err = u.repo.CreateReservation(ctx, req, policy, stockActor(ctx))
+	if err != nil {
+		if !errors.Is(err, domain.ErrInsufficientStock) {
+			log.Error().Err(err).Msgf("Error reserving product %d", req.ProductID)
+		}
+		return reservation, err
+	}
+
+	u.invalidateProduct(ctx, reservation.ProductID)
+	return reservation, nil
+}
+
+func (u *reservationUsecase) GetReservation(ctx context.Context, id int64) (reservation domain.StockReservation, err error) {
+	reservation, err = u.repo.GetReservationByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return reservation, domain.ErrReservationNotFound
+		}
+		log.Error().Err(err).Msgf("Error getting reservation %d", id)
+		return reservation, err
+	}
+
+	return reservation, nil
+}
+
+// ConfirmReservation keeps the reserved stock for good, e.g. once the order is paid.
+func (u *reservationUsecase) ConfirmReservation(ctx context.Context, id int64) (reservation domain.StockReservation, err error) {
+	reservation, err = u.repo.ConfirmReservation(ctx, id, time.Now(), stockActor(ctx))
+	if err != nil {
+		// A confirm after the expiry expires the reservation, which gives its stock back
+		if reservation.State == domain.ReservationStateExpired {
+			u.invalidateProduct(ctx, reservation.ProductID)
+		}
+		return reservation, err
+	}
+
+	return reservation, nil
+}
+
+// ReleaseReservation gives the reserved stock back, e.g. when the order is cancelled.
+func (u *reservationUsecase) ReleaseReservation(ctx context.Context, id int64) (reservation domain.StockReservation, err error) {
+	reservation, err = u.repo.ReleaseReservation(ctx, id, time.Now(), stockActor(ctx))
+	if err != nil {
+		if !errors.Is(err, domain.ErrReservationNotFound) && !errors.Is(err, domain.ErrReservationConflict) {
+			log.Error().Err(err).Msgf("Error releasing reservation %d", id)
+		}
+		return reservation, err
+	}
+
+	u.invalidateProduct(ctx, reservation.ProductID)
+	return reservation, nil
+}
+
+// ExpireReservations releases every held reservation past its expiry, batch by batch.
+func (u *reservationUsecase) ExpireReservations(ctx context.Context) (expired int, err error) {
+	for {
+		reservations, err := u.repo.ExpireReservations(ctx, time.Now(), reservationSweepBatch)
+		if err != nil {
+			log.Error().Err(err).Msg("Error expiring reservations")
+			return expired, err
+		}
+
+		for _, reservation := range reservations {
+			u.invalidateProduct(ctx, reservation.ProductID)
+		}
+
+		expired += len(reservations)
+		if len(reservations) < reservationSweepBatch {
+			return expired, nil
+		}
+	}
+}
+
+// PublishExpiredReservations publishes a reservation.expired event for every expired reservation not announced yet.
+// An event is marked published only after Kafka accepted it, so it may be delivered more than once.
+func (u *reservationUsecase) PublishExpiredReservations(ctx context.Context) (published int, err error) {
+	reservations, err := u.repo.GetUnnotifiedExpiredReservations(ctx, reservationSweepBatch)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting expired reservations")
+		return 0, err
+	}
+
+	for _, reservation := range reservations {
+		event := domain.ReservationEvent{
+			// Derived from the reservation so a republished event keeps its ID
+			EventID:       fmt.Sprintf("%s:%d", domain.ReservationEventExpired, reservation.ID),
+			Type:          domain.ReservationEventExpired,
+			OccurredAt:    reservation.UpdatedAt.UTC(),
+			ReservationID: reservation.ID,
+			OrderID:       reservation.OrderID,
+			Reference:     reservation.Reference,
+			ProductID:     reservation.ProductID,
+			SKU:           reservation.SKU,
+			Quantity:      reservation.Quantity,
+		}
+
+		value, err := json.Marshal(event)
+		if err != nil {
+			return published, err
+		}
+
+		// Events of one order share a key so they stay in order
+		key := strconv.FormatInt(reservation.ID, 10)
+		if reservation.OrderID != 0 {
+			key = strconv.Itoa(reservation.OrderID)
+		}
+
+		err = u.reservationWriter.WriteMessages(ctx, kafka.Message{
+			Key:   []byte(key),
+			Value: value,
+			Headers: []kafka.Header{
+				{Key: domain.EventHeaderID, Value: []byte(event.EventID)},
+				{Key: domain.EventHeaderType, Value: []byte(event.Type)},
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msgf("Error publishing expiry of reservation %d", reservation.ID)
+			return published, err
+		}
+
+		err = u.repo.MarkReservationNotified(ctx, reservation.ID, time.Now())
+		if err != nil {
+			log.Error().Err(err).Msgf("Error marking expiry of reservation %d as published", reservation.ID)
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+func (u *reservationUsecase) invalidateProduct(ctx context.Context, productID int) {
+	err := u.cache.DeleteProduct(ctx, productID)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error invalidating product %d in cache", productID)
+	}
+}
@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"product-service/domain"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CreateVariant adds a variant to a product. The variant starts with its own stock.
+func (u *productUsecase) CreateVariant(ctx context.Context, req domain.Variant) (variant domain.Variant, err error) {
+	err = req.Validate()
+	if err != nil {
+		return variant, err
+	}
+
+	_, err = u.repo.GetProductByID(ctx, req.ProductID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return variant, domain.ErrProductNotFound
+		}
+		log.Error().Err(err).Msgf("Error getting product by ID %d", req.ProductID)
+		return variant, err
+	}
+
+	variant, err = u.variantRepo.CreateVariant(ctx, req, stockActor(ctx))
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidVariant) {
+			log.Error().Err(err).Msgf("Error creating variant of product %d", req.ProductID)
+		}
+		return variant, err
+	}
+
+	u.invalidateProduct(ctx, req.ProductID)
+	return variant, nil
+}
+
+// UpdateVariant updates the SKU, attributes and price of a variant. Stock is not changed.
+func (u *productUsecase) UpdateVariant(ctx context.Context, req domain.Variant) (variant domain.Variant, err error) {
+	variant, err = u.getProductVariant(ctx, req.ProductID, req.ID)
+	if err != nil {
+		return variant, err
+	}
+
+	req.Stock = variant.Stock
+	err = req.Validate()
+	if err != nil {
+		return variant, err
+	}
+
+	err = u.variantRepo.UpdateVariant(ctx, req)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidVariant) {
+			log.Error().Err(err).Msgf("Error updating variant %d", req.ID)
+		}
+		return variant, err
+	}
+
+	u.invalidateProduct(ctx, req.ProductID)
+	return req, nil
+}
+
+// DeleteVariant removes a variant of a product.
+func (u *productUsecase) DeleteVariant(ctx context.Context, productID, variantID int) (err error) {
+	_, err = u.getProductVariant(ctx, productID, variantID)
+	if err != nil {
+		return err
+	}
+
+	err = u.variantRepo.DeleteVariant(ctx, variantID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrVariantNotFound
+		}
+		log.Error().Err(err).Msgf("Error deleting variant %d", variantID)
+		return err
+	}
+
+	u.invalidateProduct(ctx, productID)
+	return nil
+}
+
+// getProductVariant gets a variant, reporting it as not found when it belongs to another product.
+func (u *productUsecase) getProductVariant(ctx context.Context, productID, variantID int) (variant domain.Variant, err error) {
+	variant, err = u.variantRepo.GetVariantByID(ctx, variantID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return variant, domain.ErrVariantNotFound
+		}
+		log.Error().Err(err).Msgf("Error getting variant %d", variantID)
+		return variant, err
+	}
+
+	if variant.ProductID != productID {
+		return domain.Variant{}, domain.ErrVariantNotFound
+	}
+	return variant, nil
+}
+
+// loadVariants fills in the variants of the products with one query.
+func (u *productUsecase) loadVariants(ctx context.Context, products []*domain.Product) (err error) {
+	ids := make([]int, len(products))
+	byID := make(map[int]*domain.Product, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+		byID[product.ID] = product
+	}
+
+	variants, err := u.variantRepo.GetVariantsByProductIDs(ctx, ids)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting product variants")
+		return err
+	}
+
+	for _, variant := range variants {
+		if product, ok := byID[variant.ProductID]; ok {
+			product.Variants = append(product.Variants, variant)
+		}
+	}
+	return nil
+}
+
+func productPointers(products []domain.Product) []*domain.Product {
+	pointers := make([]*domain.Product, len(products))
+	for i := range products {
+		pointers[i] = &products[i]
+	}
+	return pointers
+}
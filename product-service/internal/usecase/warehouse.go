@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"product-service/domain"
+	repo "product-service/internal/repository/mysql"
+	cache "product-service/internal/repository/redis"
+
+	"github.com/rs/zerolog/log"
+)
+
+type WarehouseUsecase interface {
+	GetWarehouses(ctx context.Context) (warehouses []domain.Warehouse, err error)
+	CreateWarehouse(ctx context.Context, req domain.Warehouse) (warehouse domain.Warehouse, err error)
+	UpdateWarehouse(ctx context.Context, req domain.Warehouse) (warehouse domain.Warehouse, err error)
+	TransferStock(ctx context.Context, transfer domain.StockTransfer) (err error)
+}
+
+type warehouseUsecase struct {
+	repo  repo.WarehouseRepository
+	cache cache.ProductCache
+}
+
+func NewWarehouseUsecase(repo repo.WarehouseRepository, cache cache.ProductCache) WarehouseUsecase {
+	return &warehouseUsecase{repo: repo, cache: cache}
+}
+
+func (u *warehouseUsecase) GetWarehouses(ctx context.Context) (warehouses []domain.Warehouse, err error) {
+	warehouses, err = u.repo.GetWarehouses(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting warehouses")
+		return nil, err
+	}
+
+	if warehouses == nil {
+		warehouses = []domain.Warehouse{}
+	}
+	return warehouses, nil
+}
+
+func (u *warehouseUsecase) CreateWarehouse(ctx context.Context, req domain.Warehouse) (warehouse domain.Warehouse, err error) {
+	req.Name = strings.TrimSpace(req.Name)
+	err = req.Validate()
+	if err != nil {
+		return warehouse, err
+	}
+
+	warehouse, err = u.repo.CreateWarehouse(ctx, req)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidWarehouse) {
+			log.Error().Err(err).Msgf("Error creating warehouse %s", req.Code)
+		}
+		return warehouse, err
+	}
+
+	return warehouse, nil
+}
+
+// UpdateWarehouse replaces the settings of a warehouse. Deactivating it stops new reservations from
+// taking its stock; the stock stays until it is transferred away.
+func (u *warehouseUsecase) UpdateWarehouse(ctx context.Context, req domain.Warehouse) (warehouse domain.Warehouse, err error) {
+	req.Name = strings.TrimSpace(req.Name)
+	err = req.Validate()
+	if err != nil {
+		return warehouse, err
+	}
+
+	_, err = u.repo.GetWarehouseByID(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return warehouse, domain.ErrWarehouseNotFound
+		}
+		log.Error().Err(err).Msgf("Error getting warehouse %d", req.ID)
+		return warehouse, err
+	}
+
+	err = u.repo.UpdateWarehouse(ctx, req)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidWarehouse) {
+			log.Error().Err(err).Msgf("Error updating warehouse %d", req.ID)
+		}
+		return warehouse, err
+	}
+
+	return req, nil
+}
+
+// TransferStock moves stock of a product or variant from one warehouse to another.
+func (u *warehouseUsecase) TransferStock(ctx context.Context, transfer domain.StockTransfer) (err error) {
+	err = transfer.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = u.repo.TransferStock(ctx, transfer, stockActor(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInsufficientStock):
+			log.Warn().Msg(err.Error())
+		case errors.Is(err, domain.ErrWarehouseNotFound), errors.Is(err, domain.ErrProductNotFound), errors.Is(err, domain.ErrVariantNotFound):
+		default:
+			log.Error().Err(err).Msgf("Error transferring stock of product %d", transfer.ProductID)
+		}
+		return err
+	}
+
+	// The per-warehouse stock is cached with the product
+	err = u.cache.DeleteProduct(ctx, transfer.ProductID)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error invalidating product %d in cache", transfer.ProductID)
+	}
+	return nil
+}
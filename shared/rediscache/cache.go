@@ -0,0 +1,159 @@
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache is a cache-aside store of JSON values in Redis. Entries live for their TTL plus a random share of
+// it up to jitter, so entries written together do not all expire together, and concurrent misses of a key
+// within the process share a single load.
+type Cache struct {
+	rdb    *redis.Client
+	jitter float64
+	group  singleflight.Group
+}
+
+func NewCache(rdb *redis.Client, jitter float64) *Cache {
+	return &Cache{rdb: rdb, jitter: jitter}
+}
+
+// Key builds the key of an entry from its kind and ID, e.g. Key("product", 42) is "product:42".
+func Key(prefix string, id interface{}) string {
+	return fmt.Sprintf("%s:%v", prefix, id)
+}
+
+// Get reads the entry at key into dest. found is false when there is no entry.
+func (c *Cache) Get(ctx context.Context, key string, dest interface{}) (found bool, err error) {
+	data, err := c.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	err = json.Unmarshal(data, dest)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Set stores value at key for ttl plus jitter. A zero ttl stores it without expiry.
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) (err error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.rdb.Set(ctx, key, data, c.expiration(ttl)).Err()
+}
+
+// GetMany reads the entries at keys with a single MGET, decoding the entry of keys[i] into dest(i).
+// found[i] is false when keys[i] has no entry or one that cannot be decoded.
+func (c *Cache) GetMany(ctx context.Context, keys []string, dest func(i int) interface{}) (found []bool, err error) {
+	found = make([]bool, len(keys))
+	if len(keys) == 0 {
+		return found, nil
+	}
+
+	values, err := c.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return found, err
+	}
+
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		err = json.Unmarshal([]byte(data), dest(i))
+		if err != nil {
+			log.Warn().Err(err).Msgf("Error decoding %s from cache", keys[i])
+			continue
+		}
+		found[i] = true
+	}
+	return found, nil
+}
+
+// SetMany stores values[i] at keys[i] for ttl plus jitter, each with its own jitter, in one pipeline.
+func (c *Cache) SetMany(ctx context.Context, keys []string, values []interface{}, ttl time.Duration) (err error) {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pipe := c.rdb.Pipeline()
+	for i, key := range keys {
+		data, err := json.Marshal(values[i])
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, key, data, c.expiration(ttl))
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Delete drops the entries at keys. Loads of them already running may still store what they read, so
+// writers invalidate after committing and the TTL bounds how long such an entry can live.
+func (c *Cache) Delete(ctx context.Context, keys ...string) (err error) {
+	for _, key := range keys {
+		c.group.Forget(key)
+	}
+	return c.rdb.Del(ctx, keys...).Err()
+}
+
+// Fetch reads the entry at key into dest or, on a miss, loads it, stores it for ttl and decodes it into
+// dest. An unreadable cache is treated as a miss and a failed store is only logged, so Redis being down
+// slows reads without failing them. Errors of load are returned as is and nothing is stored.
+func (c *Cache) Fetch(ctx context.Context, key string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (value interface{}, err error)) (err error) {
+	found, err := c.Get(ctx, key, dest)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Error reading %s from cache", key)
+	}
+	if found {
+		return nil
+	}
+
+	data, err, _ := c.group.Do(key, func() (interface{}, error) {
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		err = c.rdb.Set(ctx, key, data, c.expiration(ttl)).Err()
+		if err != nil {
+			log.Warn().Err(err).Msgf("Error writing %s to cache", key)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data.([]byte), dest)
+}
+
+// expiration adds up to jitter of ttl to it.
+func (c *Cache) expiration(ttl time.Duration) time.Duration {
+	if ttl <= 0 || c.jitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(int64(float64(ttl)*c.jitter)+1))
+}
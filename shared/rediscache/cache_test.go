@@ -0,0 +1,264 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+type entry struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func newTestCache(t *testing.T, jitter float64) (*Cache, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return NewCache(rdb, jitter), mr
+}
+
+func TestKey(t *testing.T) {
+	if got := Key("product", 42); got != "product:42" {
+		t.Errorf("Key(product, 42) = %q", got)
+	}
+}
+
+func TestGetAndSet(t *testing.T) {
+	c, _ := newTestCache(t, 0)
+	ctx := context.Background()
+
+	var got entry
+	found, err := c.Get(ctx, "entry:1", &got)
+	if err != nil || found {
+		t.Fatalf("Get of a missing key = %v, %v", found, err)
+	}
+
+	err = c.Set(ctx, "entry:1", entry{ID: 1, Name: "one"}, time.Minute)
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	found, err = c.Get(ctx, "entry:1", &got)
+	if err != nil || !found {
+		t.Fatalf("Get = %v, %v", found, err)
+	}
+	if got != (entry{ID: 1, Name: "one"}) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestGetUndecodableEntry(t *testing.T) {
+	c, mr := newTestCache(t, 0)
+	mr.Set("entry:1", "not json")
+
+	var got entry
+	found, err := c.Get(context.Background(), "entry:1", &got)
+	if err == nil || found {
+		t.Errorf("Get of an undecodable entry = %v, %v", found, err)
+	}
+}
+
+func TestSetExpiration(t *testing.T) {
+	tests := []struct {
+		name     string
+		jitter   float64
+		ttl      time.Duration
+		min, max time.Duration
+	}{
+		{"no jitter", 0, time.Minute, time.Minute, time.Minute},
+		{"jitter", 0.5, time.Minute, time.Minute, 90 * time.Second},
+		{"no expiry", 0.5, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mr := newTestCache(t, tt.jitter)
+
+			for i := 0; i < 20; i++ {
+				err := c.Set(context.Background(), "entry:1", entry{ID: 1}, tt.ttl)
+				if err != nil {
+					t.Fatalf("Set: %v", err)
+				}
+				if ttl := mr.TTL("entry:1"); ttl < tt.min || ttl > tt.max {
+					t.Fatalf("TTL is %s, want between %s and %s", ttl, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestExpiredEntryIsAMiss(t *testing.T) {
+	c, mr := newTestCache(t, 0)
+	ctx := context.Background()
+
+	err := c.Set(ctx, "entry:1", entry{ID: 1}, time.Minute)
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	mr.FastForward(time.Minute + time.Second)
+
+	var got entry
+	found, err := c.Get(ctx, "entry:1", &got)
+	if err != nil || found {
+		t.Errorf("Get of an expired entry = %v, %v", found, err)
+	}
+}
+
+func TestGetManyAndSetMany(t *testing.T) {
+	c, mr := newTestCache(t, 0.1)
+	ctx := context.Background()
+
+	err := c.SetMany(ctx, []string{"entry:1", "entry:2"}, []interface{}{entry{ID: 1}, entry{ID: 2}}, time.Minute)
+	if err != nil {
+		t.Fatalf("SetMany: %v", err)
+	}
+	mr.Set("entry:3", "not json")
+
+	keys := []string{"entry:1", "entry:2", "entry:3", "entry:4"}
+	got := make([]entry, len(keys))
+	found, err := c.GetMany(ctx, keys, func(i int) interface{} { return &got[i] })
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+
+	want := []bool{true, true, false, false}
+	for i := range keys {
+		if found[i] != want[i] {
+			t.Errorf("found %s = %v, want %v", keys[i], found[i], want[i])
+		}
+	}
+	if got[0].ID != 1 || got[1].ID != 2 {
+		t.Errorf("got %+v", got)
+	}
+
+	found, err = c.GetMany(ctx, nil, nil)
+	if err != nil || len(found) != 0 {
+		t.Errorf("GetMany of no keys = %v, %v", found, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c, mr := newTestCache(t, 0)
+	ctx := context.Background()
+
+	err := c.SetMany(ctx, []string{"entry:1", "entry:2", "entry:3"}, []interface{}{entry{ID: 1}, entry{ID: 2}, entry{ID: 3}}, time.Minute)
+	if err != nil {
+		t.Fatalf("SetMany: %v", err)
+	}
+
+	err = c.Delete(ctx, "entry:1", "entry:2")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if mr.Exists("entry:1") || mr.Exists("entry:2") || !mr.Exists("entry:3") {
+		t.Errorf("keys left after Delete: %v", mr.Keys())
+	}
+}
+
+func TestFetch(t *testing.T) {
+	c, mr := newTestCache(t, 0)
+	ctx := context.Background()
+
+	loads := 0
+	load := func(ctx context.Context) (interface{}, error) {
+		loads++
+		return entry{ID: 1, Name: "loaded"}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		var got entry
+		err := c.Fetch(ctx, "entry:1", time.Minute, &got, load)
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		if got != (entry{ID: 1, Name: "loaded"}) {
+			t.Errorf("got %+v", got)
+		}
+	}
+
+	if loads != 1 {
+		t.Errorf("loaded %d times, want once", loads)
+	}
+	if ttl := mr.TTL("entry:1"); ttl != time.Minute {
+		t.Errorf("stored for %s, want 1m", ttl)
+	}
+}
+
+func TestFetchLoadErrorIsNotStored(t *testing.T) {
+	c, mr := newTestCache(t, 0)
+	errLoad := errors.New("load failed")
+
+	var got entry
+	err := c.Fetch(context.Background(), "entry:1", time.Minute, &got, func(ctx context.Context) (interface{}, error) {
+		return nil, errLoad
+	})
+	if !errors.Is(err, errLoad) {
+		t.Errorf("Fetch error = %v, want %v", err, errLoad)
+	}
+	if mr.Exists("entry:1") {
+		t.Error("failed load was stored")
+	}
+}
+
+func TestFetchWithRedisDown(t *testing.T) {
+	c, mr := newTestCache(t, 0)
+	mr.Close()
+
+	var got entry
+	err := c.Fetch(context.Background(), "entry:1", time.Minute, &got, func(ctx context.Context) (interface{}, error) {
+		return entry{ID: 1}, nil
+	})
+	if err != nil {
+		t.Fatalf("Fetch with Redis down: %v", err)
+	}
+	if got.ID != 1 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestFetchSharesConcurrentLoads(t *testing.T) {
+	c, _ := newTestCache(t, 0)
+
+	var loads int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return entry{ID: 1}, nil
+	}
+
+	const callers = 20
+	var wg sync.WaitGroup
+	var started sync.WaitGroup
+	started.Add(callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+
+			var got entry
+			err := c.Fetch(context.Background(), "entry:1", time.Minute, &got, load)
+			if err != nil || got.ID != 1 {
+				t.Errorf("Fetch = %+v, %v", got, err)
+			}
+		}()
+	}
+
+	started.Wait()
+	time.Sleep(50 * time.Millisecond) // let the callers miss and join the load
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("loaded %d times for concurrent misses, want once", n)
+	}
+}
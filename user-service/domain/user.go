@@ -1,8 +1,14 @@
 package domain
 
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
 type User struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"` // In production, you'd store hashed passwords.
+	Role     string `json:"role"`
 }
@@ -22,8 +22,8 @@ func NewUserRepository(db *sql.DB) UserRepository {
 }
 
 func (r *userRepository) GetUserByID(ctx context.Context, id int) (user domain.User, err error) {
-	query := `SELECT id, username, email, password FROM users WHERE id = ?`
-	err = r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password)
+	query := `SELECT id, username, email, password, role FROM users WHERE id = ?`
+	err = r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role)
 	if err != nil {
 		return user, err
 	}
@@ -32,8 +32,8 @@ func (r *userRepository) GetUserByID(ctx context.Context, id int) (user domain.U
 }
 
 func (r *userRepository) CreateUser(ctx context.Context, req domain.User) (user domain.User, err error) {
-	query := `INSERT INTO users (username, email, password) VALUES (?, ?, ?)`
-	res, err := r.db.ExecContext(ctx, query, req.Username, req.Email, req.Password)
+	query := `INSERT INTO users (username, email, password, role) VALUES (?, ?, ?, ?)`
+	res, err := r.db.ExecContext(ctx, query, req.Username, req.Email, req.Password, req.Role)
 	if err != nil {
 		return user, err
 	}
@@ -48,14 +48,15 @@ func (r *userRepository) CreateUser(ctx context.Context, req domain.User) (user
 		Username: req.Username,
 		Email:    req.Email,
 		Password: req.Password, // Hati-hati menyertakan password dalam respons
+		Role:     req.Role,
 	}
 
 	return user, nil
 }
 
 func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (user domain.User, err error) {
-	query := `SELECT id, username, email, password FROM users WHERE email = ?`
-	err = r.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password)
+	query := `SELECT id, username, email, password, role FROM users WHERE email = ?`
+	err = r.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role)
 	if err != nil {
 		return user, err
 	}
@@ -56,6 +56,9 @@ func (u *userUsecase) CreateUser(ctx context.Context, req domain.User) (user dom
 
 	req.Password = string(hashedPassword)
 
+	// Admins are promoted in the database, never through self sign-up
+	req.Role = domain.RoleCustomer
+
 	createdUser, err := u.repo.CreateUser(ctx, req)
 	if err != nil {
 		log.Error().Err(err).Msg("Error creating user")